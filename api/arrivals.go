@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"sort"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/serializers"
 	"github.com/rijdendetreinen/gotrain/stores"
 )
 
@@ -24,6 +26,13 @@ func arrivalCounters(w http.ResponseWriter, r *http.Request) {
 }
 
 func arrivalsStation(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseBoardFilter(r.URL.Query(), "origin", time.Now())
+
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	vars := mux.Vars(r)
@@ -32,8 +41,15 @@ func arrivalsStation(w http.ResponseWriter, r *http.Request) {
 	language := getLanguageVar(r.URL)
 
 	arrivals := stores.Stores.ArrivalStore.GetStationArrivals(station, false)
+	sortArrivals(arrivals)
+	arrivals = filter.filterArrivals(arrivals)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wrapArrivalsStatus("arrivals", arrivalsToJSON(arrivals, language)))
+}
 
-	// Sort arrivals on arrival time, or on planned origin when arrival times are equal
+// sortArrivals sorts arrivals on arrival time, or on planned origin when arrival times are equal
+func sortArrivals(arrivals []models.Arrival) {
 	sort.Slice(arrivals, func(i, j int) bool {
 		if arrivals[i].ArrivalTime.Equal(arrivals[j].ArrivalTime) {
 			return arrivals[i].PlannedOriginString() < arrivals[j].PlannedOriginString()
@@ -41,9 +57,6 @@ func arrivalsStation(w http.ResponseWriter, r *http.Request) {
 
 		return arrivals[i].ArrivalTime.Before(arrivals[j].ArrivalTime)
 	})
-
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(wrapArrivalsStatus("arrivals", arrivalsToJSON(arrivals, language)))
 }
 
 func arrivalDetails(w http.ResponseWriter, r *http.Request) {
@@ -64,49 +77,23 @@ func arrivalDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	response := serializers.ArrivalToJSON(*arrival, language)
+
+	if getBooleanQueryParameter(r.URL, "history", false) {
+		response["history"] = historyToJSON(stores.Stores.ArrivalStore.GetHistory(arrival.ID))
+	}
+
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(wrapArrivalsStatus("arrival", arrivalToJSON(*arrival, language)))
+	json.NewEncoder(w).Encode(wrapArrivalsStatus("arrival", response))
 }
 
 func arrivalsToJSON(arrivals []models.Arrival, language string) []map[string]interface{} {
 	response := make([]map[string]interface{}, 0)
 
 	for _, arrival := range arrivals {
-		response = append(response, arrivalToJSON(arrival, language))
-	}
-
-	return response
-}
-
-func arrivalToJSON(arrival models.Arrival, language string) map[string]interface{} {
-	response := map[string]interface{}{
-		"service_id":          arrival.ServiceID,
-		"name":                nullString(arrival.ServiceName),
-		"line_number":         nullString(arrival.LineNumber),
-		"timestamp":           arrival.Timestamp,
-		"status":              arrival.Status,
-		"service_date":        arrival.ServiceDate,
-		"service_number":      arrival.ServiceNumber,
-		"station":             arrival.Station.Code,
-		"type":                arrival.ServiceType,
-		"type_code":           arrival.ServiceTypeCode,
-		"company":             arrival.Company,
-		"origin_actual":       nullString(arrival.ActualOriginString()),
-		"origin_planned":      nullString(arrival.PlannedOriginString()),
-		"origin_actual_codes": arrival.ActualOriginCodes(),
-		"via":                 nullString(arrival.ViaStationsString()),
-		"arrival_time":        localTimeString(arrival.ArrivalTime),
-		"platform_actual":     nullString(arrival.PlatformActual),
-		"platform_planned":    nullString(arrival.PlatformPlanned),
-		"delay":               arrival.Delay,
-		"cancelled":           arrival.Cancelled,
-		"platform_changed":    arrival.PlatformChanged(),
-
-		"remarks": []interface{}{},
+		response = append(response, serializers.ArrivalToJSON(arrival, language))
 	}
 
-	response["remarks"] = models.GetRemarks(arrival.Modifications, language)
-
 	return response
 }
 
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/serializers"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+func connections(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseBoardFilter(r.URL.Query(), "destination", time.Now())
+
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+
+	from := strings.ToUpper(vars["from"])
+	to := strings.ToUpper(vars["to"])
+	language := getLanguageVar(r.URL)
+	verbose := getBooleanQueryParameter(r.URL, "verbose", false)
+
+	connections := stores.Stores.GetConnections(from, to)
+
+	sort.Slice(connections, func(i, j int) bool {
+		return departureBefore(connections[i].Departure, connections[j].Departure)
+	})
+
+	// Apply the filters on the departures, and keep the matching connections:
+	departures := make([]models.Departure, 0, len(connections))
+	arrivals := make(map[string]stores.Connection)
+
+	for _, connection := range connections {
+		departures = append(departures, connection.Departure)
+		arrivals[connection.Departure.ID] = connection
+	}
+
+	response := make([]map[string]interface{}, 0)
+
+	for _, departure := range filter.filterDepartures(departures) {
+		response = append(response, connectionToJSON(arrivals[departure.ID], to, language, verbose))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wrapDeparturesStatus("connections", response))
+}
+
+func connectionToJSON(connection stores.Connection, to, language string, verbose bool) map[string]interface{} {
+	response := serializers.DepartureToJSON(connection.Departure, language, verbose, nil)
+
+	arrival := map[string]interface{}{
+		"station":               to,
+		"station_name":          nil,
+		"arrival_time":          nil,
+		"expected_arrival_time": nil,
+		"delay":                 nil,
+		"platform_actual":       nil,
+		"platform_planned":      nil,
+	}
+
+	if connection.Arrival != nil {
+		arrival["station_name"] = serializers.NullString(connection.Arrival.Station.NameLong)
+		arrival["arrival_time"] = serializers.LocalTimeString(connection.Arrival.ArrivalTime)
+		arrival["expected_arrival_time"] = serializers.LocalTimeString(connection.ExpectedArrivalTime())
+		arrival["delay"] = connection.Arrival.ArrivalDelay
+		arrival["platform_actual"] = serializers.NullString(connection.Arrival.ArrivalPlatformActual)
+		arrival["platform_planned"] = serializers.NullString(connection.Arrival.ArrivalPlatformPlanned)
+	}
+
+	response["arrival"] = arrival
+
+	return response
+}
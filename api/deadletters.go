@@ -0,0 +1,18 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rijdendetreinen/gotrain/receiver"
+)
+
+func deadLetters(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"enabled":      receiver.DeadLetters != nil,
+		"dead_letters": receiver.DeadLetters.Recent(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -2,11 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/serializers"
 	"github.com/rijdendetreinen/gotrain/stores"
 )
 
@@ -24,6 +28,13 @@ func departureCounters(w http.ResponseWriter, r *http.Request) {
 }
 
 func departuresStation(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseBoardFilter(r.URL.Query(), "destination", time.Now())
+
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	vars := mux.Vars(r)
@@ -33,18 +44,112 @@ func departuresStation(w http.ResponseWriter, r *http.Request) {
 	verbose := getBooleanQueryParameter(r.URL, "verbose", false)
 
 	departures := stores.Stores.DepartureStore.GetStationDepartures(station, false)
+	sortDepartures(departures)
+	departures = filter.filterDepartures(departures)
 
-	// Sort departures on departure time, or on planned destination when departure times are equal
-	sort.Slice(departures, func(i, j int) bool {
-		if departures[i].DepartureTime.Equal(departures[j].DepartureTime) {
-			return departures[i].PlannedDestinationString() < departures[j].PlannedDestinationString()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wrapDeparturesStatus("departures", departuresToJSON(departures, language, verbose)))
+}
+
+// maxBoardStations is the maximum number of stations on a combined departure board
+const maxBoardStations = 10
+
+func departuresStations(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseBoardFilter(r.URL.Query(), "destination", time.Now())
+
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	stations := parseStationList(mux.Vars(r)["stations"])
+
+	if len(stations) == 0 || len(stations) > maxBoardStations {
+		badRequest(w, fmt.Errorf("between 1 and %d stations required", maxBoardStations))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	language := getLanguageVar(r.URL)
+	verbose := getBooleanQueryParameter(r.URL, "verbose", false)
+
+	var departures []models.Departure
+
+	for _, station := range stations {
+		departures = append(departures, stores.Stores.DepartureStore.GetStationDepartures(station, false)...)
+	}
+
+	sortDepartures(departures)
+
+	// The limit applies to the combined board, so it is applied after removing duplicates:
+	limit := filter.limit
+	filter.limit = 0
+	departures = filter.filterDepartures(departures)
+
+	// Services which depart from multiple stations are only listed once, at the first station they depart from:
+	serviceStations := make(map[string][]string)
+	var unique []models.Departure
+
+	for _, departure := range departures {
+		key := departure.ServiceDate + "-" + departure.ServiceNumber
+
+		if _, exists := serviceStations[key]; !exists {
+			unique = append(unique, departure)
 		}
 
-		return departures[i].DepartureTime.Before(departures[j].DepartureTime)
-	})
+		serviceStations[key] = append(serviceStations[key], departure.Station.Code)
+	}
+
+	if limit > 0 && len(unique) > limit {
+		unique = unique[:limit]
+	}
+
+	response := make([]map[string]interface{}, 0, len(unique))
+
+	for _, departure := range unique {
+		departureJSON := serializers.DepartureToJSON(departure, language, verbose, nil)
+		departureJSON["station_name"] = serializers.NullString(departure.Station.NameLong)
+		departureJSON["stations"] = serviceStations[departure.ServiceDate+"-"+departure.ServiceNumber]
+
+		response = append(response, departureJSON)
+	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(wrapDeparturesStatus("departures", departuresToJSON(departures, language, verbose)))
+	json.NewEncoder(w).Encode(wrapDeparturesStatus("departures", response))
+}
+
+// parseStationList parses a comma separated list of station codes, ignoring duplicates
+func parseStationList(value string) []string {
+	var stations []string
+	seen := make(map[string]bool)
+
+	for _, station := range strings.Split(value, ",") {
+		station = strings.ToUpper(strings.TrimSpace(station))
+
+		if station != "" && !seen[station] {
+			seen[station] = true
+			stations = append(stations, station)
+		}
+	}
+
+	return stations
+}
+
+// sortDepartures sorts departures on departure time, or on planned destination when departure times are equal
+func sortDepartures(departures []models.Departure) {
+	sort.Slice(departures, func(i, j int) bool {
+		return departureBefore(departures[i], departures[j])
+	})
+}
+
+// departureBefore is the ordering of departures on a departure board
+func departureBefore(a, b models.Departure) bool {
+	if a.DepartureTime.Equal(b.DepartureTime) {
+		return a.PlannedDestinationString() < b.PlannedDestinationString()
+	}
+
+	return a.DepartureTime.Before(b.DepartureTime)
 }
 
 func departureDetails(w http.ResponseWriter, r *http.Request) {
@@ -73,134 +178,23 @@ func departureDetails(w http.ResponseWriter, r *http.Request) {
 		service = stores.Stores.ServiceStore.GetService(departure.ServiceNumber, departure.ServiceDate)
 	}
 
+	response := serializers.DepartureToJSON(*departure, language, verbose, service)
+
+	if getBooleanQueryParameter(r.URL, "history", false) {
+		response["history"] = historyToJSON(stores.Stores.DepartureStore.GetHistory(departure.ID))
+	}
+
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(wrapDeparturesStatus("departure", departureToJSON(*departure, language, verbose, service)))
+	json.NewEncoder(w).Encode(wrapDeparturesStatus("departure", response))
 }
 
 func departuresToJSON(departures []models.Departure, language string, verbose bool) []map[string]interface{} {
 	response := make([]map[string]interface{}, 0)
 
 	for _, departure := range departures {
-		response = append(response, departureToJSON(departure, language, verbose, nil))
-	}
-
-	return response
-}
-
-func departureToJSON(departure models.Departure, language string, verbose bool, service *models.Service) map[string]interface{} {
-	response := map[string]interface{}{
-		"service_id":               departure.ServiceID,
-		"name":                     nullString(departure.ServiceName),
-		"line_number":              nullString(departure.LineNumber),
-		"timestamp":                departure.Timestamp,
-		"status":                   departure.Status,
-		"service_date":             departure.ServiceDate,
-		"service_number":           departure.ServiceNumber,
-		"station":                  departure.Station.Code,
-		"type":                     departure.ServiceType,
-		"type_code":                departure.ServiceTypeCode,
-		"company":                  departure.Company,
-		"destination_actual":       nullString(departure.ActualDestinationString()),
-		"destination_planned":      nullString(departure.PlannedDestinationString()),
-		"destination_actual_codes": departure.ActualDestinationCodes(),
-		"via":                      nullString(departure.ActualViaStationsString()),
-		"departure_time":           localTimeString(departure.DepartureTime),
-		"platform_actual":          nullString(departure.PlatformActual),
-		"platform_planned":         nullString(departure.PlatformPlanned),
-		"delay":                    departure.Delay,
-		"cancelled":                departure.Cancelled,
-		"platform_changed":         departure.PlatformChanged(),
-
-		"remarks": []interface{}{},
-		"tips":    []interface{}{},
-
-		"wings": []interface{}{},
-	}
-
-	response["remarks"], response["tips"] = departure.GetRemarksTips(language)
-
-	responseWings := []interface{}{}
-
-	if departure.Cancelled {
-		// Override actual destination and via stations with planned destination and via:
-		response["destination_actual"] = response["destination_planned"]
-		response["via"] = nullString(departure.PlannedViaStationsString())
-		response["delay"] = 0
+		response = append(response, serializers.DepartureToJSON(departure, language, verbose, nil))
 	}
 
-	if verbose {
-		var serviceStops map[string]models.ServiceStop
-
-		if service != nil {
-			serviceStops = service.GetStops()
-		}
-
-		for _, trainWing := range departure.TrainWings {
-			wingResponse := map[string]interface{}{
-				"destination_actual":  trainWing.DestinationActualString(),
-				"destination_planned": trainWing.DestinationPlannedString(),
-				"remarks":             models.GetRemarks(trainWing.Modifications, language),
-				"stops":               []interface{}{},
-			}
-
-			stops := []interface{}{}
-
-			wingStops := trainWing.Stations
-
-			if departure.Cancelled {
-				wingStops = trainWing.StationsPlanned
-			}
-
-			for _, station := range wingStops {
-				stopData := map[string]interface{}{
-					"code":                       station.Code,
-					"short":                      station.NameShort,
-					"medium":                     station.NameMedium,
-					"long":                       station.NameLong,
-					"assistance_available":       false,
-					"accessible":                 false,
-					"arrival_time":               nil,
-					"arrival_platform":           nil,
-					"arrival_cancelled":          false,
-					"arrival_delay":              0,
-					"arrival_platform_changed":   false,
-					"departure_time":             nil,
-					"departure_platform":         nil,
-					"departure_cancelled":        false,
-					"departure_delay":            0,
-					"departure_platform_changed": false,
-				}
-
-				serviceStop, exists := serviceStops[station.Code]
-				if exists {
-					stopData["assistance_available"] = serviceStop.AssistanceAvailable
-					stopData["accessible"] = serviceStop.StationAccessible
-
-					stopData["arrival_time"] = localTimeString(serviceStop.ArrivalTime)
-					stopData["arrival_platform"] = nullString(serviceStop.ArrivalPlatformActual)
-					stopData["arrival_cancelled"] = serviceStop.ArrivalCancelled
-					stopData["arrival_delay"] = serviceStop.ArrivalDelay
-					stopData["arrival_platform_changed"] = serviceStop.ArrivalPlatformChanged()
-
-					stopData["departure_time"] = localTimeString(serviceStop.DepartureTime)
-					stopData["departure_platform"] = nullString(serviceStop.DeparturePlatformActual)
-					stopData["departure_cancelled"] = serviceStop.DepartureCancelled
-					stopData["departure_delay"] = serviceStop.DepartureDelay
-					stopData["departure_platform_changed"] = serviceStop.DeparturePlatformChanged()
-				}
-
-				stops = append(stops, stopData)
-			}
-
-			wingResponse["material"] = materialsToJSON(trainWing.Material, language, verbose)
-			wingResponse["stops"] = stops
-
-			responseWings = append(responseWings, wingResponse)
-		}
-	}
-
-	response["wings"] = responseWings
-
 	return response
 }
 
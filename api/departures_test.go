@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+func generateDeparture(serviceNumber, station, platform string, departureTime time.Time) models.Departure {
+	var departure models.Departure
+
+	departure.ProductID = "12345"
+	departure.ServiceID = serviceNumber
+	departure.ServiceNumber = serviceNumber
+	departure.Station.Code = station
+	departure.ServiceDate = "2019-01-27"
+	departure.GenerateID()
+	departure.Timestamp = time.Date(2019, time.January, 27, 12, 0, 0, 0, time.UTC)
+	departure.DepartureTime = departureTime
+	departure.PlatformActual = platform
+
+	return departure
+}
+
+func getDeparturesStations(t *testing.T, stations, query string) []map[string]interface{} {
+	router := mux.NewRouter()
+	router.HandleFunc("/v2/departures/stations/{stations}", departuresStations)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v2/departures/stations/"+stations+"?"+query, nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var response struct {
+		Departures []map[string]interface{} `json:"departures"`
+	}
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	return response.Departures
+}
+
+func TestDeparturesStations(t *testing.T) {
+	stores.InitializeStores()
+
+	departureTime := time.Date(2019, time.January, 27, 12, 30, 0, 0, time.UTC)
+
+	// Service 1234 departs from UT and then from ASD, service 5678 only from ASD:
+	stores.Stores.DepartureStore.ProcessDeparture(generateDeparture("1234", "UT", "5", departureTime))
+	stores.Stores.DepartureStore.ProcessDeparture(generateDeparture("1234", "ASD", "7", departureTime.Add(30*time.Minute)))
+	stores.Stores.DepartureStore.ProcessDeparture(generateDeparture("5678", "ASD", "7", departureTime.Add(40*time.Minute)))
+
+	departures := getDeparturesStations(t, "ut,asd", "")
+
+	if len(departures) != 2 {
+		t.Fatalf("Expected 2 departures, got %d", len(departures))
+	}
+
+	if departures[0]["service_number"] != "1234" || len(departures[0]["stations"].([]interface{})) != 2 {
+		t.Errorf("Expected service 1234 at both stations, got %v", departures[0])
+	}
+
+	// Only the departure from ASD matches the platform filter:
+	departures = getDeparturesStations(t, "ut,asd", "platform=7")
+
+	if len(departures) != 2 {
+		t.Fatalf("Expected 2 departures on platform 7, got %d", len(departures))
+	}
+
+	stations := departures[0]["stations"].([]interface{})
+
+	if departures[0]["service_number"] != "1234" || len(stations) != 1 || stations[0] != "ASD" {
+		t.Errorf("Expected service 1234 from ASD only, got %v", departures[0])
+	}
+
+	departures = getDeparturesStations(t, "ut,asd", "limit=1")
+
+	if len(departures) != 1 || departures[0]["service_number"] != "1234" {
+		t.Errorf("Expected only the first departure, got %v", departures)
+	}
+}
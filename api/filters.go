@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// boardFilter filters the departures or arrivals on a station board
+type boardFilter struct {
+	from  time.Time
+	until time.Time
+
+	companies map[string]bool
+	typeCodes map[string]bool
+	lines     map[string]bool
+	platforms map[string]bool
+	stations  map[string]bool
+
+	cancelledOnly bool
+	delayedOver   int
+	limit         int
+}
+
+// boardItem contains the fields of a departure or arrival which can be filtered on
+type boardItem struct {
+	time      time.Time
+	company   string
+	typeCode  string
+	line      string
+	platform  string
+	stations  []string
+	cancelled bool
+	delay     int
+}
+
+// parseBoardFilter parses the filter query parameters. Times (from and until) are either RFC 3339
+// timestamps or a number of minutes relative to the current time. The station parameter is the
+// name of the destination (departures) or origin (arrivals) parameter.
+func parseBoardFilter(query url.Values, stationParameter string, currentTime time.Time) (boardFilter, error) {
+	filter := boardFilter{
+		companies:     parseListParameter(query.Get("company")),
+		typeCodes:     parseListParameter(query.Get("type")),
+		lines:         parseListParameter(query.Get("line")),
+		platforms:     parseListParameter(query.Get("platform")),
+		stations:      parseListParameter(query.Get(stationParameter)),
+		cancelledOnly: query.Get("cancelled") == "true",
+		delayedOver:   -1,
+	}
+
+	var err error
+
+	if filter.from, err = parseTimeParameter(query.Get("from"), currentTime); err != nil {
+		return filter, fmt.Errorf("invalid from: %v", err)
+	}
+
+	if filter.until, err = parseTimeParameter(query.Get("until"), currentTime); err != nil {
+		return filter, fmt.Errorf("invalid until: %v", err)
+	}
+
+	if value := query.Get("delayed_over"); value != "" {
+		if filter.delayedOver, err = strconv.Atoi(value); err != nil || filter.delayedOver < 0 {
+			return filter, fmt.Errorf("invalid delayed_over: %q", value)
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		if filter.limit, err = strconv.Atoi(value); err != nil || filter.limit < 0 {
+			return filter, fmt.Errorf("invalid limit: %q", value)
+		}
+	}
+
+	return filter, nil
+}
+
+// parseListParameter parses a comma separated list of (case insensitive) values
+func parseListParameter(value string) map[string]bool {
+	if value == "" {
+		return nil
+	}
+
+	values := make(map[string]bool)
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values[strings.ToUpper(item)] = true
+		}
+	}
+
+	return values
+}
+
+// parseTimeParameter parses an RFC 3339 timestamp or a number of minutes relative to the current time
+func parseTimeParameter(value string, currentTime time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if minutes, err := strconv.Atoi(value); err == nil {
+		return currentTime.Add(time.Duration(minutes) * time.Minute), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// matches checks whether an item passes all filters
+func (filter boardFilter) matches(item boardItem) bool {
+	if !filter.from.IsZero() && item.time.Before(filter.from) {
+		return false
+	}
+
+	if !filter.until.IsZero() && item.time.After(filter.until) {
+		return false
+	}
+
+	if !matchesList(filter.companies, item.company) ||
+		!matchesList(filter.typeCodes, item.typeCode) ||
+		!matchesList(filter.lines, item.line) ||
+		!matchesList(filter.platforms, item.platform) {
+		return false
+	}
+
+	if filter.stations != nil {
+		found := false
+
+		for _, station := range item.stations {
+			if filter.stations[strings.ToUpper(station)] {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if filter.cancelledOnly && !item.cancelled {
+		return false
+	}
+
+	if filter.delayedOver >= 0 && item.delay <= filter.delayedOver {
+		return false
+	}
+
+	return true
+}
+
+// matchesList checks whether a value is in the list, or whether the list is not set
+func matchesList(list map[string]bool, value string) bool {
+	return list == nil || list[strings.ToUpper(value)]
+}
+
+// filterDepartures returns the departures which pass the filter, up to the limit
+func (filter boardFilter) filterDepartures(departures []models.Departure) []models.Departure {
+	filtered := make([]models.Departure, 0, len(departures))
+
+	for _, departure := range departures {
+		if filter.limit > 0 && len(filtered) >= filter.limit {
+			break
+		}
+
+		item := boardItem{
+			time:      departure.RealDepartureTime(),
+			company:   departure.Company,
+			typeCode:  departure.ServiceTypeCode,
+			line:      departure.LineNumber,
+			platform:  departure.PlatformActual,
+			stations:  departure.ActualDestinationCodes(),
+			cancelled: departure.Cancelled,
+			delay:     departure.Delay,
+		}
+
+		if filter.matches(item) {
+			filtered = append(filtered, departure)
+		}
+	}
+
+	return filtered
+}
+
+// filterArrivals returns the arrivals which pass the filter, up to the limit
+func (filter boardFilter) filterArrivals(arrivals []models.Arrival) []models.Arrival {
+	filtered := make([]models.Arrival, 0, len(arrivals))
+
+	for _, arrival := range arrivals {
+		if filter.limit > 0 && len(filtered) >= filter.limit {
+			break
+		}
+
+		item := boardItem{
+			time:      arrival.RealArrivalTime(),
+			company:   arrival.Company,
+			typeCode:  arrival.ServiceTypeCode,
+			line:      arrival.LineNumber,
+			platform:  arrival.PlatformActual,
+			stations:  arrival.ActualOriginCodes(),
+			cancelled: arrival.Cancelled,
+			delay:     arrival.Delay,
+		}
+
+		if filter.matches(item) {
+			filtered = append(filtered, arrival)
+		}
+	}
+
+	return filtered
+}
+
+// badRequest writes an error response for invalid parameters
+func badRequest(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
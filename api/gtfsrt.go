@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/rijdendetreinen/gotrain/gtfsrt"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+func gtfsTripUpdates(w http.ResponseWriter, r *http.Request) {
+	services := stores.Stores.ServiceStore.GetServices(false)
+	feed := gtfsrt.TripUpdates(services, gtfsrt.MappingFromConfig(), time.Now())
+
+	writeFeed(w, r, feed)
+}
+
+func gtfsAlerts(w http.ResponseWriter, r *http.Request) {
+	services := stores.Stores.ServiceStore.GetServices(false)
+	departures := stores.Stores.DepartureStore.GetDepartures(false)
+	feed := gtfsrt.Alerts(services, departures, gtfsrt.MappingFromConfig(), time.Now())
+
+	writeFeed(w, r, feed)
+}
+
+// writeFeed writes a GTFS-Realtime feed as protobuf, or as text when the debug parameter is set
+func writeFeed(w http.ResponseWriter, r *http.Request, feed *gtfs.FeedMessage) {
+	var data []byte
+	var err error
+
+	if getBooleanQueryParameter(r.URL, "debug", false) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		data, err = prototext.MarshalOptions{Multiline: true}.Marshal(feed)
+	} else {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		data, err = proto.Marshal(feed)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
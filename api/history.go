@@ -0,0 +1,32 @@
+package api
+
+import (
+	"github.com/rijdendetreinen/gotrain/serializers"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+// historyToJSON generates an interface (convertible to JSON) with the history of an item
+func historyToJSON(history []stores.HistoryEntry) []interface{} {
+	response := []interface{}{}
+
+	for _, entry := range history {
+		changes := []interface{}{}
+
+		for _, change := range entry.Changes {
+			changes = append(changes, map[string]interface{}{
+				"field":   change.Field,
+				"station": serializers.NullString(change.Station),
+				"old":     serializers.NullString(change.Old),
+				"new":     serializers.NullString(change.New),
+			})
+		}
+
+		response = append(response, map[string]interface{}{
+			"timestamp":  entry.Timestamp,
+			"product_id": entry.ProductID,
+			"changes":    changes,
+		})
+	}
+
+	return response
+}
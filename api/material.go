@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/serializers"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+func materialDetails(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Normalize the number the same way as the material numbers in the index:
+	number := models.Material{Number: mux.Vars(r)["number"]}.NormalizedNumber()
+
+	if number == nil || stores.Materials == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(nil)
+		return
+	}
+
+	rotation := stores.Materials.GetRotation(*number)
+
+	if len(rotation) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(nil)
+		return
+	}
+
+	current, next := stores.Materials.GetCurrentAndNext(*number, time.Now())
+
+	rotationResponse := []interface{}{}
+
+	for _, day := range rotation {
+		services := []interface{}{}
+
+		for _, service := range day.Services {
+			services = append(services, materialServiceToJSON(&service))
+		}
+
+		rotationResponse = append(rotationResponse, map[string]interface{}{
+			"service_date": day.ServiceDate,
+			"services":     services,
+		})
+	}
+
+	response := map[string]interface{}{
+		"number":   *number,
+		"current":  materialServiceToJSON(current),
+		"next":     materialServiceToJSON(next),
+		"rotation": rotationResponse,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wrapServicesStatus("material", response))
+}
+
+func materialServiceToJSON(service *stores.MaterialService) map[string]interface{} {
+	if service == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"service_date":   service.ServiceDate,
+		"service_number": service.ServiceNumber,
+		"type":           service.ServiceType,
+		"company":        service.Company,
+		"material_type":  serializers.NullString(service.MaterialType),
+		"from":           service.From,
+		"to":             service.To,
+		"departure_time": serializers.LocalTimeString(service.DepartureTime),
+		"arrival_time":   serializers.LocalTimeString(service.ArrivalTime),
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rijdendetreinen/gotrain/receiver"
 	"github.com/rijdendetreinen/gotrain/stores"
 
 	"github.com/gorilla/mux"
@@ -53,17 +54,42 @@ func ServeAPI(address string, exit chan bool) {
 	router.HandleFunc("/v2", apiVersion).Methods("GET")
 	router.HandleFunc("/v2/version", apiVersion).Methods("GET")
 	router.HandleFunc("/v2/status", apiStatus).Methods("GET")
+	router.HandleFunc("/v2/deadletters", deadLetters).Methods("GET")
 
 	router.HandleFunc("/v2/arrivals/stats", arrivalCounters).Methods("GET")
 	router.HandleFunc("/v2/arrivals/station/{station}", arrivalsStation).Methods("GET")
+	router.HandleFunc("/v2/arrivals/station/{station}/stream", arrivalsStationStream).Methods("GET")
 	router.HandleFunc("/v2/arrivals/arrival/{id}/{station}/{date}", arrivalDetails).Methods("GET")
 
 	router.HandleFunc("/v2/departures/stats", departureCounters).Methods("GET")
 	router.HandleFunc("/v2/departures/station/{station}", departuresStation).Methods("GET")
+	router.HandleFunc("/v2/departures/station/{station}/stream", departuresStationStream).Methods("GET")
+	router.HandleFunc("/v2/departures/stations/{stations}", departuresStations).Methods("GET")
 	router.HandleFunc("/v2/departures/departure/{id}/{station}/{date}", departureDetails).Methods("GET")
 
+	router.HandleFunc("/v2/connections/{from}/{to}", connections).Methods("GET")
+
 	router.HandleFunc("/v2/services/stats", serviceCounters).Methods("GET")
 	router.HandleFunc("/v2/services/service/{id}/{date}", serviceDetails).Methods("GET")
+	router.HandleFunc("/v2/services/service/{id}/{date}/stream", serviceDetailsStream).Methods("GET")
+	router.HandleFunc("/v2/services/number/{number}", servicesNumber).Methods("GET")
+	router.HandleFunc("/v2/services/number/{number}/current", servicesNumberCurrent).Methods("GET")
+
+	router.HandleFunc("/v2/ws", websocketSubscriptions).Methods("GET")
+
+	router.HandleFunc("/v2/material/{number}", materialDetails).Methods("GET")
+
+	router.HandleFunc("/v2/stations", stationsList).Methods("GET")
+	router.HandleFunc("/v2/stations/{code}", stationDetails).Methods("GET")
+
+	router.HandleFunc("/gtfs-rt/tripupdates", gtfsTripUpdates).Methods("GET")
+	router.HandleFunc("/gtfs-rt/alerts", gtfsAlerts).Methods("GET")
+
+	router.HandleFunc("/siri/et", siriEstimatedTimetable).Methods("GET")
+	router.HandleFunc("/siri/sm/{station}", siriStopMonitoring).Methods("GET")
+
+	changeHub = stores.NewHub(&stores.Stores)
+	changeHub.Start()
 
 	router.Use(prometheusMiddleware)
 	srv.Handler = router
@@ -74,6 +100,7 @@ func ServeAPI(address string, exit chan bool) {
 	<-exit
 	log.Info().Msg("Shutting down REST API")
 	srv.Close()
+	changeHub.Stop()
 	log.Info().Msg("REST API shut down")
 	exit <- true
 }
@@ -96,10 +123,11 @@ func apiVersion(w http.ResponseWriter, r *http.Request) {
 }
 
 func apiStatus(w http.ResponseWriter, r *http.Request) {
-	version := map[string]string{
+	version := map[string]interface{}{
 		"arrivals":   stores.Stores.ArrivalStore.Status,
 		"departures": stores.Stores.DepartureStore.Status,
 		"services":   stores.Stores.ServiceStore.Status,
+		"source":     receiver.GetSourceStatus(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
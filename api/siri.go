@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rijdendetreinen/gotrain/siri"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+func siriEstimatedTimetable(w http.ResponseWriter, r *http.Request) {
+	services := stores.Stores.ServiceStore.GetServices(false)
+
+	writeSiri(w, siri.EstimatedTimetable(services, time.Now()))
+}
+
+func siriStopMonitoring(w http.ResponseWriter, r *http.Request) {
+	station := mux.Vars(r)["station"]
+
+	departures := stores.Stores.DepartureStore.GetStationDepartures(station, false)
+	arrivals := stores.Stores.ArrivalStore.GetStationArrivals(station, false)
+
+	writeSiri(w, siri.StopMonitoring(station, departures, arrivals, time.Now()))
+}
+
+// writeSiri writes a SIRI document as XML
+func writeSiri(w http.ResponseWriter, document *siri.Siri) {
+	data, err := siri.Marshal(document)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
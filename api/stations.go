@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rijdendetreinen/gotrain/serializers"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+func stationsList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := []interface{}{}
+
+	for _, station := range stores.Stores.StationStore.SearchStations(r.URL.Query().Get("q")) {
+		response = append(response, stationToJSON(station))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"stations": response,
+	})
+}
+
+func stationDetails(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	station := stores.Stores.StationStore.GetStation(mux.Vars(r)["code"])
+
+	if station == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"station": stationToJSON(*station),
+	})
+}
+
+func stationToJSON(station stores.StationDetails) map[string]interface{} {
+	response := map[string]interface{}{
+		"code":                 station.Code,
+		"short":                serializers.NullString(station.NameShort),
+		"medium":               serializers.NullString(station.NameMedium),
+		"long":                 serializers.NullString(station.NameLong),
+		"accessible":           nil,
+		"assistance_available": nil,
+	}
+
+	if station.AccessibilityKnown {
+		response["accessible"] = station.Accessible
+		response["assistance_available"] = station.AssistanceAvailable
+	}
+
+	return response
+}
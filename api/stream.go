@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/serializers"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/rs/zerolog/log"
+)
+
+// streamBufferSize is the number of events which may be queued for a single client. Clients which
+// fall further behind are disconnected, and receive a new snapshot when they reconnect.
+const streamBufferSize = 100
+
+// streamKeepAlive is the interval for sending a comment to keep idle connections open
+const streamKeepAlive = 30 * time.Second
+
+// streamEvent is a single Server-Sent Event
+type streamEvent struct {
+	name string
+	data interface{}
+}
+
+// streamChanges sends a snapshot followed by an event for every relevant change in the store, until the
+// client disconnects. The filter converts a change to an event, and returns false for irrelevant changes.
+func streamChanges(w http.ResponseWriter, r *http.Request, store *stores.Store, snapshot func() streamEvent, filter func(stores.Change) (streamEvent, bool)) {
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events := make(chan streamEvent, streamBufferSize)
+	overflow := make(chan struct{})
+	var overflowOnce sync.Once
+
+	// Subscribe before creating the snapshot, so no changes are missed:
+	listenerID := store.AddListener(func(change stores.Change) {
+		event, relevant := filter(change)
+
+		if !relevant {
+			return
+		}
+
+		select {
+		case events <- event:
+		default:
+			overflowOnce.Do(func() { close(overflow) })
+		}
+	})
+
+	defer store.RemoveListener(listenerID)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeStreamEvent(w, snapshot()); err != nil {
+		return
+	}
+
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-overflow:
+			log.Warn().Str("path", r.URL.Path).Msg("Stream client too slow, disconnecting")
+			return
+
+		case event := <-events:
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+
+			flusher.Flush()
+		}
+	}
+}
+
+// writeStreamEvent writes a single event in the Server-Sent Events format
+func writeStreamEvent(w http.ResponseWriter, event streamEvent) error {
+	data, err := json.Marshal(event.data)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name, data)
+
+	return err
+}
+
+// changeEvent converts a store change to an update event, or a remove event when an item is hidden or removed
+func changeEvent(change stores.Change, language string, verbose bool) streamEvent {
+	if change.Action == stores.ChangeUpdated {
+		switch item := change.Item.(type) {
+		case models.Departure:
+			if !item.Hidden {
+				return streamEvent{"update", serializers.DepartureToJSON(item, language, verbose, nil)}
+			}
+		case models.Arrival:
+			if !item.Hidden {
+				return streamEvent{"update", serializers.ArrivalToJSON(item, language)}
+			}
+		case models.Service:
+			if !item.Hidden {
+				return streamEvent{"update", serializers.ServiceToJSON(item, language, verbose)}
+			}
+		}
+	}
+
+	return streamEvent{"remove", map[string]interface{}{
+		"id":      change.ID,
+		"station": serializers.NullString(change.Station),
+	}}
+}
+
+// departuresSnapshot returns the current departures for a station
+func departuresSnapshot(station, language string, verbose bool) map[string]interface{} {
+	departures := stores.Stores.DepartureStore.GetStationDepartures(station, false)
+	sortDepartures(departures)
+
+	return wrapDeparturesStatus("departures", departuresToJSON(departures, language, verbose))
+}
+
+// arrivalsSnapshot returns the current arrivals for a station
+func arrivalsSnapshot(station, language string) map[string]interface{} {
+	arrivals := stores.Stores.ArrivalStore.GetStationArrivals(station, false)
+	sortArrivals(arrivals)
+
+	return wrapArrivalsStatus("arrivals", arrivalsToJSON(arrivals, language))
+}
+
+// serviceSnapshot returns the current service details, or a nil service when the service is unknown
+func serviceSnapshot(serviceID, serviceDate, language string, verbose bool) map[string]interface{} {
+	service := stores.Stores.ServiceStore.GetService(serviceID, serviceDate)
+
+	if service == nil {
+		return wrapServicesStatus("service", nil)
+	}
+
+	return wrapServicesStatus("service", serializers.ServiceToJSON(*service, language, verbose))
+}
+
+func departuresStationStream(w http.ResponseWriter, r *http.Request) {
+	station := mux.Vars(r)["station"]
+	language := getLanguageVar(r.URL)
+	verbose := getBooleanQueryParameter(r.URL, "verbose", false)
+
+	snapshot := func() streamEvent {
+		return streamEvent{"snapshot", departuresSnapshot(station, language, verbose)}
+	}
+
+	filter := func(change stores.Change) (streamEvent, bool) {
+		if change.Station != station {
+			return streamEvent{}, false
+		}
+
+		return changeEvent(change, language, verbose), true
+	}
+
+	streamChanges(w, r, &stores.Stores.DepartureStore.Store, snapshot, filter)
+}
+
+func arrivalsStationStream(w http.ResponseWriter, r *http.Request) {
+	station := mux.Vars(r)["station"]
+	language := getLanguageVar(r.URL)
+
+	snapshot := func() streamEvent {
+		return streamEvent{"snapshot", arrivalsSnapshot(station, language)}
+	}
+
+	filter := func(change stores.Change) (streamEvent, bool) {
+		if change.Station != station {
+			return streamEvent{}, false
+		}
+
+		return changeEvent(change, language, false), true
+	}
+
+	streamChanges(w, r, &stores.Stores.ArrivalStore.Store, snapshot, filter)
+}
+
+func serviceDetailsStream(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	serviceID := vars["id"]
+	serviceDate := vars["date"]
+	language := getLanguageVar(r.URL)
+	verbose := getBooleanQueryParameter(r.URL, "verbose", false)
+
+	id := serviceDate + "-" + serviceID
+
+	snapshot := func() streamEvent {
+		return streamEvent{"snapshot", serviceSnapshot(serviceID, serviceDate, language, verbose)}
+	}
+
+	filter := func(change stores.Change) (streamEvent, bool) {
+		if change.ID != id {
+			return streamEvent{}, false
+		}
+
+		return changeEvent(change, language, verbose), true
+	}
+
+	streamChanges(w, r, &stores.Stores.ServiceStore.Store, snapshot, filter)
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/rs/zerolog/log"
+)
+
+// changeHub distributes store changes to WebSocket clients
+var changeHub *stores.Hub
+
+// websocketBufferSize is the number of changes which may be queued for a single WebSocket client
+const websocketBufferSize = 1000
+
+// websocketPingInterval is the interval for sending pings to detect broken connections. A connection
+// is closed when nothing (not even a pong) has been received within websocketReadTimeout.
+const (
+	websocketPingInterval = 30 * time.Second
+	websocketReadTimeout  = 2 * websocketPingInterval
+)
+
+// websocketReadLimit is the maximum size of a request in bytes
+const websocketReadLimit = 4096
+
+// websocketMaxSubscriptions is the maximum number of subscriptions per connection
+const websocketMaxSubscriptions = 100
+
+var upgrader = websocket.Upgrader{
+	// The API is public, so connections from any origin are allowed:
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// websocketRequest is a request from a client to subscribe to or unsubscribe from
+// the departures or arrivals for a station, or a single service
+type websocketRequest struct {
+	Action  string `json:"action"`
+	Type    string `json:"type"`
+	Station string `json:"station,omitempty"`
+	ID      string `json:"id,omitempty"`
+	Date    string `json:"date,omitempty"`
+
+	err error
+}
+
+// topic returns the hub topic for this request
+func (request websocketRequest) topic() (stores.Topic, bool) {
+	switch request.Type {
+	case stores.TopicDepartures, stores.TopicArrivals:
+		return stores.Topic{Kind: request.Type, Key: request.Station}, request.Station != ""
+	case stores.TopicServices:
+		return stores.Topic{Kind: request.Type, Key: request.Date + "-" + request.ID}, request.ID != "" && request.Date != ""
+	}
+
+	return stores.Topic{}, false
+}
+
+// snapshot returns the current data for the subscription
+func (request websocketRequest) snapshot(language string, verbose bool) map[string]interface{} {
+	switch request.Type {
+	case stores.TopicDepartures:
+		return departuresSnapshot(request.Station, language, verbose)
+	case stores.TopicArrivals:
+		return arrivalsSnapshot(request.Station, language)
+	default:
+		return serviceSnapshot(request.ID, request.Date, language, verbose)
+	}
+}
+
+// websocketMessage creates a message for the client, identifying the subscription it belongs to
+func websocketMessage(event string, request websocketRequest, data interface{}) map[string]interface{} {
+	message := map[string]interface{}{
+		"event": event,
+		"type":  request.Type,
+		"data":  data,
+	}
+
+	if request.Type == stores.TopicServices {
+		message["id"] = request.ID
+		message["date"] = request.Date
+	} else {
+		message["station"] = request.Station
+	}
+
+	return message
+}
+
+// websocketError creates an error message for the client
+func websocketError(request websocketRequest, reason string) map[string]interface{} {
+	return map[string]interface{}{
+		"event":   "error",
+		"request": request,
+		"error":   reason,
+	}
+}
+
+// isSubscribed checks whether a connection is already subscribed to a topic
+func isSubscribed(subscriptions map[stores.Topic]websocketRequest, topic stores.Topic) bool {
+	_, subscribed := subscriptions[topic]
+	return subscribed
+}
+
+func websocketSubscriptions(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+
+	if err != nil {
+		log.Debug().Err(err).Msg("WebSocket upgrade failed")
+		return
+	}
+
+	defer conn.Close()
+
+	language := getLanguageVar(r.URL)
+	verbose := getBooleanQueryParameter(r.URL, "verbose", false)
+
+	client := changeHub.NewClient(websocketBufferSize)
+	defer client.Close()
+
+	subscriptions := make(map[stores.Topic]websocketRequest)
+
+	// Only this goroutine writes to the connection; requests are read in a separate goroutine:
+	requests := make(chan websocketRequest)
+	closed := make(chan struct{})
+	defer close(closed)
+
+	conn.SetReadLimit(websocketReadLimit)
+	conn.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+	})
+
+	go func() {
+		defer close(requests)
+
+		for {
+			_, data, err := conn.ReadMessage()
+
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					log.Debug().Err(err).Msg("WebSocket read error")
+				}
+				return
+			}
+
+			conn.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+
+			var request websocketRequest
+			request.err = json.Unmarshal(data, &request)
+
+			select {
+			case requests <- request:
+			case <-closed:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(websocketPingInterval)
+	defer ping.Stop()
+
+	for {
+		var message interface{}
+
+		select {
+		case request, ok := <-requests:
+			if !ok {
+				return
+			}
+
+			topic, valid := request.topic()
+
+			switch {
+			case request.err != nil:
+				message = websocketError(request, "invalid request: "+request.err.Error())
+
+			case !valid:
+				message = websocketError(request, "invalid subscription")
+
+			case request.Action == "subscribe" && !isSubscribed(subscriptions, topic) && len(subscriptions) >= websocketMaxSubscriptions:
+				message = websocketError(request, "too many subscriptions")
+
+			case request.Action == "subscribe":
+				// Subscribe before creating the snapshot, so no changes are missed:
+				client.Subscribe(topic)
+				subscriptions[topic] = request
+
+				message = websocketMessage("snapshot", request, request.snapshot(language, verbose))
+
+			case request.Action == "unsubscribe":
+				client.Unsubscribe(topic)
+				delete(subscriptions, topic)
+
+				message = websocketMessage("unsubscribed", request, nil)
+
+			default:
+				message = websocketError(request, "unknown action")
+			}
+
+		case change := <-client.Changes():
+			request, subscribed := subscriptions[change.Topic]
+
+			if !subscribed {
+				continue
+			}
+
+			event := changeEvent(change.Change, language, verbose)
+			message = websocketMessage(event.name, request, event.data)
+
+		case <-client.Done():
+			log.Warn().Msg("WebSocket client too slow, disconnecting")
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+			return
+
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+			continue
+		}
+
+		if err := conn.WriteJSON(message); err != nil {
+			return
+		}
+	}
+}
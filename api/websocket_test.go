@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+func TestWebsocketMaxSubscriptions(t *testing.T) {
+	stores.InitializeStores()
+	changeHub = stores.NewHub(&stores.Stores)
+
+	server := httptest.NewServer(http.HandlerFunc(websocketSubscriptions))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	var message map[string]interface{}
+
+	for i := 0; i <= websocketMaxSubscriptions; i++ {
+		request := websocketRequest{Action: "subscribe", Type: stores.TopicDepartures, Station: strconv.Itoa(i)}
+
+		if err := conn.WriteJSON(request); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+
+		if i < websocketMaxSubscriptions && message["event"] != "snapshot" {
+			t.Fatalf("Subscription %d should be accepted: %v", i, message)
+		}
+	}
+
+	if message["event"] != "error" || message["error"] != "too many subscriptions" {
+		t.Errorf("Subscription above the maximum should be refused: %v", message)
+	}
+}
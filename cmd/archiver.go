@@ -55,6 +55,10 @@ func startArchiver(cmd *cobra.Command) {
 	receiver.ProcessStores = false
 	receiver.ArchiveServices = true
 
+	if err := receiver.SetupDeadLetters(); err != nil {
+		log.Error().Err(err).Msg("Could not set up dead letter capture")
+	}
+
 	go receiver.ReceiveData(exitArchiverReceiverChannel)
 
 	<-shutdownArchiverFinished
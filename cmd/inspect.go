@@ -7,8 +7,10 @@ import (
 
 	"github.com/rijdendetreinen/gotrain/models"
 	"github.com/rijdendetreinen/gotrain/parsers"
+	"github.com/rijdendetreinen/gotrain/receiver"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var inspectCommand = &cobra.Command{
@@ -266,6 +268,86 @@ var inspectServiceCommand = &cobra.Command{
 	},
 }
 
+var inspectDeadLetterCommand = &cobra.Command{
+	Use:   "deadletter [file or directory...]",
+	Short: "Re-parse dead letters",
+	Long: `Re-run the parser on messages which were captured in the dead letter directory because they
+could not be decompressed or parsed. Without arguments, all files in deadletter.directory are checked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		remove, _ := cmd.Flags().GetBool("remove")
+
+		if len(args) == 0 {
+			directory := viper.GetString("deadletter.directory")
+
+			if directory == "" {
+				fmt.Println("No dead letter files specified and deadletter.directory is not configured")
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			args = []string{directory}
+		}
+
+		var filenames []string
+
+		for _, arg := range args {
+			if info, err := os.Stat(arg); err == nil && info.IsDir() {
+				files, err := receiver.ListDeadLetters(arg)
+
+				if err != nil {
+					log.Error().Err(err).Str("directory", arg).Msg("Error reading dead letter directory")
+					os.Exit(1)
+				}
+
+				filenames = append(filenames, files...)
+			} else {
+				filenames = append(filenames, arg)
+			}
+		}
+
+		failed := 0
+
+		for _, filename := range filenames {
+			letter, err := receiver.ReadDeadLetter(filename)
+
+			if err != nil {
+				fmt.Printf("%s: could not read dead letter: %s\n", filename, err)
+				failed++
+				continue
+			}
+
+			messageType, item, err := letter.Reparse()
+
+			if err != nil {
+				fmt.Printf("%s: %s FAILED: %s (original error: %s)\n", filename, messageType, err, letter.Error)
+				failed++
+				continue
+			}
+
+			switch item := item.(type) {
+			case models.Departure:
+				fmt.Printf("%s: OK departure %s (product %s)\n", filename, item.ID, item.ProductID)
+			case models.Arrival:
+				fmt.Printf("%s: OK arrival %s (product %s)\n", filename, item.ID, item.ProductID)
+			case models.Service:
+				fmt.Printf("%s: OK service %s (product %s)\n", filename, item.ID, item.ProductID)
+			}
+
+			if remove {
+				if err := os.Remove(filename); err != nil {
+					log.Error().Err(err).Str("file", filename).Msg("Could not remove dead letter")
+				}
+			}
+		}
+
+		fmt.Printf("%d dead letter(s), %d still failing\n", len(filenames), failed)
+
+		if failed > 0 {
+			os.Exit(2)
+		}
+	},
+}
+
 func displayModifications(modifications []models.Modification, level int, showModifications bool, language string) {
 	if showModifications {
 		if len(modifications) == 0 {
@@ -312,6 +394,7 @@ func init() {
 	inspectCommand.AddCommand(inspectDepartureCommand)
 	inspectCommand.AddCommand(inspectServiceCommand)
 	inspectCommand.AddCommand(inspectArrivalCommand)
+	inspectCommand.AddCommand(inspectDeadLetterCommand)
 
 	inspectDepartureCommand.Flags().BoolP("modifications", "m", false, "Show modifications")
 	inspectDepartureCommand.Flags().BoolP("stops", "s", false, "Show stops")
@@ -323,4 +406,6 @@ func init() {
 
 	inspectArrivalCommand.Flags().BoolP("modifications", "m", false, "Show modifications and tips")
 	inspectArrivalCommand.Flags().StringP("language", "l", "nl", "Language")
+
+	inspectDeadLetterCommand.Flags().BoolP("remove", "r", false, "Remove dead letters which can be parsed now")
 }
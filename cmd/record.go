@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/receiver"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var recordCommand = &cobra.Command{
+	Use:   "record",
+	Short: "Record raw messages",
+	Long: `Record all raw messages received from the ZMQ server to rotating capture files.
+The capture files can be fed back into GoTrain with the replay command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		startRecorder(cmd)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(recordCommand)
+
+	recordCommand.Flags().StringP("directory", "d", "", "Capture directory (default: record.directory or captures/)")
+	recordCommand.Flags().DurationP("rotate", "r", time.Hour, "Start a new capture file after this interval (0 to disable)")
+	recordCommand.Flags().Int64P("max-size", "m", 0, "Start a new capture file after this many megabytes (0 to disable)")
+}
+
+var exitRecorderChannel = make(chan bool)
+
+func startRecorder(cmd *cobra.Command) {
+	initLogger(cmd)
+
+	log.Info().Msgf("GoTrain recorder %v starting", Version.VersionStringLong())
+
+	directory, _ := cmd.Flags().GetString("directory")
+	rotateInterval, _ := cmd.Flags().GetDuration("rotate")
+	maxSize, _ := cmd.Flags().GetInt64("max-size")
+
+	if directory == "" {
+		directory = viper.GetString("record.directory")
+	}
+
+	if directory == "" {
+		directory = "captures/"
+	}
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		log.Error().Err(err).Str("directory", directory).Msg("Could not create capture directory")
+		return
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	shutdownRecorderFinished := make(chan struct{})
+
+	signal.Notify(signalChan, os.Interrupt)
+	signal.Notify(signalChan, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signalChan
+		log.Warn().Msgf("Received signal: %+v, shutting down", sig)
+		signal.Reset()
+
+		exitRecorderChannel <- true
+		<-exitRecorderChannel
+
+		close(shutdownRecorderFinished)
+	}()
+
+	// Record the same envelopes as the server does:
+	receiver.ProcessStores = true
+	receiver.ArchiveServices = false
+
+	writer := receiver.NewCaptureWriter(directory, rotateInterval, maxSize*1024*1024)
+
+	go receiver.Record(writer, exitRecorderChannel)
+
+	<-shutdownRecorderFinished
+	log.Warn().Msg("Exiting")
+}
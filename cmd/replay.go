@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/rijdendetreinen/gotrain/api"
+	"github.com/rijdendetreinen/gotrain/receiver"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var replayCommand = &cobra.Command{
+	Use:   "replay [capture files]",
+	Short: "Replay recorded messages",
+	Long: `Replay capture files created by the record command. All messages are processed exactly like
+the server does, so the REST API can be used to inspect the result or for load testing.
+
+Use --speed to set the pacing: 1 replays in real-time, 10 replays 10 times as fast
+and 0 replays as fast as possible.
+
+With --load the saved store contents are read first. The replayed messages are only kept in
+memory, the saved stores are not changed.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		startReplay(cmd, args)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(replayCommand)
+
+	replayCommand.Flags().Float64P("speed", "s", 1, "Replay speed (1 = real-time, 0 = as fast as possible)")
+	replayCommand.Flags().Bool("api", true, "Start the REST API while replaying")
+	replayCommand.Flags().Bool("load", false, "Load saved store contents before replaying (read-only)")
+	replayCommand.Flags().Bool("exit", false, "Exit when all messages have been replayed")
+}
+
+func startReplay(cmd *cobra.Command, filenames []string) {
+	initLogger(cmd)
+
+	log.Info().Msgf("GoTrain %v replaying", Version.VersionStringLong())
+
+	speed, _ := cmd.Flags().GetFloat64("speed")
+	startAPI, _ := cmd.Flags().GetBool("api")
+	loadStores, _ := cmd.Flags().GetBool("load")
+	exitWhenFinished, _ := cmd.Flags().GetBool("exit")
+
+	// Capture file names contain the start time, so sorting gives the right order:
+	sort.Strings(filenames)
+
+	if loadStores {
+		initStores()
+	} else {
+		stores.InitializeStores()
+		startMaterialIndex()
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	stopReplay := make(chan struct{})
+	replayFinished := make(chan struct{})
+
+	signal.Notify(signalChan, os.Interrupt)
+	signal.Notify(signalChan, syscall.SIGTERM)
+
+	if startAPI {
+		go api.ServeAPI(viper.GetString("api.address"), exitRestAPI)
+	}
+
+	receiver.ProcessStores = true
+	receiver.ArchiveServices = false
+
+	go func() {
+		err := receiver.Replay(filenames, speed, stopReplay)
+
+		if err != nil {
+			log.Error().Err(err).Msg("Error while replaying")
+		}
+
+		log.Info().Msgf("Current inventory: %d arrivals, %d departures, %d services, %d stations",
+			stores.Stores.ArrivalStore.GetNumberOfArrivals(),
+			stores.Stores.DepartureStore.GetNumberOfDepartures(),
+			stores.Stores.ServiceStore.GetNumberOfServices(),
+			stores.Stores.StationStore.GetNumberOfStations())
+
+		close(replayFinished)
+	}()
+
+	select {
+	case sig := <-signalChan:
+		log.Warn().Msgf("Received signal: %+v, shutting down", sig)
+		close(stopReplay)
+		<-replayFinished
+	case <-replayFinished:
+		if startAPI && !exitWhenFinished {
+			log.Info().Msg("Replay finished, REST API keeps running until interrupted")
+
+			sig := <-signalChan
+			log.Warn().Msgf("Received signal: %+v, shutting down", sig)
+		}
+	}
+
+	signal.Reset()
+
+	if startAPI {
+		exitRestAPI <- true
+		<-exitRestAPI
+	}
+
+	stores.Materials.Stop()
+
+	log.Warn().Msg("Exiting")
+}
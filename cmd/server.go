@@ -3,13 +3,18 @@ package cmd
 import (
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/rijdendetreinen/gotrain/api"
+	"github.com/rijdendetreinen/gotrain/events"
+	"github.com/rijdendetreinen/gotrain/mqtt"
 	"github.com/rijdendetreinen/gotrain/prometheus_interface"
 	"github.com/rijdendetreinen/gotrain/receiver"
+	"github.com/rijdendetreinen/gotrain/relay"
 	"github.com/rijdendetreinen/gotrain/stores"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -34,6 +39,10 @@ var exitRestAPI = make(chan bool)
 var cleanupTicker *time.Ticker
 var downtimeDetectorTicker *time.Ticker
 var autoSaveTicker *time.Ticker
+var eventPublisher *events.Publisher
+var mqttPublisher *mqtt.Publisher
+var zmqRelay *relay.Relay
+var configWatcher *fsnotify.Watcher
 
 func startServer(cmd *cobra.Command) {
 	initLogger(cmd)
@@ -50,6 +59,7 @@ func startServer(cmd *cobra.Command) {
 	signal.Notify(signalChan, syscall.SIGTERM)
 
 	initStores()
+	attachPersistence()
 
 	go func() {
 		sig := <-signalChan
@@ -62,6 +72,37 @@ func startServer(cmd *cobra.Command) {
 	receiver.ProcessStores = true
 	receiver.ArchiveServices = false
 
+	if err := receiver.SetupDeadLetters(); err != nil {
+		log.Error().Err(err).Msg("Could not set up dead letter capture")
+	}
+
+	publisher, err := events.SetupFromConfig(&stores.Stores)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Could not set up event webhooks")
+	} else if publisher != nil {
+		eventPublisher = publisher
+		eventPublisher.Start()
+	}
+
+	brokerPublisher, err := mqtt.SetupFromConfig(&stores.Stores)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Could not set up MQTT publisher")
+	} else if brokerPublisher != nil {
+		mqttPublisher = brokerPublisher
+		mqttPublisher.Start()
+	}
+
+	socketRelay, err := relay.SetupFromConfig(&stores.Stores)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Could not set up ZMQ relay")
+	} else if socketRelay != nil {
+		zmqRelay = socketRelay
+		zmqRelay.Start()
+	}
+
 	go receiver.ReceiveData(exitReceiverChannel)
 
 	apiAddress := viper.GetString("api.address")
@@ -75,6 +116,7 @@ func startServer(cmd *cobra.Command) {
 	setupCleanupScheduler()
 	setupDowntimeDetector()
 	setupAutoSave()
+	setupConfigReload()
 
 	<-shutdownFinished
 	log.Warn().Msg("Exiting")
@@ -104,26 +146,132 @@ func setupDowntimeDetector() {
 	}()
 }
 
+// Default and minimum interval for saving the stores
+const (
+	defaultSnapshotInterval = 12 * time.Hour
+	minSnapshotInterval     = 1 * time.Minute
+)
+
 func setupAutoSave() {
-	autoSaveTicker := time.NewTicker(12 * time.Hour)
-	log.Debug().Msg("Autosave set up")
+	interval := defaultSnapshotInterval
+
+	if viper.IsSet("stores.snapshot_interval") {
+		interval = viper.GetDuration("stores.snapshot_interval")
+
+		// Note that a number without unit is parsed as nanoseconds:
+		if interval <= 0 {
+			log.Error().Str("snapshot_interval", viper.GetString("stores.snapshot_interval")).Dur("default", defaultSnapshotInterval).
+				Msg("Invalid stores.snapshot_interval, using default")
+			interval = defaultSnapshotInterval
+		} else if interval < minSnapshotInterval {
+			log.Warn().Str("snapshot_interval", viper.GetString("stores.snapshot_interval")).Dur("minimum", minSnapshotInterval).
+				Msg("stores.snapshot_interval is too short (use a unit, e.g. 12h), using minimum")
+			interval = minSnapshotInterval
+		}
+	}
+
+	autoSaveTicker = time.NewTicker(interval)
+	log.Debug().Dur("interval", interval).Msg("Autosave set up")
 
 	go func() {
 		for {
 			<-autoSaveTicker.C
 			log.Info().Msg("Auto-saving stores")
-			log.Info().Msgf("Current inventory: %d arrivals, %d departures, %d services",
+			log.Info().Msgf("Current inventory: %d arrivals, %d departures, %d services, %d stations",
 				stores.Stores.ArrivalStore.GetNumberOfArrivals(),
 				stores.Stores.DepartureStore.GetNumberOfDepartures(),
-				stores.Stores.ServiceStore.GetNumberOfServices())
+				stores.Stores.ServiceStore.GetNumberOfServices(),
+				stores.Stores.StationStore.GetNumberOfStations())
 			stores.SaveStores()
 		}
 	}()
 }
 
+// setupConfigReload watches the configuration file and applies changed store policies without a
+// restart. Other settings are not reloaded.
+func setupConfigReload() {
+	file := viper.ConfigFileUsed()
+
+	if file == "" {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		log.Error().Err(err).Msg("Could not watch configuration file")
+		return
+	}
+
+	// Watch the directory, since editors often replace the file instead of writing to it:
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		log.Error().Err(err).Str("file", file).Msg("Could not watch configuration file")
+		watcher.Close()
+		return
+	}
+
+	configWatcher = watcher
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if filepath.Clean(event.Name) == filepath.Clean(file) && event.Has(fsnotify.Write|fsnotify.Create) {
+					reloadPolicies(file)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				log.Error().Err(err).Msg("Error while watching configuration file")
+			}
+		}
+	}()
+}
+
+// reloadPolicies applies the store policies of a changed configuration file. The file is read into
+// a separate configuration, since the global configuration is not safe for concurrent use. The
+// policies are only applied when all settings are valid.
+func reloadPolicies(file string) {
+	log.Info().Str("file", file).Msg("Configuration changed, reloading store policies")
+
+	config := viper.New()
+	config.SetConfigFile(file)
+	config.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	config.AutomaticEnv()
+
+	if err := config.ReadInConfig(); err != nil {
+		log.Error().Err(err).Msg("Could not read configuration file, keeping the current store policies")
+		return
+	}
+
+	policies, err := stores.ReadPolicies(config)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid store policies, keeping the current store policies")
+		return
+	}
+
+	stores.ApplyPolicies(policies)
+}
+
+// initStores initializes the stores and reads the saved store contents. The store backend and the
+// write-ahead log are closed again after reading, so changes are only kept in memory until
+// attachPersistence is called.
 func initStores() {
 	stores.InitializeStores()
 
+	if err := stores.ConfigurePolicies(); err != nil {
+		log.Error().Err(err).Msg("Invalid store policies, using the defaults")
+	}
+
+	stores.GoTrainVersion = Version.VersionStringShort()
+
 	if viper.IsSet("stores.location") {
 		stores.StoresDataDirectory = viper.GetString("stores.location")
 	}
@@ -133,9 +281,93 @@ func initStores() {
 	} else {
 		log.Info().Str("directory", stores.StoresDataDirectory).Msg("Data directory initialized")
 
+		stores.Stores.SetBackend(openStoreBackend())
+		stores.Stores.SetWAL(openWAL())
+
 		log.Info().Msg("Reading saved store contents...")
 		stores.LoadStores()
+
+		stores.CloseWAL()
+		stores.CloseBackend()
+		stores.Stores.SetWAL(nil)
+		stores.Stores.SetBackend(stores.MemoryBackend{})
 	}
+
+	startMaterialIndex()
+}
+
+// startMaterialIndex creates the material index for the (loaded) stores
+func startMaterialIndex() {
+	stores.Materials = stores.NewMaterialIndex(&stores.Stores)
+	stores.Materials.Start()
+}
+
+// attachPersistence opens the store backend and the write-ahead log, so every change is saved
+func attachPersistence() {
+	if _, err := os.Stat(stores.StoresDataDirectory); os.IsNotExist(err) {
+		return
+	}
+
+	backend := openStoreBackend()
+
+	if backend.Persistent() {
+		log.Info().Str("backend", viper.GetString("stores.backend")).Msg("Store backend attached")
+	}
+
+	stores.Stores.SetBackend(backend)
+
+	if wal := openWAL(); wal != nil {
+		log.Info().Str("directory", wal.Directory).Msg("Write-ahead log enabled")
+		stores.Stores.SetWAL(wal)
+	}
+}
+
+// openStoreBackend opens the configured store backend
+func openStoreBackend() stores.Backend {
+	name := viper.GetString("stores.backend")
+	path := filepath.Join(stores.StoresDataDirectory, "stores.db")
+
+	if viper.IsSet("stores.database") {
+		path = viper.GetString("stores.database")
+	}
+
+	backend, err := stores.OpenBackend(name, path)
+
+	if err != nil {
+		log.Fatal().Err(err).Str("backend", name).Msg("Could not open store backend")
+	}
+
+	log.Debug().Str("backend", name).Str("database", path).Msg("Store backend opened")
+
+	return backend
+}
+
+// openWAL opens the configured write-ahead log, or returns nil when it is not used
+func openWAL() *stores.WAL {
+	if !viper.GetBool("stores.wal") {
+		return nil
+	}
+
+	if viper.GetString("stores.backend") == stores.BackendBolt {
+		log.Info().Msg("Store backend saves every change; write-ahead log not used")
+		return nil
+	}
+
+	directory := filepath.Join(stores.StoresDataDirectory, "wal")
+
+	if viper.IsSet("stores.wal_directory") {
+		directory = viper.GetString("stores.wal_directory")
+	}
+
+	wal, err := stores.OpenWAL(directory)
+
+	if err != nil {
+		log.Fatal().Err(err).Str("directory", directory).Msg("Could not open write-ahead log")
+	}
+
+	log.Debug().Str("directory", directory).Msg("Write-ahead log opened")
+
+	return wal
 }
 
 func shutdown() {
@@ -153,12 +385,32 @@ func shutdown() {
 		autoSaveTicker.Stop()
 	}
 
+	if configWatcher != nil {
+		configWatcher.Close()
+	}
+
 	exitRestAPI <- true
 	exitReceiverChannel <- true
 
 	<-exitRestAPI
 	<-exitReceiverChannel
 
+	if eventPublisher != nil {
+		eventPublisher.Stop()
+	}
+
+	if mqttPublisher != nil {
+		mqttPublisher.Stop()
+	}
+
+	if zmqRelay != nil {
+		zmqRelay.Stop()
+	}
+
+	stores.Materials.Stop()
+
 	log.Info().Msg("Saving store contents...")
 	stores.SaveStores()
+	stores.CloseWAL()
+	stores.CloseBackend()
 }
@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var storeCommand = &cobra.Command{
+	Use:   "store",
+	Short: "Inspect and convert saved store contents",
+	Long: `Inspect and convert the saved departures, arrivals and services in the data directory.
+Use a sub-command to specify the action.`,
+}
+
+var storeDumpCommand = &cobra.Command{
+	Use:   "dump",
+	Short: "Dump saved store contents as JSON Lines",
+	Long: `Dump the saved departures, arrivals and services as JSON Lines (one item per line).
+The output can be converted back with the import command.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		initStoreDirectory(cmd)
+
+		types, _ := cmd.Flags().GetStringSlice("type")
+		station, _ := cmd.Flags().GetString("station")
+		service, _ := cmd.Flags().GetString("service")
+
+		files := readStoreFiles()
+		writer := bufio.NewWriter(os.Stdout)
+		encoder := json.NewEncoder(writer)
+
+		for _, record := range files.Records() {
+			if matchesDumpFilter(record, types, strings.ToUpper(station), service) {
+				encoder.Encode(record)
+			}
+		}
+
+		writer.Flush()
+	},
+}
+
+var storeStatsCommand = &cobra.Command{
+	Use:   "stats",
+	Short: "Show statistics of saved store contents",
+	Long:  `Show the number of saved departures, arrivals and services per service date, per station and hidden.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		initStoreDirectory(cmd)
+
+		for _, file := range stores.StoreFileNames() {
+			header, err := stores.ReadSnapshotHeader(file)
+
+			if err != nil {
+				fmt.Printf("%s: %s\n", file, err)
+				continue
+			}
+
+			if header.FormatVersion == 0 {
+				fmt.Printf("%s: legacy format\n", file)
+			} else {
+				fmt.Printf("%s: format %d, GoTrain %s, created %s, %d items\n", file, header.FormatVersion,
+					header.GoTrainVersion, header.Created.Local(), header.Items)
+			}
+		}
+
+		files := readStoreFiles()
+
+		departures := newStoreStats()
+		for _, departure := range files.Departures {
+			departures.add(departure.ServiceDate, departure.Station.Code, departure.Hidden)
+		}
+
+		arrivals := newStoreStats()
+		for _, arrival := range files.Arrivals {
+			arrivals.add(arrival.ServiceDate, arrival.Station.Code, arrival.Hidden)
+		}
+
+		services := newStoreStats()
+		for _, service := range files.Services {
+			services.add(service.ServiceDate, "", service.Hidden)
+		}
+
+		departures.print("Departures")
+		arrivals.print("Arrivals")
+		services.print("Services")
+	},
+}
+
+var storeImportCommand = &cobra.Command{
+	Use:   "import [filename]",
+	Short: "Import a JSON Lines dump into the saved store contents",
+	Long: `Import a JSON Lines dump created by the dump command (use - to read from stdin). The saved
+store files for the departures, arrivals and services in the dump are replaced.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initStoreDirectory(cmd)
+
+		var input io.Reader = os.Stdin
+
+		if args[0] != "-" {
+			input = openFile(args)
+		}
+
+		var files stores.StoreFiles
+
+		scanner := bufio.NewScanner(input)
+		scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+		line := 0
+
+		for scanner.Scan() {
+			line++
+
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+
+			var record stores.DumpRecord
+
+			err := json.Unmarshal(scanner.Bytes(), &record)
+
+			if err == nil {
+				err = files.Add(record)
+			}
+
+			if err != nil {
+				fmt.Printf("Line %d: %s\n", line, err)
+				os.Exit(2)
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			fmt.Println("Error while reading dump")
+			fmt.Println(err)
+			os.Exit(2)
+		}
+
+		if err := stores.WriteStoreFiles(files); err != nil {
+			fmt.Println("Error while writing store files")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Imported %d departures, %d arrivals and %d services into %s\n",
+			len(files.Departures), len(files.Arrivals), len(files.Services), stores.StoresDataDirectory)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(storeCommand)
+	storeCommand.AddCommand(storeDumpCommand)
+	storeCommand.AddCommand(storeStatsCommand)
+	storeCommand.AddCommand(storeImportCommand)
+
+	storeCommand.PersistentFlags().StringP("directory", "d", "", "Data directory (default: stores.location)")
+
+	storeDumpCommand.Flags().StringSliceP("type", "t", nil, "Only dump these types (departure, arrival, service)")
+	storeDumpCommand.Flags().StringP("station", "s", "", "Only dump items for this station")
+	storeDumpCommand.Flags().String("service", "", "Only dump items for this service number")
+}
+
+// initStoreDirectory sets the data directory from the command line or configuration
+func initStoreDirectory(cmd *cobra.Command) {
+	initLogger(cmd)
+
+	directory, _ := cmd.Flags().GetString("directory")
+
+	if directory == "" && viper.IsSet("stores.location") {
+		directory = viper.GetString("stores.location")
+	}
+
+	if directory != "" {
+		if !strings.HasSuffix(directory, "/") {
+			directory += "/"
+		}
+
+		stores.StoresDataDirectory = directory
+	}
+
+	stores.GoTrainVersion = Version.VersionStringShort()
+}
+
+func readStoreFiles() stores.StoreFiles {
+	files, err := stores.ReadStoreFiles()
+
+	if err != nil {
+		fmt.Println("Error while reading store files")
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	return files
+}
+
+// matchesDumpFilter returns true when a record matches the type, station and service number filters
+func matchesDumpFilter(record stores.DumpRecord, types []string, station, serviceNumber string) bool {
+	if len(types) > 0 && !matchesType(types, record.Type) {
+		return false
+	}
+
+	switch {
+	case record.Departure != nil:
+		return (station == "" || record.Departure.Station.Code == station) &&
+			(serviceNumber == "" || record.Departure.ServiceNumber == serviceNumber)
+	case record.Arrival != nil:
+		return (station == "" || record.Arrival.Station.Code == station) &&
+			(serviceNumber == "" || record.Arrival.ServiceNumber == serviceNumber)
+	case record.Service != nil:
+		return (station == "" || serviceCallsAt(*record.Service, station)) &&
+			(serviceNumber == "" || serviceHasNumber(*record.Service, serviceNumber))
+	}
+
+	return false
+}
+
+// matchesType returns true when the record type is in the list (singular or plural)
+func matchesType(types []string, recordType string) bool {
+	for _, candidate := range types {
+		if strings.TrimSuffix(candidate, "s") == recordType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func serviceCallsAt(service models.Service, station string) bool {
+	for _, part := range service.ServiceParts {
+		for _, stop := range part.Stops {
+			if stop.Station.Code == station {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func serviceHasNumber(service models.Service, serviceNumber string) bool {
+	if service.ServiceNumber == serviceNumber {
+		return true
+	}
+
+	for _, part := range service.ServiceParts {
+		if part.ServiceNumber == serviceNumber {
+			return true
+		}
+	}
+
+	return false
+}
+
+// storeStats counts the items of a store per service date and station
+type storeStats struct {
+	total    int
+	hidden   int
+	dates    map[string]int
+	stations map[string]int
+}
+
+func newStoreStats() *storeStats {
+	return &storeStats{dates: make(map[string]int), stations: make(map[string]int)}
+}
+
+func (stats *storeStats) add(date, station string, hidden bool) {
+	stats.total++
+	stats.dates[date]++
+
+	if station != "" {
+		stats.stations[station]++
+	}
+
+	if hidden {
+		stats.hidden++
+	}
+}
+
+func (stats *storeStats) print(title string) {
+	fmt.Printf("\n%s: %d (%d hidden)\n", title, stats.total, stats.hidden)
+
+	if len(stats.dates) > 0 {
+		fmt.Println("  Per service date:")
+
+		dates := make([]string, 0, len(stats.dates))
+		for date := range stats.dates {
+			dates = append(dates, date)
+		}
+		sort.Strings(dates)
+
+		for _, date := range dates {
+			fmt.Printf("    %-10s %7d\n", date, stats.dates[date])
+		}
+	}
+
+	if len(stats.stations) > 0 {
+		fmt.Println("  Per station:")
+
+		stations := make([]string, 0, len(stats.stations))
+		for station := range stats.stations {
+			stations = append(stations, station)
+		}
+		sort.Slice(stations, func(i, j int) bool {
+			if stats.stations[stations[i]] != stats.stations[stations[j]] {
+				return stats.stations[stations[i]] > stats.stations[stations[j]]
+			}
+
+			return stations[i] < stations[j]
+		})
+
+		for _, station := range stations {
+			fmt.Printf("    %-10s %7d\n", station, stats.stations[station])
+		}
+	}
+}
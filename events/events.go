@@ -0,0 +1,317 @@
+package events
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// Event types
+const (
+	EventPlatformChange     = "platform_change"
+	EventDelay              = "delay"
+	EventCancellation       = "cancellation"
+	EventDiversion          = "diversion"
+	EventMaterialAdded      = "material_added"
+	EventMaterialLeftBehind = "material_left_behind"
+	EventDoNotBoard         = "do_not_board"
+)
+
+// Event sources
+const (
+	SourceDeparture = "departure"
+	SourceArrival   = "arrival"
+	SourceService   = "service"
+)
+
+// Event is a significant change of a departure, arrival or service
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Source    string    `json:"source"`
+	Timestamp time.Time `json:"timestamp"`
+	ProductID string    `json:"product_id"`
+
+	ServiceDate   string `json:"service_date"`
+	ServiceNumber string `json:"service_number"`
+	ServiceType   string `json:"service_type"`
+	Company       string `json:"company"`
+	Station       string `json:"station"`
+
+	// Old and New contain the value before and after the change (platform, delay in seconds,
+	// destination codes or material number), depending on the event type
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// Detector detects events by comparing a new version of an item with the previous version.
+// New items are compared with their planned version.
+type Detector struct {
+	DelayThreshold int // Minimum delay in seconds for delay events
+}
+
+// eventBuilder collects the events for a single version of an item
+type eventBuilder struct {
+	template Event
+	events   []Event
+}
+
+func (builder *eventBuilder) add(eventType, station, previousValue, currentValue string) {
+	event := builder.template
+	event.Type = eventType
+	event.Station = station
+	event.Old = previousValue
+	event.New = currentValue
+	event.ID = strings.Join([]string{event.Source, event.ProductID, event.ServiceDate, event.ServiceNumber, eventType, station, currentValue}, "-")
+
+	builder.events = append(builder.events, event)
+}
+
+func (detector Detector) delayExceeded(previous, current int) bool {
+	return current >= detector.DelayThreshold && previous < detector.DelayThreshold
+}
+
+// DepartureEvents detects the events of a departure. previous is nil for new departures.
+func (detector Detector) DepartureEvents(previous *models.Departure, current models.Departure) []Event {
+	if previous == nil {
+		previous = &models.Departure{
+			PlatformActual:    current.PlatformPlanned,
+			DestinationActual: current.DestinationPlanned,
+		}
+	}
+
+	builder := eventBuilder{template: Event{
+		Source:        SourceDeparture,
+		Timestamp:     current.Timestamp,
+		ProductID:     current.ProductID,
+		ServiceDate:   current.ServiceDate,
+		ServiceNumber: current.ServiceNumber,
+		ServiceType:   current.ServiceTypeCode,
+		Company:       current.Company,
+	}}
+
+	station := current.Station.Code
+
+	if current.PlatformActual != "" && current.PlatformActual != previous.PlatformActual {
+		builder.add(EventPlatformChange, station, previous.PlatformActual, current.PlatformActual)
+	}
+
+	if detector.delayExceeded(previous.Delay, current.Delay) {
+		builder.add(EventDelay, station, strconv.Itoa(previous.Delay), strconv.Itoa(current.Delay))
+	}
+
+	if current.Cancelled && !previous.Cancelled {
+		builder.add(EventCancellation, station, "", "")
+	}
+
+	previousDestination := stationCodes(previous.DestinationActual)
+	currentDestination := stationCodes(current.DestinationActual)
+
+	if previousDestination != "" && currentDestination != "" && previousDestination != currentDestination {
+		builder.add(EventDiversion, station, previousDestination, currentDestination)
+	}
+
+	previousMaterial := wingsMaterial(previous.TrainWings)
+
+	for _, material := range wingsMaterial(current.TrainWings) {
+		detectMaterialEvents(&builder, station, previousMaterial, material)
+	}
+
+	if current.DoNotBoard && !previous.DoNotBoard {
+		builder.add(EventDoNotBoard, station, "", "")
+	}
+
+	return builder.events
+}
+
+// ArrivalEvents detects the events of an arrival. previous is nil for new arrivals.
+func (detector Detector) ArrivalEvents(previous *models.Arrival, current models.Arrival) []Event {
+	if previous == nil {
+		previous = &models.Arrival{PlatformActual: current.PlatformPlanned}
+	}
+
+	builder := eventBuilder{template: Event{
+		Source:        SourceArrival,
+		Timestamp:     current.Timestamp,
+		ProductID:     current.ProductID,
+		ServiceDate:   current.ServiceDate,
+		ServiceNumber: current.ServiceNumber,
+		ServiceType:   current.ServiceTypeCode,
+		Company:       current.Company,
+	}}
+
+	station := current.Station.Code
+
+	if current.PlatformActual != "" && current.PlatformActual != previous.PlatformActual {
+		builder.add(EventPlatformChange, station, previous.PlatformActual, current.PlatformActual)
+	}
+
+	if detector.delayExceeded(previous.Delay, current.Delay) {
+		builder.add(EventDelay, station, strconv.Itoa(previous.Delay), strconv.Itoa(current.Delay))
+	}
+
+	if current.Cancelled && !previous.Cancelled {
+		builder.add(EventCancellation, station, "", "")
+	}
+
+	if current.DoNotBoard && !previous.DoNotBoard {
+		builder.add(EventDoNotBoard, station, "", "")
+	}
+
+	return builder.events
+}
+
+// ServiceEvents detects the events of all stops of a service. previous is nil for new services.
+func (detector Detector) ServiceEvents(previous *models.Service, current models.Service) []Event {
+	previousStops := make(map[string]models.ServiceStop)
+
+	if previous != nil {
+		for _, part := range previous.ServiceParts {
+			for _, stop := range part.Stops {
+				previousStops[stop.Station.Code] = stop
+			}
+		}
+	}
+
+	builder := eventBuilder{template: Event{
+		Source:      SourceService,
+		Timestamp:   current.Timestamp,
+		ProductID:   current.ProductID,
+		ServiceDate: current.ServiceDate,
+		ServiceType: current.ServiceTypeCode,
+		Company:     current.Company,
+	}}
+
+	for _, part := range current.ServiceParts {
+		builder.template.ServiceNumber = current.ServiceNumber
+		if part.ServiceNumber != "" {
+			builder.template.ServiceNumber = part.ServiceNumber
+		}
+
+		for _, stop := range part.Stops {
+			previousStop, exists := previousStops[stop.Station.Code]
+
+			if !exists {
+				previousStop = plannedStop(stop)
+			}
+
+			detector.detectStopEvents(&builder, previousStop, stop)
+		}
+	}
+
+	return builder.events
+}
+
+func (detector Detector) detectStopEvents(builder *eventBuilder, previous, current models.ServiceStop) {
+	station := current.Station.Code
+
+	if platform := stopPlatform(current); platform != "" && platform != stopPlatform(previous) {
+		builder.add(EventPlatformChange, station, stopPlatform(previous), platform)
+	}
+
+	if detector.delayExceeded(stopDelay(previous), stopDelay(current)) {
+		builder.add(EventDelay, station, strconv.Itoa(stopDelay(previous)), strconv.Itoa(stopDelay(current)))
+	}
+
+	if stopCancelled(current) && !stopCancelled(previous) {
+		builder.add(EventCancellation, station, "", "")
+	}
+
+	if previous.DestinationActual != "" && current.DestinationActual != "" && previous.DestinationActual != current.DestinationActual {
+		builder.add(EventDiversion, station, previous.DestinationActual, current.DestinationActual)
+	}
+
+	for _, material := range current.Material {
+		detectMaterialEvents(builder, station, previous.Material, material)
+	}
+
+	if current.DoNotBoard && !previous.DoNotBoard {
+		builder.add(EventDoNotBoard, station, "", "")
+	}
+}
+
+// detectMaterialEvents adds events for a material unit which is newly added or left behind
+func detectMaterialEvents(builder *eventBuilder, station string, previousMaterial []models.Material, material models.Material) {
+	number := material.NormalizedNumber()
+
+	if number == nil {
+		return
+	}
+
+	var previous models.Material
+
+	for _, unit := range previousMaterial {
+		if unitNumber := unit.NormalizedNumber(); unitNumber != nil && *unitNumber == *number {
+			previous = unit
+		}
+	}
+
+	if material.Added && !previous.Added {
+		builder.add(EventMaterialAdded, station, "", *number)
+	}
+
+	if material.RemainsBehind && !previous.RemainsBehind {
+		builder.add(EventMaterialLeftBehind, station, "", *number)
+	}
+}
+
+// plannedStop returns the planned version of a stop, which is used when a stop is new
+func plannedStop(stop models.ServiceStop) models.ServiceStop {
+	return models.ServiceStop{
+		Station:                 stop.Station,
+		DestinationActual:       stop.DestinationPlanned,
+		ArrivalPlatformActual:   stop.ArrivalPlatformPlanned,
+		DeparturePlatformActual: stop.DeparturePlatformPlanned,
+		ArrivalTime:             stop.ArrivalTime,
+		DepartureTime:           stop.DepartureTime,
+	}
+}
+
+// stopPlatform returns the departure platform of a stop, or the arrival platform at the terminus
+func stopPlatform(stop models.ServiceStop) string {
+	if stop.DepartureTime.IsZero() {
+		return stop.ArrivalPlatformActual
+	}
+
+	return stop.DeparturePlatformActual
+}
+
+// stopDelay returns the departure delay of a stop, or the arrival delay at the terminus
+func stopDelay(stop models.ServiceStop) int {
+	if stop.DepartureTime.IsZero() {
+		return stop.ArrivalDelay
+	}
+
+	return stop.DepartureDelay
+}
+
+// stopCancelled returns true when both the arrival and departure of a stop are cancelled (if present)
+func stopCancelled(stop models.ServiceStop) bool {
+	if stop.ArrivalTime.IsZero() && stop.DepartureTime.IsZero() {
+		return false
+	}
+
+	return (stop.ArrivalTime.IsZero() || stop.ArrivalCancelled) && (stop.DepartureTime.IsZero() || stop.DepartureCancelled)
+}
+
+func stationCodes(stations []models.Station) string {
+	codes := make([]string, 0, len(stations))
+
+	for _, station := range stations {
+		codes = append(codes, station.Code)
+	}
+
+	return strings.Join(codes, ",")
+}
+
+func wingsMaterial(wings []models.TrainWing) []models.Material {
+	var material []models.Material
+
+	for _, wing := range wings {
+		material = append(material, wing.Material...)
+	}
+
+	return material
+}
@@ -0,0 +1,153 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func generateDeparture() models.Departure {
+	var departure models.Departure
+
+	departure.ProductID = "12345"
+	departure.ServiceID = "1234"
+	departure.ServiceNumber = "1234"
+	departure.ServiceTypeCode = "IC"
+	departure.Station.Code = "UT"
+	departure.ServiceDate = "2019-01-27"
+	departure.GenerateID()
+	departure.Timestamp = time.Date(2019, time.January, 27, 12, 34, 56, 0, time.UTC)
+	departure.DepartureTime = time.Date(2019, time.January, 27, 12, 34, 56, 0, time.UTC)
+	departure.PlatformPlanned = "5"
+	departure.PlatformActual = "5"
+	departure.DestinationPlanned = []models.Station{{Code: "GVC"}}
+	departure.DestinationActual = []models.Station{{Code: "GVC"}}
+
+	return departure
+}
+
+func eventTypes(events []Event) []string {
+	types := make([]string, 0, len(events))
+
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+func TestNewDepartureEvents(t *testing.T) {
+	detector := Detector{DelayThreshold: 300}
+
+	departure := generateDeparture()
+
+	if events := detector.DepartureEvents(nil, departure); len(events) != 0 {
+		t.Errorf("Planned departure should not have events: %v", eventTypes(events))
+	}
+
+	departure.PlatformActual = "7"
+	departure.Delay = 600
+	events := detector.DepartureEvents(nil, departure)
+
+	if len(events) != 2 || events[0].Type != EventPlatformChange || events[1].Type != EventDelay {
+		t.Fatalf("Wrong events: %v", eventTypes(events))
+	}
+
+	if events[0].Old != "5" || events[0].New != "7" || events[0].Station != "UT" || events[0].ServiceType != "IC" {
+		t.Errorf("Wrong platform change event: %+v", events[0])
+	}
+}
+
+func TestDepartureEvents(t *testing.T) {
+	detector := Detector{DelayThreshold: 300}
+
+	previous := generateDeparture()
+	previous.Delay = 120
+
+	current := generateDeparture()
+	current.Delay = 240
+
+	if events := detector.DepartureEvents(&previous, current); len(events) != 0 {
+		t.Errorf("Delay below threshold should not be an event: %v", eventTypes(events))
+	}
+
+	current.Delay = 300
+	current.Cancelled = true
+	current.DoNotBoard = true
+	current.DestinationActual = []models.Station{{Code: "LEDN"}}
+	current.TrainWings = []models.TrainWing{{Material: []models.Material{
+		{Number: "000000-09547-0", Added: true},
+		{Number: "000000-02412-0", RemainsBehind: true},
+	}}}
+
+	events := detector.DepartureEvents(&previous, current)
+	expected := []string{EventDelay, EventCancellation, EventDiversion, EventMaterialAdded, EventMaterialLeftBehind, EventDoNotBoard}
+
+	if len(events) != len(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, eventTypes(events))
+	}
+
+	for i, eventType := range expected {
+		if events[i].Type != eventType {
+			t.Errorf("Expected event %s, got %s", eventType, events[i].Type)
+		}
+	}
+
+	if events[2].Old != "GVC" || events[2].New != "LEDN" {
+		t.Errorf("Wrong diversion event: %+v", events[2])
+	}
+
+	if events[3].New != "9547" || events[4].New != "2412" {
+		t.Errorf("Wrong material events: %+v %+v", events[3], events[4])
+	}
+
+	// Same state again should not repeat the events:
+	if events := detector.DepartureEvents(&current, current); len(events) != 0 {
+		t.Errorf("Unchanged departure should not have events: %v", eventTypes(events))
+	}
+}
+
+func TestServiceEvents(t *testing.T) {
+	detector := Detector{DelayThreshold: 300}
+
+	var service models.Service
+	service.ProductID = "12345"
+	service.ServiceNumber = "1234"
+	service.ServiceDate = "2019-01-27"
+	service.GenerateID()
+
+	var stop1, stop2 models.ServiceStop
+	stop1.Station.Code = "UT"
+	stop1.DepartureTime = time.Date(2019, time.January, 27, 12, 34, 0, 0, time.UTC)
+	stop1.DeparturePlatformPlanned = "5"
+	stop1.DeparturePlatformActual = "5"
+	stop2.Station.Code = "GVC"
+	stop2.ArrivalTime = time.Date(2019, time.January, 27, 13, 34, 0, 0, time.UTC)
+	stop2.ArrivalPlatformPlanned = "3"
+	stop2.ArrivalPlatformActual = "3"
+
+	service.ServiceParts = []models.ServicePart{{Stops: []models.ServiceStop{stop1, stop2}}}
+
+	if events := detector.ServiceEvents(nil, service); len(events) != 0 {
+		t.Errorf("Planned service should not have events: %v", eventTypes(events))
+	}
+
+	updated := service
+	stop2.ArrivalPlatformActual = "4"
+	stop2.ArrivalDelay = 420
+	stop2.ArrivalCancelled = true
+	updated.ServiceParts = []models.ServicePart{{ServiceNumber: "11234", Stops: []models.ServiceStop{stop1, stop2}}}
+
+	events := detector.ServiceEvents(&service, updated)
+
+	if len(events) != 3 || events[0].Type != EventPlatformChange || events[1].Type != EventDelay || events[2].Type != EventCancellation {
+		t.Fatalf("Wrong events: %v", eventTypes(events))
+	}
+
+	for _, event := range events {
+		if event.Station != "GVC" || event.ServiceNumber != "11234" || event.Source != SourceService {
+			t.Errorf("Wrong event details: %+v", event)
+		}
+	}
+}
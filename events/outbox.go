@@ -0,0 +1,134 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Delivery is an event which has to be delivered to a webhook
+type Delivery struct {
+	URL      string `json:"url"`
+	Event    Event  `json:"event"`
+	Attempts int    `json:"attempts"`
+
+	// Queued is the time the delivery was added to the queue
+	Queued time.Time `json:"queued"`
+
+	// File is the outbox file of this delivery (empty when the outbox is disabled)
+	File string `json:"-"`
+}
+
+// Outbox stores pending deliveries in a directory, so they survive a restart.
+// All methods can be called on a nil outbox, in which case deliveries are not persisted.
+type Outbox struct {
+	Directory string
+
+	mutex    sync.Mutex
+	sequence int
+}
+
+// NewOutbox creates an outbox, creating the directory if necessary
+func NewOutbox(directory string) (*Outbox, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Outbox{Directory: directory}, nil
+}
+
+// Add writes a new delivery to the outbox and sets its file name
+func (outbox *Outbox) Add(delivery *Delivery) error {
+	if outbox == nil {
+		return nil
+	}
+
+	outbox.mutex.Lock()
+	outbox.sequence++
+	delivery.File = fmt.Sprintf("outbox-%s-%04d.json", time.Now().UTC().Format("20060102-150405.000"), outbox.sequence%10000)
+	outbox.mutex.Unlock()
+
+	err := outbox.Update(*delivery)
+
+	if err != nil {
+		delivery.File = ""
+	}
+
+	return err
+}
+
+// Update rewrites a delivery in the outbox (e.g. after a failed attempt)
+func (outbox *Outbox) Update(delivery Delivery) error {
+	if outbox == nil || delivery.File == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(delivery)
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(outbox.Directory, delivery.File), data, 0644)
+}
+
+// Remove removes a delivered (or dropped) delivery from the outbox
+func (outbox *Outbox) Remove(delivery Delivery) error {
+	if outbox == nil || delivery.File == "" {
+		return nil
+	}
+
+	return os.Remove(filepath.Join(outbox.Directory, delivery.File))
+}
+
+// Pending reads all deliveries in the outbox, oldest first. Unreadable files are skipped.
+func (outbox *Outbox) Pending() ([]Delivery, error) {
+	if outbox == nil {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(outbox.Directory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "outbox-") && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, entry.Name())
+		}
+	}
+
+	sort.Strings(files)
+
+	deliveries := make([]Delivery, 0, len(files))
+
+	for _, file := range files {
+		var delivery Delivery
+
+		data, err := os.ReadFile(filepath.Join(outbox.Directory, file))
+
+		if err == nil {
+			err = json.Unmarshal(data, &delivery)
+		}
+
+		if err != nil {
+			log.Error().Err(err).Str("file", file).Msg("Could not read outbox delivery")
+			continue
+		}
+
+		delivery.File = file
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
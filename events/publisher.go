@@ -0,0 +1,248 @@
+package events
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Webhook defaults, used when not configured
+const (
+	defaultMaxRetries     = 10
+	defaultRetryDelay     = 5 * time.Second
+	defaultTimeout        = 10 * time.Second
+	defaultMaxQueue       = 10000
+	defaultMaxAge         = 1 * time.Hour
+	defaultDelayThreshold = 300
+)
+
+// deliveryBufferSize is the number of deliveries which can be queued for the outbox writer.
+// Deliveries are dropped when the buffer is full, so the stores are never blocked.
+const deliveryBufferSize = 1000
+
+// Publisher detects events in the changes of the stores and delivers them to webhooks
+type Publisher struct {
+	Detector Detector
+	Webhooks []Webhook
+	Outbox   *Outbox
+
+	collection  *stores.StoreCollection
+	workers     map[string]*webhookWorker
+	deliveries  chan Delivery
+	writerDone  chan struct{}
+	listenerIDs [3]int
+	dropped     atomic.Int64
+}
+
+// NewPublisher creates a publisher for the given store collection and webhooks
+func NewPublisher(collection *stores.StoreCollection, webhooks []Webhook) *Publisher {
+	for i := range webhooks {
+		if webhooks[i].MaxRetries == 0 {
+			webhooks[i].MaxRetries = defaultMaxRetries
+		}
+		if webhooks[i].RetryDelay == 0 {
+			webhooks[i].RetryDelay = defaultRetryDelay
+		}
+		if webhooks[i].Timeout == 0 {
+			webhooks[i].Timeout = defaultTimeout
+		}
+		if webhooks[i].MaxQueue == 0 {
+			webhooks[i].MaxQueue = defaultMaxQueue
+		}
+		if webhooks[i].MaxAge == 0 {
+			webhooks[i].MaxAge = defaultMaxAge
+		}
+	}
+
+	return &Publisher{
+		Detector:   Detector{DelayThreshold: defaultDelayThreshold},
+		Webhooks:   webhooks,
+		collection: collection,
+	}
+}
+
+// SetupFromConfig creates a publisher for the configured webhooks. It returns nil when no
+// webhooks are configured.
+func SetupFromConfig(collection *stores.StoreCollection) (*Publisher, error) {
+	var webhooks []Webhook
+
+	if err := viper.UnmarshalKey("events.webhooks", &webhooks); err != nil {
+		return nil, err
+	}
+
+	if len(webhooks) == 0 {
+		return nil, nil
+	}
+
+	publisher := NewPublisher(collection, webhooks)
+
+	if viper.IsSet("events.delay_threshold") {
+		publisher.Detector.DelayThreshold = viper.GetInt("events.delay_threshold")
+	}
+
+	if directory := viper.GetString("events.outbox"); directory != "" {
+		outbox, err := NewOutbox(directory)
+
+		if err != nil {
+			return nil, err
+		}
+
+		publisher.Outbox = outbox
+	}
+
+	log.Info().Int("webhooks", len(webhooks)).Str("outbox", viper.GetString("events.outbox")).Msg("Event webhooks enabled")
+
+	return publisher, nil
+}
+
+// Start starts the webhook workers, queues the pending deliveries from the outbox and
+// registers the publisher as listener of the stores
+func (publisher *Publisher) Start() {
+	publisher.workers = make(map[string]*webhookWorker)
+
+	for _, webhook := range publisher.Webhooks {
+		publisher.workers[webhook.URL] = newWebhookWorker(webhook, publisher.Outbox)
+	}
+
+	pending, err := publisher.Outbox.Pending()
+
+	if err != nil {
+		log.Error().Err(err).Msg("Could not read outbox")
+	}
+
+	for _, delivery := range pending {
+		worker, exists := publisher.workers[delivery.URL]
+
+		if !exists {
+			log.Warn().Str("url", delivery.URL).Str("event", delivery.Event.ID).Msg("Webhook no longer configured, dropping event")
+			publisher.Outbox.Remove(delivery)
+			continue
+		}
+
+		// Deliveries written by an older version have no queue time:
+		if delivery.Queued.IsZero() {
+			delivery.Queued = time.Now()
+		}
+
+		worker.enqueue(delivery)
+	}
+
+	for _, worker := range publisher.workers {
+		go worker.run()
+	}
+
+	publisher.deliveries = make(chan Delivery, deliveryBufferSize)
+	publisher.writerDone = make(chan struct{})
+
+	go publisher.writeDeliveries()
+
+	publisher.listenerIDs[0] = publisher.collection.DepartureStore.AddListener(func(change stores.Change) {
+		if change.Action == stores.ChangeUpdated {
+			var previous *models.Departure
+
+			if change.Previous != nil {
+				departure := change.Previous.(models.Departure)
+				previous = &departure
+			}
+
+			publisher.Publish(publisher.Detector.DepartureEvents(previous, change.Item.(models.Departure)))
+		}
+	})
+	publisher.listenerIDs[1] = publisher.collection.ArrivalStore.AddListener(func(change stores.Change) {
+		if change.Action == stores.ChangeUpdated {
+			var previous *models.Arrival
+
+			if change.Previous != nil {
+				arrival := change.Previous.(models.Arrival)
+				previous = &arrival
+			}
+
+			publisher.Publish(publisher.Detector.ArrivalEvents(previous, change.Item.(models.Arrival)))
+		}
+	})
+	publisher.listenerIDs[2] = publisher.collection.ServiceStore.AddListener(func(change stores.Change) {
+		if change.Action == stores.ChangeUpdated {
+			var previous *models.Service
+
+			if change.Previous != nil {
+				service := change.Previous.(models.Service)
+				previous = &service
+			}
+
+			publisher.Publish(publisher.Detector.ServiceEvents(previous, change.Item.(models.Service)))
+		}
+	})
+}
+
+// Stop removes the listeners from the stores and stops the webhook workers. Events which
+// have not been delivered yet remain in the outbox.
+func (publisher *Publisher) Stop() {
+	publisher.collection.DepartureStore.RemoveListener(publisher.listenerIDs[0])
+	publisher.collection.ArrivalStore.RemoveListener(publisher.listenerIDs[1])
+	publisher.collection.ServiceStore.RemoveListener(publisher.listenerIDs[2])
+
+	close(publisher.deliveries)
+	<-publisher.writerDone
+
+	for _, worker := range publisher.workers {
+		worker.shutdown()
+	}
+}
+
+// Publish queues events for all webhooks with matching filters. It is called from the store
+// listeners, so the deliveries are written to the outbox by a separate goroutine. Events are
+// dropped (and counted) when the queue of that goroutine is full.
+func (publisher *Publisher) Publish(events []Event) {
+	for _, event := range events {
+		for _, webhook := range publisher.Webhooks {
+			if !webhook.Matches(event) {
+				continue
+			}
+
+			select {
+			case publisher.deliveries <- Delivery{URL: webhook.URL, Event: event, Queued: time.Now()}:
+			default:
+				publisher.dropped.Add(1)
+				log.Warn().Str("url", webhook.URL).Str("event", event.ID).Msg("Event queue full, dropping event")
+			}
+		}
+	}
+}
+
+// Dropped returns the number of deliveries which were dropped: because a queue was full, because
+// they were queued for too long or because the maximum number of retries was reached
+func (publisher *Publisher) Dropped() int64 {
+	dropped := publisher.dropped.Load()
+
+	for _, worker := range publisher.workers {
+		dropped += worker.dropped.Load()
+	}
+
+	return dropped
+}
+
+// writeDeliveries writes the published deliveries to the outbox and hands them to the webhook
+// workers, until the deliveries channel is closed
+func (publisher *Publisher) writeDeliveries() {
+	defer close(publisher.writerDone)
+
+	for delivery := range publisher.deliveries {
+		worker := publisher.workers[delivery.URL]
+
+		// Don't write deliveries to the outbox which will be dropped anyway:
+		if worker.full() {
+			worker.drop(delivery, "queue full")
+			continue
+		}
+
+		if err := publisher.Outbox.Add(&delivery); err != nil {
+			log.Error().Err(err).Str("event", delivery.Event.ID).Msg("Could not write event to outbox")
+		}
+
+		worker.enqueue(delivery)
+	}
+}
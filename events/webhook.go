@@ -0,0 +1,261 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxRetryDelay is the maximum delay between two delivery attempts
+const maxRetryDelay = 10 * time.Minute
+
+// Webhook is an HTTP endpoint which receives events as JSON POST requests.
+// Empty filters match all events.
+type Webhook struct {
+	URL          string        `mapstructure:"url"`
+	Events       []string      `mapstructure:"events"`
+	Sources      []string      `mapstructure:"sources"`
+	Stations     []string      `mapstructure:"stations"`
+	ServiceTypes []string      `mapstructure:"service_types"`
+	MaxRetries   int           `mapstructure:"max_retries"`
+	RetryDelay   time.Duration `mapstructure:"retry_delay"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+
+	// MaxQueue is the maximum number of undelivered events; new events are dropped while the
+	// queue is full. Events which have been queued longer than MaxAge are dropped as well.
+	MaxQueue int           `mapstructure:"max_queue"`
+	MaxAge   time.Duration `mapstructure:"max_age"`
+}
+
+// Matches returns true when an event passes all filters of the webhook
+func (webhook Webhook) Matches(event Event) bool {
+	return matchesFilter(webhook.Events, event.Type) &&
+		matchesFilter(webhook.Sources, event.Source) &&
+		matchesFilter(webhook.Stations, event.Station) &&
+		matchesFilter(webhook.ServiceTypes, event.ServiceType)
+}
+
+func matchesFilter(filter []string, value string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+
+	for _, allowed := range filter {
+		if allowed == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryDelay returns the delay before the next attempt, doubling after every failed attempt
+func (webhook Webhook) retryDelay(attempts int) time.Duration {
+	delay := webhook.RetryDelay
+
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay
+}
+
+// webhookWorker delivers the events for a single webhook in order
+type webhookWorker struct {
+	webhook Webhook
+	outbox  *Outbox
+	client  *http.Client
+
+	mutex   sync.Mutex
+	queue   []Delivery
+	notify  chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+func newWebhookWorker(webhook Webhook, outbox *Outbox) *webhookWorker {
+	return &webhookWorker{
+		webhook: webhook,
+		outbox:  outbox,
+		client:  &http.Client{Timeout: webhook.Timeout},
+		notify:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// full returns true when the queue of the worker has reached the maximum size
+func (worker *webhookWorker) full() bool {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+
+	return len(worker.queue) >= worker.webhook.MaxQueue
+}
+
+// enqueue adds a delivery to the queue of the worker. The delivery is dropped when the queue is full.
+func (worker *webhookWorker) enqueue(delivery Delivery) {
+	worker.mutex.Lock()
+	if len(worker.queue) >= worker.webhook.MaxQueue {
+		worker.mutex.Unlock()
+		worker.drop(delivery, "queue full")
+		return
+	}
+	worker.queue = append(worker.queue, delivery)
+	worker.mutex.Unlock()
+
+	select {
+	case worker.notify <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the first delivery in the queue, waiting for one when the queue is empty.
+// It returns false when the worker has been stopped.
+func (worker *webhookWorker) next() (Delivery, bool) {
+	for {
+		worker.mutex.Lock()
+		if len(worker.queue) > 0 {
+			delivery := worker.queue[0]
+			worker.mutex.Unlock()
+
+			return delivery, true
+		}
+		worker.mutex.Unlock()
+
+		select {
+		case <-worker.notify:
+		case <-worker.stop:
+			return Delivery{}, false
+		}
+	}
+}
+
+// run delivers all queued events until the worker is stopped
+func (worker *webhookWorker) run() {
+	defer close(worker.done)
+
+	for {
+		delivery, ok := worker.next()
+
+		if !ok {
+			return
+		}
+
+		if worker.expired(delivery) {
+			worker.drop(delivery, "too old")
+		} else if !worker.deliverWithRetries(delivery) {
+			return
+		}
+
+		worker.mutex.Lock()
+		worker.queue = worker.queue[1:]
+		worker.mutex.Unlock()
+	}
+}
+
+// deliverWithRetries tries to deliver an event until it succeeds or the maximum number of retries
+// has been reached. It returns false when the worker was stopped while waiting for a retry.
+func (worker *webhookWorker) deliverWithRetries(delivery Delivery) bool {
+	for {
+		err := worker.deliver(delivery)
+
+		if err == nil {
+			worker.removeFromOutbox(delivery)
+			return true
+		}
+
+		delivery.Attempts++
+
+		if delivery.Attempts > worker.webhook.MaxRetries || worker.expired(delivery) {
+			log.Error().Err(err).Str("url", worker.webhook.URL).Str("event", delivery.Event.ID).
+				Int("attempts", delivery.Attempts).Msg("Could not deliver event, dropping it")
+			worker.dropped.Add(1)
+			worker.removeFromOutbox(delivery)
+			return true
+		}
+
+		delay := worker.webhook.retryDelay(delivery.Attempts)
+
+		log.Warn().Err(err).Str("url", worker.webhook.URL).Str("event", delivery.Event.ID).
+			Int("attempts", delivery.Attempts).Dur("retry_delay", delay).Msg("Could not deliver event, retrying")
+
+		if err := worker.outbox.Update(delivery); err != nil {
+			log.Error().Err(err).Str("file", delivery.File).Msg("Could not update outbox")
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-worker.stop:
+			return false
+		}
+	}
+}
+
+// deliver posts an event to the webhook
+func (worker *webhookWorker) deliver(delivery Delivery) error {
+	body, err := json.Marshal(delivery.Event)
+
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, worker.webhook.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-GoTrain-Event", delivery.Event.Type)
+
+	response, err := worker.client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	return nil
+}
+
+// expired returns true when a delivery has been queued for longer than the maximum age
+func (worker *webhookWorker) expired(delivery Delivery) bool {
+	return time.Since(delivery.Queued) > worker.webhook.MaxAge
+}
+
+// drop discards a delivery without delivering it
+func (worker *webhookWorker) drop(delivery Delivery, reason string) {
+	log.Warn().Str("url", worker.webhook.URL).Str("event", delivery.Event.ID).Str("reason", reason).
+		Msg("Dropping event")
+
+	worker.dropped.Add(1)
+	worker.removeFromOutbox(delivery)
+}
+
+func (worker *webhookWorker) removeFromOutbox(delivery Delivery) {
+	if err := worker.outbox.Remove(delivery); err != nil {
+		log.Error().Err(err).Str("file", delivery.File).Msg("Could not remove delivery from outbox")
+	}
+}
+
+// shutdown stops the worker and waits for it to finish. Undelivered events remain in the outbox.
+func (worker *webhookWorker) shutdown() {
+	close(worker.stop)
+	<-worker.done
+}
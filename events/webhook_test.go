@@ -0,0 +1,232 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+func TestWebhookMatches(t *testing.T) {
+	webhook := Webhook{Stations: []string{"UT", "ASD"}, ServiceTypes: []string{"IC"}}
+
+	tables := []struct {
+		event   Event
+		matches bool
+	}{
+		{Event{Station: "UT", ServiceType: "IC"}, true},
+		{Event{Station: "GVC", ServiceType: "IC"}, false},
+		{Event{Station: "ASD", ServiceType: "SPR"}, false},
+	}
+
+	for _, table := range tables {
+		if webhook.Matches(table.event) != table.matches {
+			t.Errorf("Wrong match for %+v", table.event)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	webhook := Webhook{RetryDelay: time.Second}
+
+	tables := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{20, maxRetryDelay},
+	}
+
+	for _, table := range tables {
+		if delay := webhook.retryDelay(table.attempts); delay != table.delay {
+			t.Errorf("Attempt %d: expected delay %s, got %s", table.attempts, table.delay, delay)
+		}
+	}
+}
+
+// webhookServer fails the first failures requests and records the received events
+type webhookServer struct {
+	mutex    sync.Mutex
+	failures int
+	events   []Event
+	received chan struct{}
+}
+
+func (server *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.failures > 0 {
+		server.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var event Event
+	json.NewDecoder(r.Body).Decode(&event)
+	server.events = append(server.events, event)
+	server.received <- struct{}{}
+}
+
+func TestPublisherDelivery(t *testing.T) {
+	server := &webhookServer{failures: 2, received: make(chan struct{}, 10)}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	outbox, err := NewOutbox(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collection := &stores.StoreCollection{}
+	collection.DepartureStore.InitStore()
+	collection.ArrivalStore.InitStore()
+	collection.ServiceStore.InitStore()
+
+	publisher := NewPublisher(collection, []Webhook{{
+		URL:        httpServer.URL,
+		Events:     []string{EventPlatformChange},
+		RetryDelay: 10 * time.Millisecond,
+	}})
+	publisher.Outbox = outbox
+	publisher.Start()
+
+	departure := generateDeparture()
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	// Delay and platform change, only the platform change matches:
+	departure.Timestamp = departure.Timestamp.Add(time.Minute)
+	departure.PlatformActual = "7"
+	departure.Delay = 600
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	select {
+	case <-server.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event not delivered")
+	}
+
+	publisher.Stop()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(server.events) != 1 || server.events[0].Type != EventPlatformChange || server.events[0].New != "7" {
+		t.Errorf("Wrong events delivered: %+v", server.events)
+	}
+
+	if pending, _ := outbox.Pending(); len(pending) != 0 {
+		t.Errorf("Outbox should be empty, has %d deliveries", len(pending))
+	}
+}
+
+func TestPublisherOutbox(t *testing.T) {
+	server := &webhookServer{received: make(chan struct{}, 10)}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	outbox, err := NewOutbox(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deliveries left behind by a previous run:
+	pending := []Delivery{
+		{URL: httpServer.URL, Event: Event{ID: "1", Type: EventCancellation}, Attempts: 3},
+		{URL: "http://unknown.example.com/", Event: Event{ID: "2", Type: EventCancellation}},
+	}
+
+	for i := range pending {
+		if err := outbox.Add(&pending[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	collection := &stores.StoreCollection{}
+	publisher := NewPublisher(collection, []Webhook{{URL: httpServer.URL}})
+	publisher.Outbox = outbox
+	publisher.Start()
+
+	select {
+	case <-server.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Pending event not delivered")
+	}
+
+	publisher.Stop()
+
+	if remaining, _ := outbox.Pending(); len(remaining) != 0 {
+		t.Errorf("Outbox should be empty, has %d deliveries", len(remaining))
+	}
+}
+
+func TestPublishQueueFull(t *testing.T) {
+	publisher := NewPublisher(&stores.StoreCollection{}, []Webhook{{URL: "http://example.com/"}})
+	publisher.deliveries = make(chan Delivery, 1)
+
+	// The second event doesn't fit and must not block the store listener:
+	publisher.Publish([]Event{{ID: "1"}, {ID: "2"}})
+
+	if publisher.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped delivery, got %d", publisher.Dropped())
+	}
+	if delivery := <-publisher.deliveries; delivery.Event.ID != "1" {
+		t.Errorf("Wrong queued delivery: %+v", delivery)
+	}
+}
+
+func TestWebhookWorkerLimits(t *testing.T) {
+	server := &webhookServer{received: make(chan struct{}, 10)}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	outbox, err := NewOutbox(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worker := newWebhookWorker(Webhook{URL: httpServer.URL, MaxQueue: 2, MaxAge: time.Minute}, outbox)
+
+	deliveries := []Delivery{
+		{URL: httpServer.URL, Event: Event{ID: "old"}, Queued: time.Now().Add(-2 * time.Minute)},
+		{URL: httpServer.URL, Event: Event{ID: "new"}, Queued: time.Now()},
+		{URL: httpServer.URL, Event: Event{ID: "full"}, Queued: time.Now()},
+	}
+
+	for i := range deliveries {
+		outbox.Add(&deliveries[i])
+		worker.enqueue(deliveries[i])
+	}
+
+	go worker.run()
+
+	select {
+	case <-server.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event not delivered")
+	}
+
+	worker.shutdown()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(server.events) != 1 || server.events[0].ID != "new" {
+		t.Errorf("Only the new event should be delivered: %+v", server.events)
+	}
+	if worker.dropped.Load() != 2 {
+		t.Errorf("Expected 2 dropped deliveries, got %d", worker.dropped.Load())
+	}
+	if pending, _ := outbox.Pending(); len(pending) != 0 {
+		t.Errorf("Dropped deliveries should be removed from the outbox, %d remaining", len(pending))
+	}
+}
@@ -0,0 +1,157 @@
+// Package forwarder forwards all processed departures, arrivals and services as JSON messages.
+// It is used by the publishers which keep external subscribers up to date.
+package forwarder
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/serializers"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/rs/zerolog/log"
+)
+
+// SendFunc sends a message with the given key (e.g. a topic or envelope). An empty payload
+// indicates that the item has been hidden or removed (tombstone).
+type SendFunc func(key string, payload []byte)
+
+// Keys determine the message keys of departures, arrivals and services
+type Keys struct {
+	Departure func(departure models.Departure) string
+	Arrival   func(arrival models.Arrival) string
+	Service   func(service models.Service) string
+}
+
+// Forwarder sends a message for every change of the stores
+type Forwarder struct {
+	Language string
+
+	keys        Keys
+	send        SendFunc
+	collection  *stores.StoreCollection
+	listenerIDs [3]int
+
+	mutex    sync.Mutex
+	snapshot map[string]bool // Keys updated while the store contents are being sent
+}
+
+// NewForwarder creates a forwarder for the given store collection
+func NewForwarder(collection *stores.StoreCollection, keys Keys, send SendFunc) *Forwarder {
+	return &Forwarder{
+		Language:   "nl",
+		keys:       keys,
+		send:       send,
+		collection: collection,
+	}
+}
+
+// Start registers the forwarder as listener of the stores. When sendContents is true, the current
+// contents of the stores are sent as well. Items which are updated while the contents are being
+// sent are skipped, so an older version is never sent after a newer one.
+func (forwarder *Forwarder) Start(sendContents bool) {
+	if sendContents {
+		forwarder.mutex.Lock()
+		forwarder.snapshot = make(map[string]bool)
+		forwarder.mutex.Unlock()
+	}
+
+	forwarder.listenerIDs[0] = forwarder.collection.DepartureStore.AddListener(func(change stores.Change) {
+		departure := change.Item.(models.Departure)
+		forwarder.forward(forwarder.keys.Departure(departure), change, func() interface{} {
+			return serializers.DepartureToJSON(departure, forwarder.Language, true, nil)
+		})
+	})
+	forwarder.listenerIDs[1] = forwarder.collection.ArrivalStore.AddListener(func(change stores.Change) {
+		arrival := change.Item.(models.Arrival)
+		forwarder.forward(forwarder.keys.Arrival(arrival), change, func() interface{} {
+			return serializers.ArrivalToJSON(arrival, forwarder.Language)
+		})
+	})
+	forwarder.listenerIDs[2] = forwarder.collection.ServiceStore.AddListener(func(change stores.Change) {
+		service := change.Item.(models.Service)
+		forwarder.forward(forwarder.keys.Service(service), change, func() interface{} {
+			return serializers.ServiceToJSON(service, forwarder.Language, true)
+		})
+	})
+
+	if !sendContents {
+		return
+	}
+
+	for _, departure := range forwarder.collection.DepartureStore.GetDepartures(false) {
+		forwarder.sendSnapshot(forwarder.keys.Departure(departure), serializers.DepartureToJSON(departure, forwarder.Language, true, nil))
+	}
+
+	for _, arrival := range forwarder.collection.ArrivalStore.GetArrivals(false) {
+		forwarder.sendSnapshot(forwarder.keys.Arrival(arrival), serializers.ArrivalToJSON(arrival, forwarder.Language))
+	}
+
+	for _, service := range forwarder.collection.ServiceStore.GetServices(false) {
+		forwarder.sendSnapshot(forwarder.keys.Service(service), serializers.ServiceToJSON(service, forwarder.Language, true))
+	}
+
+	forwarder.mutex.Lock()
+	forwarder.snapshot = nil
+	forwarder.mutex.Unlock()
+}
+
+// Stop removes the listeners from the stores
+func (forwarder *Forwarder) Stop() {
+	forwarder.collection.DepartureStore.RemoveListener(forwarder.listenerIDs[0])
+	forwarder.collection.ArrivalStore.RemoveListener(forwarder.listenerIDs[1])
+	forwarder.collection.ServiceStore.RemoveListener(forwarder.listenerIDs[2])
+}
+
+// forward sends an updated item, or a tombstone for a hidden or removed item
+func (forwarder *Forwarder) forward(key string, change stores.Change, item func() interface{}) {
+	var payload []byte
+
+	if change.Action == stores.ChangeUpdated && !isHidden(change.Item) {
+		var err error
+
+		if payload, err = json.Marshal(item()); err != nil {
+			log.Error().Err(err).Str("key", key).Msg("Could not encode message")
+			return
+		}
+	}
+
+	forwarder.mutex.Lock()
+	defer forwarder.mutex.Unlock()
+
+	if forwarder.snapshot != nil {
+		forwarder.snapshot[key] = true
+	}
+
+	forwarder.send(key, payload)
+}
+
+// sendSnapshot sends an item from the store contents, unless it has been updated since
+func (forwarder *Forwarder) sendSnapshot(key string, item interface{}) {
+	payload, err := json.Marshal(item)
+
+	if err != nil {
+		log.Error().Err(err).Str("key", key).Msg("Could not encode message")
+		return
+	}
+
+	forwarder.mutex.Lock()
+	defer forwarder.mutex.Unlock()
+
+	if !forwarder.snapshot[key] {
+		forwarder.send(key, payload)
+	}
+}
+
+func isHidden(item interface{}) bool {
+	switch item := item.(type) {
+	case models.Departure:
+		return item.Hidden
+	case models.Arrival:
+		return item.Hidden
+	case models.Service:
+		return item.Hidden
+	}
+
+	return false
+}
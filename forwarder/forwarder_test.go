@@ -0,0 +1,96 @@
+package forwarder
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/forwarder/forwardertest"
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+var testKeys = Keys{
+	Departure: func(departure models.Departure) string { return "departures/" + departure.ID },
+	Arrival:   func(arrival models.Arrival) string { return "arrivals/" + arrival.ID },
+	Service:   func(service models.Service) string { return "services/" + service.ID },
+}
+
+func TestForwarder(t *testing.T) {
+	collection := forwardertest.NewCollection()
+
+	// Departure in the store before starting:
+	existing := forwardertest.GenerateDeparture()
+	existing.ServiceID = "5678"
+	existing.GenerateID()
+	collection.DepartureStore.ProcessDeparture(existing)
+
+	recorder := forwardertest.NewRecorder()
+	forwarder := NewForwarder(collection, testKeys, recorder.Send)
+	forwarder.Start(true)
+
+	if _, exists := recorder.Message("departures/" + existing.ID); !exists {
+		t.Error("Existing departure should be sent on start")
+	}
+
+	departure := forwardertest.GenerateDeparture()
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	key := "departures/" + departure.ID
+	payload, _ := recorder.Message(key)
+	var message map[string]interface{}
+
+	if err := json.Unmarshal(payload, &message); err != nil {
+		t.Fatalf("Invalid message for %s: %s", key, err)
+	}
+
+	if message["platform_actual"] != "5" || message["service_number"] != "1234" {
+		t.Errorf("Wrong message: %v", message)
+	}
+
+	// Cleanup should send tombstones:
+	collection.DepartureStore.CleanUp(departure.DepartureTime.Add(15 * time.Minute))
+
+	if payload, exists := recorder.Message(key); !exists || len(payload) != 0 {
+		t.Error("Hidden departure should be sent as tombstone")
+	}
+
+	forwarder.Stop()
+	count := recorder.Count()
+	collection.DepartureStore.ProcessDeparture(forwardertest.GenerateDeparture())
+
+	if recorder.Count() != count {
+		t.Error("Stopped forwarder should not send")
+	}
+}
+
+func TestForwarderWithoutContents(t *testing.T) {
+	collection := forwardertest.NewCollection()
+	collection.DepartureStore.ProcessDeparture(forwardertest.GenerateDeparture())
+
+	recorder := forwardertest.NewRecorder()
+	forwarder := NewForwarder(collection, testKeys, recorder.Send)
+	forwarder.Start(false)
+	defer forwarder.Stop()
+
+	if recorder.Count() != 0 {
+		t.Error("Store contents should not be sent")
+	}
+}
+
+func TestForwarderSnapshotOrder(t *testing.T) {
+	recorder := forwardertest.NewRecorder()
+	forwarder := NewForwarder(forwardertest.NewCollection(), testKeys, recorder.Send)
+
+	departure := forwardertest.GenerateDeparture()
+	key := testKeys.Departure(departure)
+
+	// Departure is removed while the (older) store contents are being sent:
+	forwarder.snapshot = make(map[string]bool)
+	forwarder.forward(key, stores.Change{Action: stores.ChangeRemoved, Item: departure}, nil)
+	forwarder.sendSnapshot(key, map[string]interface{}{"service_id": departure.ServiceID})
+
+	if payload, exists := recorder.Message(key); !exists || len(payload) != 0 {
+		t.Error("Store contents should not replace the tombstone of a newer change")
+	}
+}
@@ -0,0 +1,103 @@
+// Package forwardertest provides test fixtures for the publishers which use a forwarder
+package forwardertest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+// Recorder records the last message by key. It can be used as MQTT client and as relay sender.
+type Recorder struct {
+	mutex    sync.Mutex
+	messages map[string][]byte
+	count    int
+	closed   bool
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{messages: make(map[string][]byte)}
+}
+
+// Send records a message
+func (recorder *Recorder) Send(key string, payload []byte) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.messages[key] = payload
+	recorder.count++
+}
+
+// Publish records a message
+func (recorder *Recorder) Publish(topic string, payload []byte) {
+	recorder.Send(topic, payload)
+}
+
+// Close marks the recorder as closed
+func (recorder *Recorder) Close() {
+	recorder.mutex.Lock()
+	recorder.closed = true
+	recorder.mutex.Unlock()
+}
+
+// Disconnect marks the recorder as closed
+func (recorder *Recorder) Disconnect() {
+	recorder.Close()
+}
+
+// Message returns the last message with the given key
+func (recorder *Recorder) Message(key string) ([]byte, bool) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	payload, exists := recorder.messages[key]
+
+	return payload, exists
+}
+
+// Count returns the number of recorded messages
+func (recorder *Recorder) Count() int {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.count
+}
+
+// Closed returns true when the recorder has been closed
+func (recorder *Recorder) Closed() bool {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.closed
+}
+
+// GenerateDeparture returns a departure of service 1234 from UT
+func GenerateDeparture() models.Departure {
+	var departure models.Departure
+
+	departure.ProductID = "12345"
+	departure.ServiceID = "1234"
+	departure.ServiceNumber = "1234"
+	departure.ServiceTypeCode = "IC"
+	departure.Station.Code = "UT"
+	departure.ServiceDate = "2019-01-27"
+	departure.GenerateID()
+	departure.Timestamp = time.Date(2019, time.January, 27, 12, 34, 56, 0, time.UTC)
+	departure.DepartureTime = time.Date(2019, time.January, 27, 12, 34, 56, 0, time.UTC)
+	departure.PlatformActual = "5"
+
+	return departure
+}
+
+// NewCollection creates an empty store collection
+func NewCollection() *stores.StoreCollection {
+	collection := &stores.StoreCollection{}
+	collection.DepartureStore.InitStore()
+	collection.ArrivalStore.InitStore()
+	collection.ServiceStore.InitStore()
+
+	return collection
+}
@@ -0,0 +1,298 @@
+package gtfsrt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/rijdendetreinen/gotrain/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// alertEffects contains the modification types which result in an alert, with the effect for travellers
+var alertEffects = map[int]gtfs.Alert_Effect{
+	models.ModificationCancelledTrain:       gtfs.Alert_NO_SERVICE,
+	models.ModificationCancelledDeparture:   gtfs.Alert_NO_SERVICE,
+	models.ModificationCancelledArrival:     gtfs.Alert_NO_SERVICE,
+	models.ModificationDiverted:             gtfs.Alert_DETOUR,
+	models.ModificationRouteShortened:       gtfs.Alert_REDUCED_SERVICE,
+	models.ModificationOriginRouteShortened: gtfs.Alert_REDUCED_SERVICE,
+	models.ModificationRouteExtended:        gtfs.Alert_ADDITIONAL_SERVICE,
+	models.ModificationOriginRouteExtended:  gtfs.Alert_ADDITIONAL_SERVICE,
+	models.ModificationChangedDestination:   gtfs.Alert_MODIFIED_SERVICE,
+	models.ModificationChangedOrigin:        gtfs.Alert_MODIFIED_SERVICE,
+	models.ModificationChangedStopPattern:   gtfs.Alert_MODIFIED_SERVICE,
+	models.ModificationBusReplacement:       gtfs.Alert_MODIFIED_SERVICE,
+}
+
+// stopModifications are the modification types which only apply to a single stop of a trip
+var stopModifications = map[int]bool{
+	models.ModificationCancelledDeparture: true,
+	models.ModificationCancelledArrival:   true,
+	models.ModificationChangedStopPattern: true,
+}
+
+// alertCauses maps (parts of) InfoPlus causes to alert causes. The first match is used.
+var alertCauses = []struct {
+	keyword string
+	cause   gtfs.Alert_Cause
+}{
+	{"acties", gtfs.Alert_STRIKE},
+	{"aanrijding", gtfs.Alert_ACCIDENT},
+	{"politie", gtfs.Alert_POLICE_ACTIVITY},
+	{"aanleg", gtfs.Alert_CONSTRUCTION},
+	{"werkzaamheden", gtfs.Alert_MAINTENANCE},
+	{"reparatie", gtfs.Alert_MAINTENANCE},
+	{"weer", gtfs.Alert_WEATHER},
+	{"sneeuw", gtfs.Alert_WEATHER},
+	{"storm", gtfs.Alert_WEATHER},
+	{"rijp", gtfs.Alert_WEATHER},
+	{"gladde", gtfs.Alert_WEATHER},
+	{"storing", gtfs.Alert_TECHNICAL_PROBLEM},
+	{"defect", gtfs.Alert_TECHNICAL_PROBLEM},
+	{"beschadig", gtfs.Alert_TECHNICAL_PROBLEM},
+	{"ontspoorde", gtfs.Alert_TECHNICAL_PROBLEM},
+	{"gestrande", gtfs.Alert_TECHNICAL_PROBLEM},
+}
+
+// trip identifies the trip an alert applies to
+type trip struct {
+	tripID      string
+	serviceDate string
+}
+
+// alertBuilder collects modifications and merges identical modifications reported by
+// multiple services and departures into a single alert
+type alertBuilder struct {
+	mapping Mapping
+	alerts  map[string]*alert
+}
+
+// alert is an alert which is being built
+type alert struct {
+	modification models.Modification
+	trip         trip
+	stops        map[string]struct{}
+	end          time.Time
+}
+
+// Alerts creates an Alerts feed from the modifications of the given services and departures.
+// Hidden services and departures are ignored, so alerts disappear when their items are hidden or removed.
+func Alerts(services []models.Service, departures []models.Departure, mapping Mapping, currentTime time.Time) *gtfs.FeedMessage {
+	builder := &alertBuilder{
+		mapping: mapping,
+		alerts:  make(map[string]*alert),
+	}
+
+	for _, service := range services {
+		if !service.Hidden {
+			builder.addService(service)
+		}
+	}
+
+	for _, departure := range departures {
+		if !departure.Hidden {
+			builder.addDeparture(departure)
+		}
+	}
+
+	return builder.feed(currentTime)
+}
+
+// addService adds the modifications of a service, its parts and its stops
+func (builder *alertBuilder) addService(service models.Service) {
+	for _, part := range service.ServiceParts {
+		serviceTrip := trip{builder.mapping.TripID(service, part), service.ServiceDate}
+		end := partEnd(part)
+
+		for _, modification := range service.Modifications {
+			builder.add(modification, serviceTrip, models.Station{}, end)
+		}
+
+		for _, modification := range part.Modifications {
+			builder.add(modification, serviceTrip, models.Station{}, end)
+		}
+
+		for _, stop := range part.Stops {
+			for _, modification := range stop.Modifications {
+				builder.add(modification, serviceTrip, stop.Station, end)
+			}
+		}
+	}
+}
+
+// addDeparture adds the modifications of a departure
+func (builder *alertBuilder) addDeparture(departure models.Departure) {
+	departureTrip := trip{builder.mapping.DepartureTripID(departure), departure.ServiceDate}
+
+	for _, modification := range departure.Modifications {
+		builder.add(modification, departureTrip, departure.Station, departure.RealDepartureTime())
+	}
+}
+
+// add adds a modification for a trip, reported at the given station. Modifications are merged
+// when they have the same type and trip, and (for modifications of a single stop) the same stop.
+func (builder *alertBuilder) add(modification models.Modification, modificationTrip trip, station models.Station, end time.Time) {
+	if _, relevant := alertEffects[modification.ModificationType]; !relevant {
+		return
+	}
+
+	key := fmt.Sprintf("%s-%s-%d", modificationTrip.serviceDate, modificationTrip.tripID, modification.ModificationType)
+
+	if stopModifications[modification.ModificationType] {
+		if station.Code == "" {
+			station = modification.Station
+		}
+
+		key += "-" + station.Code
+	} else {
+		station = models.Station{}
+
+		if modification.Station.Code != "" {
+			key += "-" + modification.Station.Code
+		}
+	}
+
+	existing, exists := builder.alerts[key]
+
+	if !exists {
+		existing = &alert{
+			modification: modification,
+			trip:         modificationTrip,
+			stops:        make(map[string]struct{}),
+		}
+
+		builder.alerts[key] = existing
+	}
+
+	// Prefer the modification which has a cause:
+	if existing.modification.CauseLong == "" && modification.CauseLong != "" {
+		existing.modification = modification
+	}
+
+	if station.Code != "" {
+		existing.stops[builder.mapping.StopID(station)] = struct{}{}
+	}
+
+	if end.After(existing.end) {
+		existing.end = end
+	}
+}
+
+// feed creates the feed message with all collected alerts
+func (builder *alertBuilder) feed(currentTime time.Time) *gtfs.FeedMessage {
+	feed := NewFeedMessage(currentTime)
+
+	keys := make([]string, 0, len(builder.alerts))
+
+	for key := range builder.alerts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		feed.Entity = append(feed.Entity, &gtfs.FeedEntity{
+			Id:    proto.String(key),
+			Alert: builder.alerts[key].toGTFS(),
+		})
+	}
+
+	return feed
+}
+
+// toGTFS converts the alert to a GTFS-RT alert
+func (alert *alert) toGTFS() *gtfs.Alert {
+	modification := alert.modification
+
+	result := &gtfs.Alert{
+		Cause:      alertCause(modification.CauseLong).Enum(),
+		Effect:     alertEffects[modification.ModificationType].Enum(),
+		HeaderText: translatedRemark(modification),
+	}
+
+	if !alert.end.IsZero() {
+		result.ActivePeriod = []*gtfs.TimeRange{{End: proto.Uint64(uint64(alert.end.Unix()))}}
+	}
+
+	tripDescriptor := &gtfs.TripDescriptor{
+		TripId:    proto.String(alert.trip.tripID),
+		StartDate: proto.String(strings.ReplaceAll(alert.trip.serviceDate, "-", "")),
+	}
+
+	if len(alert.stops) == 0 {
+		result.InformedEntity = []*gtfs.EntitySelector{{Trip: tripDescriptor}}
+	}
+
+	stops := make([]string, 0, len(alert.stops))
+
+	for stop := range alert.stops {
+		stops = append(stops, stop)
+	}
+
+	sort.Strings(stops)
+
+	for _, stop := range stops {
+		result.InformedEntity = append(result.InformedEntity, &gtfs.EntitySelector{
+			Trip:   tripDescriptor,
+			StopId: proto.String(stop),
+		})
+	}
+
+	return result
+}
+
+// translatedRemark returns the Dutch and English remark for a modification
+func translatedRemark(modification models.Modification) *gtfs.TranslatedString {
+	translated := &gtfs.TranslatedString{}
+
+	for _, language := range []string{"nl", "en"} {
+		remark, hasRemark := modification.Remark(language)
+
+		if hasRemark {
+			translated.Translation = append(translated.Translation, &gtfs.TranslatedString_Translation{
+				Text:     proto.String(remark),
+				Language: proto.String(language),
+			})
+		}
+	}
+
+	return translated
+}
+
+// alertCause determines the alert cause based on the (Dutch) InfoPlus cause
+func alertCause(causeLong string) gtfs.Alert_Cause {
+	if causeLong == "" {
+		return gtfs.Alert_UNKNOWN_CAUSE
+	}
+
+	cause := strings.ToLower(causeLong)
+
+	for _, mapping := range alertCauses {
+		if strings.Contains(cause, mapping.keyword) {
+			return mapping.cause
+		}
+	}
+
+	return gtfs.Alert_OTHER_CAUSE
+}
+
+// partEnd returns the expected time of the last arrival or departure of a service part
+func partEnd(part models.ServicePart) time.Time {
+	var end time.Time
+
+	for _, stop := range part.Stops {
+		for _, expected := range []time.Time{
+			stop.ArrivalTime.Add(time.Duration(stop.ArrivalDelay) * time.Second),
+			stop.DepartureTime.Add(time.Duration(stop.DepartureDelay) * time.Second),
+		} {
+			if expected.After(end) {
+				end = expected
+			}
+		}
+	}
+
+	return end
+}
@@ -0,0 +1,129 @@
+package gtfsrt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func generateDeparture(station string) models.Departure {
+	var departure models.Departure
+
+	departure.ServiceID = "1234"
+	departure.ServiceNumber = "1234"
+	departure.ServiceDate = "2019-01-27"
+	departure.Station.Code = station
+	departure.GenerateID()
+	departure.DepartureTime = time.Date(2019, time.January, 27, 12, 30, 0, 0, time.UTC)
+
+	return departure
+}
+
+func TestAlertsDeduplicated(t *testing.T) {
+	cancelled := models.Modification{ModificationType: models.ModificationCancelledTrain, CauseLong: "door een seinstoring"}
+
+	service := generateService()
+	service.Modifications = []models.Modification{cancelled}
+
+	departure1 := generateDeparture("UT")
+	departure1.Modifications = []models.Modification{cancelled}
+	departure2 := generateDeparture("ASB")
+	departure2.Modifications = []models.Modification{cancelled}
+
+	feed := Alerts([]models.Service{service}, []models.Departure{departure1, departure2}, Mapping{TripIDTemplate: DefaultTripIDTemplate, StopIDTemplate: DefaultStopIDTemplate}, time.Now())
+
+	if len(feed.Entity) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(feed.Entity))
+	}
+
+	alert := feed.Entity[0].Alert
+
+	if alert.GetEffect() != gtfs.Alert_NO_SERVICE {
+		t.Errorf("Wrong effect: %s", alert.GetEffect())
+	}
+
+	if alert.GetCause() != gtfs.Alert_TECHNICAL_PROBLEM {
+		t.Errorf("Wrong cause: %s", alert.GetCause())
+	}
+
+	if len(alert.InformedEntity) != 1 || alert.InformedEntity[0].Trip.GetTripId() != "1234" || alert.InformedEntity[0].StopId != nil {
+		t.Errorf("Wrong informed entities: %v", alert.InformedEntity)
+	}
+
+	translations := alert.HeaderText.GetTranslation()
+
+	if len(translations) != 2 {
+		t.Fatalf("Expected NL and EN translations, got %d", len(translations))
+	}
+
+	if translations[0].GetText() != "Trein rijdt niet door een seinstoring" || translations[1].GetText() != "Cancelled due to signal failure" {
+		t.Errorf("Wrong translations: %v", translations)
+	}
+
+	// The alert should expire after the last arrival of the service:
+	if alert.ActivePeriod[0].GetEnd() != uint64(time.Date(2019, time.January, 27, 13, 0, 0, 0, time.UTC).Unix()) {
+		t.Errorf("Wrong end of active period: %d", alert.ActivePeriod[0].GetEnd())
+	}
+}
+
+func TestAlertsStopModifications(t *testing.T) {
+	cancelled := models.Modification{ModificationType: models.ModificationCancelledDeparture}
+	delayed := models.Modification{ModificationType: models.ModificationDelayedDeparture, CauseLong: "door een seinstoring"}
+
+	departure1 := generateDeparture("UT")
+	departure1.Modifications = []models.Modification{cancelled, delayed}
+	departure2 := generateDeparture("ASB")
+	departure2.Modifications = []models.Modification{cancelled}
+
+	feed := Alerts(nil, []models.Departure{departure1, departure2}, Mapping{TripIDTemplate: DefaultTripIDTemplate, StopIDTemplate: DefaultStopIDTemplate}, time.Now())
+
+	// Delays do not result in alerts, cancelled departures result in an alert per station:
+	if len(feed.Entity) != 2 {
+		t.Fatalf("Expected 2 alerts, got %d", len(feed.Entity))
+	}
+
+	for _, entity := range feed.Entity {
+		if len(entity.Alert.InformedEntity) != 1 || entity.Alert.InformedEntity[0].StopId == nil {
+			t.Errorf("Alert should apply to a single stop: %v", entity.Alert.InformedEntity)
+		}
+
+		if entity.Alert.GetCause() != gtfs.Alert_UNKNOWN_CAUSE {
+			t.Errorf("Wrong cause: %s", entity.Alert.GetCause())
+		}
+	}
+}
+
+func TestAlertsHiddenItems(t *testing.T) {
+	departure := generateDeparture("UT")
+	departure.Modifications = []models.Modification{{ModificationType: models.ModificationBusReplacement}}
+	departure.Hidden = true
+
+	feed := Alerts(nil, []models.Departure{departure}, Mapping{TripIDTemplate: DefaultTripIDTemplate, StopIDTemplate: DefaultStopIDTemplate}, time.Now())
+
+	if len(feed.Entity) != 0 {
+		t.Error("Hidden departures should not result in alerts")
+	}
+}
+
+func TestAlertCause(t *testing.T) {
+	tables := []struct {
+		cause    string
+		expected gtfs.Alert_Cause
+	}{
+		{"", gtfs.Alert_UNKNOWN_CAUSE},
+		{"door acties van het personeel", gtfs.Alert_STRIKE},
+		{"door een aanrijding met een persoon", gtfs.Alert_ACCIDENT},
+		{"door werkzaamheden", gtfs.Alert_MAINTENANCE},
+		{"door de weersomstandigheden", gtfs.Alert_WEATHER},
+		{"door een defecte trein", gtfs.Alert_TECHNICAL_PROBLEM},
+		{"door diverse oorzaken", gtfs.Alert_OTHER_CAUSE},
+	}
+
+	for _, table := range tables {
+		if cause := alertCause(table.cause); cause != table.expected {
+			t.Errorf("Cause %q: expected %s, got %s", table.cause, table.expected, cause)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package gtfsrt
+
+import (
+	"strings"
+
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/spf13/viper"
+)
+
+// DefaultTripIDTemplate is the default template for trip IDs
+const DefaultTripIDTemplate = "{service_number}"
+
+// DefaultStopIDTemplate is the default template for stop IDs
+const DefaultStopIDTemplate = "{station}"
+
+// Mapping translates services and stations to the trip IDs and stop IDs of a static GTFS feed.
+//
+// The trip ID template may contain the placeholders {service_id}, {service_number}, {service_date}
+// (2019-01-27), {service_date_compact} (20190127), {company} and {line_number}. The stop ID template
+// may contain {station} (uppercase station code) and {station_lower}.
+type Mapping struct {
+	TripIDTemplate string
+	StopIDTemplate string
+}
+
+// MappingFromConfig returns the mapping configured in the gtfsrt: section
+func MappingFromConfig() Mapping {
+	mapping := Mapping{
+		TripIDTemplate: viper.GetString("gtfsrt.trip_id"),
+		StopIDTemplate: viper.GetString("gtfsrt.stop_id"),
+	}
+
+	if mapping.TripIDTemplate == "" {
+		mapping.TripIDTemplate = DefaultTripIDTemplate
+	}
+	if mapping.StopIDTemplate == "" {
+		mapping.StopIDTemplate = DefaultStopIDTemplate
+	}
+
+	return mapping
+}
+
+// TripID returns the trip ID for a service part
+func (mapping Mapping) TripID(service models.Service, part models.ServicePart) string {
+	serviceNumber := part.ServiceNumber
+
+	if serviceNumber == "" {
+		serviceNumber = service.ServiceNumber
+	}
+
+	return mapping.tripID(service.ID, serviceNumber, service.ServiceDate, service.Company, service.LineNumber)
+}
+
+// DepartureTripID returns the trip ID for the service of a departure
+func (mapping Mapping) DepartureTripID(departure models.Departure) string {
+	serviceID := departure.ServiceDate + "-" + departure.ServiceNumber
+
+	return mapping.tripID(serviceID, departure.ServiceNumber, departure.ServiceDate, departure.Company, departure.LineNumber)
+}
+
+func (mapping Mapping) tripID(serviceID, serviceNumber, serviceDate, company, lineNumber string) string {
+	replacer := strings.NewReplacer(
+		"{service_id}", serviceID,
+		"{service_number}", serviceNumber,
+		"{service_date}", serviceDate,
+		"{service_date_compact}", strings.ReplaceAll(serviceDate, "-", ""),
+		"{company}", company,
+		"{line_number}", lineNumber,
+	)
+
+	return replacer.Replace(mapping.TripIDTemplate)
+}
+
+// StopID returns the stop ID for a station
+func (mapping Mapping) StopID(station models.Station) string {
+	replacer := strings.NewReplacer(
+		"{station}", station.Code,
+		"{station_lower}", strings.ToLower(station.Code),
+	)
+
+	return replacer.Replace(mapping.StopIDTemplate)
+}
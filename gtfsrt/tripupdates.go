@@ -0,0 +1,171 @@
+// Package gtfsrt exports the contents of the stores as GTFS-Realtime feeds
+package gtfsrt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/rijdendetreinen/gotrain/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// gtfsRealtimeVersion is the version of the GTFS-Realtime specification
+const gtfsRealtimeVersion = "2.0"
+
+// NewFeedMessage creates an empty full dataset feed message
+func NewFeedMessage(currentTime time.Time) *gtfs.FeedMessage {
+	return &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: proto.String(gtfsRealtimeVersion),
+			Incrementality:      gtfs.FeedHeader_FULL_DATASET.Enum(),
+			Timestamp:           proto.Uint64(uint64(currentTime.Unix())),
+		},
+	}
+}
+
+// TripUpdates creates a TripUpdates feed for the given services, with a trip update for each service part
+func TripUpdates(services []models.Service, mapping Mapping, currentTime time.Time) *gtfs.FeedMessage {
+	feed := NewFeedMessage(currentTime)
+
+	for _, service := range services {
+		if service.Hidden {
+			continue
+		}
+
+		for index, part := range service.ServiceParts {
+			tripUpdate := TripUpdate(service, part, mapping)
+
+			// Trip IDs are not unique across service dates, so the entity ID is based on the service ID:
+			feed.Entity = append(feed.Entity, &gtfs.FeedEntity{
+				Id:         proto.String(fmt.Sprintf("%s-%d", service.ID, index)),
+				TripUpdate: tripUpdate,
+			})
+		}
+	}
+
+	return feed
+}
+
+// TripUpdate creates a trip update for a single service part
+func TripUpdate(service models.Service, part models.ServicePart, mapping Mapping) *gtfs.TripUpdate {
+	relationship := gtfs.TripDescriptor_SCHEDULED
+
+	if hasModification(service.Modifications, models.ModificationExtraTrain) || hasModification(part.Modifications, models.ModificationExtraTrain) {
+		relationship = gtfs.TripDescriptor_ADDED
+	}
+
+	tripUpdate := &gtfs.TripUpdate{
+		Trip: &gtfs.TripDescriptor{
+			TripId:               proto.String(mapping.TripID(service, part)),
+			StartDate:            proto.String(strings.ReplaceAll(service.ServiceDate, "-", "")),
+			ScheduleRelationship: relationship.Enum(),
+		},
+	}
+
+	if !service.Timestamp.IsZero() {
+		tripUpdate.Timestamp = proto.Uint64(uint64(service.Timestamp.Unix()))
+	}
+
+	if isCancelled(service, part) {
+		tripUpdate.Trip.ScheduleRelationship = gtfs.TripDescriptor_CANCELED.Enum()
+
+		return tripUpdate
+	}
+
+	var sequence uint32
+
+	for _, stop := range part.Stops {
+		if !stop.IsStopping() {
+			continue
+		}
+
+		sequence++
+
+		tripUpdate.StopTimeUpdate = append(tripUpdate.StopTimeUpdate, stopTimeUpdate(stop, sequence, mapping))
+	}
+
+	return tripUpdate
+}
+
+// stopTimeUpdate creates the update for a single stop. Stops which are no longer called at,
+// or of which both the arrival and departure are cancelled, are skipped.
+func stopTimeUpdate(stop models.ServiceStop, sequence uint32, mapping Mapping) *gtfs.TripUpdate_StopTimeUpdate {
+	update := &gtfs.TripUpdate_StopTimeUpdate{
+		StopSequence: proto.Uint32(sequence),
+		StopId:       proto.String(mapping.StopID(stop.Station)),
+	}
+
+	if isSkipped(stop) {
+		update.ScheduleRelationship = gtfs.TripUpdate_StopTimeUpdate_SKIPPED.Enum()
+
+		return update
+	}
+
+	if hasArrival(stop) {
+		update.Arrival = stopTimeEvent(stop.ArrivalTime, stop.ArrivalDelay)
+	}
+
+	if hasDeparture(stop) {
+		update.Departure = stopTimeEvent(stop.DepartureTime, stop.DepartureDelay)
+	}
+
+	return update
+}
+
+// hasArrival checks whether the stop has an arrival which is not cancelled
+func hasArrival(stop models.ServiceStop) bool {
+	return !stop.ArrivalTime.IsZero() && !stop.ArrivalCancelled
+}
+
+// hasDeparture checks whether the stop has a departure which is not cancelled
+func hasDeparture(stop models.ServiceStop) bool {
+	return !stop.DepartureTime.IsZero() && !stop.DepartureCancelled
+}
+
+// isSkipped checks whether a planned stop is no longer called at
+func isSkipped(stop models.ServiceStop) bool {
+	return !stop.StoppingActual || (!hasArrival(stop) && !hasDeparture(stop))
+}
+
+// stopTimeEvent creates an event with the delay and the expected time
+func stopTimeEvent(plannedTime time.Time, delay int) *gtfs.TripUpdate_StopTimeEvent {
+	return &gtfs.TripUpdate_StopTimeEvent{
+		Delay: proto.Int32(int32(delay)),
+		Time:  proto.Int64(plannedTime.Add(time.Duration(delay) * time.Second).Unix()),
+	}
+}
+
+// isCancelled checks whether the service part is cancelled: either explicitly, or because
+// none of its stops are still called at
+func isCancelled(service models.Service, part models.ServicePart) bool {
+	if hasModification(service.Modifications, models.ModificationCancelledTrain) || hasModification(part.Modifications, models.ModificationCancelledTrain) {
+		return true
+	}
+
+	stops := part.GetStoppingStations()
+
+	if len(stops) == 0 {
+		return false
+	}
+
+	for _, stop := range stops {
+		if !isSkipped(stop) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasModification checks whether a modification of the given type is present
+func hasModification(modifications []models.Modification, modificationType int) bool {
+	for _, modification := range modifications {
+		if modification.ModificationType == modificationType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,176 @@
+package gtfsrt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func generateService() models.Service {
+	var service models.Service
+
+	service.ServiceNumber = "1234"
+	service.ServiceDate = "2019-01-27"
+	service.Company = "NS"
+	service.GenerateID()
+	service.Timestamp = time.Date(2019, time.January, 27, 12, 0, 0, 0, time.UTC)
+
+	departure := time.Date(2019, time.January, 27, 12, 30, 0, 0, time.UTC)
+
+	stop := func(code string, minutes int) models.ServiceStop {
+		return models.ServiceStop{
+			Station:         models.Station{Code: code},
+			StoppingActual:  true,
+			StoppingPlanned: true,
+			ArrivalTime:     departure.Add(time.Duration(minutes) * time.Minute),
+			DepartureTime:   departure.Add(time.Duration(minutes+1) * time.Minute),
+		}
+	}
+
+	first := stop("UT", 0)
+	first.ArrivalTime = time.Time{}
+	last := stop("ASD", 30)
+	last.DepartureTime = time.Time{}
+
+	service.ServiceParts = []models.ServicePart{{
+		ServiceNumber: "1234",
+		Stops: []models.ServiceStop{
+			first,
+			stop("ASB", 15),
+			{Station: models.Station{Code: "DVD"}},
+			last,
+		},
+	}}
+
+	return service
+}
+
+func TestTripUpdate(t *testing.T) {
+	service := generateService()
+	service.ServiceParts[0].Stops[1].ArrivalDelay = 120
+	service.ServiceParts[0].Stops[1].DepartureDelay = 60
+
+	tripUpdate := TripUpdate(service, service.ServiceParts[0], Mapping{TripIDTemplate: "{service_date_compact}:{service_number}", StopIDTemplate: "{station_lower}"})
+
+	if tripUpdate.Trip.GetTripId() != "20190127:1234" {
+		t.Errorf("Wrong trip ID: %s", tripUpdate.Trip.GetTripId())
+	}
+
+	if tripUpdate.Trip.GetStartDate() != "20190127" {
+		t.Errorf("Wrong start date: %s", tripUpdate.Trip.GetStartDate())
+	}
+
+	if tripUpdate.Trip.GetScheduleRelationship() != gtfs.TripDescriptor_SCHEDULED {
+		t.Errorf("Wrong schedule relationship: %s", tripUpdate.Trip.GetScheduleRelationship())
+	}
+
+	// Passing stations should not be included:
+	if len(tripUpdate.StopTimeUpdate) != 3 {
+		t.Fatalf("Wrong number of stop time updates: %d", len(tripUpdate.StopTimeUpdate))
+	}
+
+	first := tripUpdate.StopTimeUpdate[0]
+
+	if first.GetStopId() != "ut" || first.GetStopSequence() != 1 || first.Arrival != nil || first.Departure == nil {
+		t.Errorf("Wrong stop time update for first stop: %v", first)
+	}
+
+	second := tripUpdate.StopTimeUpdate[1]
+
+	if second.GetArrival().GetDelay() != 120 || second.GetDeparture().GetDelay() != 60 {
+		t.Errorf("Wrong delays: %v", second)
+	}
+
+	expectedArrival := service.ServiceParts[0].Stops[1].ArrivalTime.Add(2 * time.Minute).Unix()
+
+	if second.GetArrival().GetTime() != expectedArrival {
+		t.Errorf("Wrong arrival time: %d, expected %d", second.GetArrival().GetTime(), expectedArrival)
+	}
+}
+
+func TestTripUpdateSkippedStops(t *testing.T) {
+	service := generateService()
+	service.ServiceParts[0].Stops[1].StoppingActual = false
+	service.ServiceParts[0].Stops[3].ArrivalCancelled = true
+
+	tripUpdate := TripUpdate(service, service.ServiceParts[0], Mapping{TripIDTemplate: DefaultTripIDTemplate, StopIDTemplate: DefaultStopIDTemplate})
+
+	for index, expected := range []gtfs.TripUpdate_StopTimeUpdate_ScheduleRelationship{
+		gtfs.TripUpdate_StopTimeUpdate_SCHEDULED,
+		gtfs.TripUpdate_StopTimeUpdate_SKIPPED,
+		gtfs.TripUpdate_StopTimeUpdate_SKIPPED,
+	} {
+		if tripUpdate.StopTimeUpdate[index].GetScheduleRelationship() != expected {
+			t.Errorf("Stop %d: expected %s, got %s", index, expected, tripUpdate.StopTimeUpdate[index].GetScheduleRelationship())
+		}
+	}
+
+	if tripUpdate.Trip.GetScheduleRelationship() != gtfs.TripDescriptor_SCHEDULED {
+		t.Error("Trip with remaining stops should not be cancelled")
+	}
+}
+
+func TestTripUpdateCancelled(t *testing.T) {
+	service := generateService()
+
+	for index := range service.ServiceParts[0].Stops {
+		service.ServiceParts[0].Stops[index].ArrivalCancelled = true
+		service.ServiceParts[0].Stops[index].DepartureCancelled = true
+	}
+
+	tripUpdate := TripUpdate(service, service.ServiceParts[0], Mapping{TripIDTemplate: DefaultTripIDTemplate, StopIDTemplate: DefaultStopIDTemplate})
+
+	if tripUpdate.Trip.GetScheduleRelationship() != gtfs.TripDescriptor_CANCELED {
+		t.Errorf("Wrong schedule relationship: %s", tripUpdate.Trip.GetScheduleRelationship())
+	}
+
+	if len(tripUpdate.StopTimeUpdate) != 0 {
+		t.Error("Cancelled trip should not have stop time updates")
+	}
+}
+
+func TestTripUpdateExtraTrain(t *testing.T) {
+	service := generateService()
+	service.Modifications = []models.Modification{{ModificationType: models.ModificationExtraTrain}}
+
+	tripUpdate := TripUpdate(service, service.ServiceParts[0], Mapping{TripIDTemplate: DefaultTripIDTemplate, StopIDTemplate: DefaultStopIDTemplate})
+
+	if tripUpdate.Trip.GetScheduleRelationship() != gtfs.TripDescriptor_ADDED {
+		t.Errorf("Wrong schedule relationship: %s", tripUpdate.Trip.GetScheduleRelationship())
+	}
+}
+
+func TestTripUpdatesFeed(t *testing.T) {
+	hidden := generateService()
+	hidden.ServiceNumber = "5678"
+	hidden.Hidden = true
+
+	feed := TripUpdates([]models.Service{generateService(), hidden}, Mapping{TripIDTemplate: DefaultTripIDTemplate, StopIDTemplate: DefaultStopIDTemplate}, time.Now())
+
+	if feed.Header.GetGtfsRealtimeVersion() != "2.0" {
+		t.Error("Wrong GTFS-RT version")
+	}
+
+	if len(feed.Entity) != 1 || feed.Entity[0].GetId() != "2019-01-27-1234-0" {
+		t.Errorf("Expected a single entity for the visible service, got %d", len(feed.Entity))
+	}
+}
+
+func TestTripUpdatesFeedEntityIDs(t *testing.T) {
+	nextDay := generateService()
+	nextDay.ServiceDate = "2019-01-28"
+	nextDay.GenerateID()
+
+	feed := TripUpdates([]models.Service{generateService(), nextDay}, Mapping{TripIDTemplate: DefaultTripIDTemplate, StopIDTemplate: DefaultStopIDTemplate}, time.Now())
+
+	if len(feed.Entity) != 2 {
+		t.Fatalf("Expected 2 entities, got %d", len(feed.Entity))
+	}
+
+	// Same trip ID on two service dates, but the entity IDs must be unique:
+	if feed.Entity[0].GetId() == feed.Entity[1].GetId() {
+		t.Errorf("Duplicate entity ID %s", feed.Entity[0].GetId())
+	}
+}
@@ -0,0 +1,112 @@
+package mqtt
+
+import (
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// disconnectQuiesce is the time (in milliseconds) to wait for pending messages when disconnecting
+const disconnectQuiesce = 1000
+
+// BrokerClient publishes messages to an MQTT broker using the Paho client
+type BrokerClient struct {
+	QoS      byte
+	Retained bool
+
+	client paho.Client
+}
+
+// Connect connects to an MQTT broker. The client reconnects automatically when the connection is lost.
+func Connect(options *paho.ClientOptions, qos byte, retained bool) (*BrokerClient, error) {
+	options.SetAutoReconnect(true)
+	options.SetConnectRetry(true)
+	options.SetOnConnectHandler(func(paho.Client) {
+		log.Info().Msg("Connected to MQTT broker")
+	})
+	options.SetConnectionLostHandler(func(_ paho.Client, err error) {
+		log.Warn().Err(err).Msg("Connection to MQTT broker lost")
+	})
+
+	client := paho.NewClient(options)
+	token := client.Connect()
+
+	// With connect retry, the token only completes when the first connection has been made:
+	if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	return &BrokerClient{QoS: qos, Retained: retained, client: client}, nil
+}
+
+// Publish publishes a message without waiting for the broker
+func (client *BrokerClient) Publish(topic string, payload []byte) {
+	token := client.client.Publish(topic, client.QoS, client.Retained, payload)
+
+	// Only report errors which are known immediately (e.g. when not connected):
+	select {
+	case <-token.Done():
+		if token.Error() != nil {
+			log.Warn().Err(token.Error()).Str("topic", topic).Msg("Could not publish MQTT message")
+		}
+	default:
+	}
+}
+
+// Disconnect disconnects from the broker after sending the pending messages
+func (client *BrokerClient) Disconnect() {
+	client.client.Disconnect(disconnectQuiesce)
+}
+
+// SetupFromConfig connects to the configured MQTT broker and creates a publisher.
+// It returns nil when no broker is configured.
+func SetupFromConfig(collection *stores.StoreCollection) (*Publisher, error) {
+	broker := viper.GetString("mqtt.broker")
+
+	if broker == "" {
+		return nil, nil
+	}
+
+	options := paho.NewClientOptions().AddBroker(broker)
+	options.SetClientID("gotrain")
+
+	if viper.IsSet("mqtt.client_id") {
+		options.SetClientID(viper.GetString("mqtt.client_id"))
+	}
+	if viper.IsSet("mqtt.username") {
+		options.SetUsername(viper.GetString("mqtt.username"))
+		options.SetPassword(viper.GetString("mqtt.password"))
+	}
+
+	qos := byte(1)
+	retained := true
+
+	if viper.IsSet("mqtt.qos") {
+		qos = byte(viper.GetInt("mqtt.qos"))
+	}
+	if viper.IsSet("mqtt.retained") {
+		retained = viper.GetBool("mqtt.retained")
+	}
+
+	client, err := Connect(options, qos, retained)
+
+	if err != nil {
+		return nil, err
+	}
+
+	publisher := NewPublisher(collection, client)
+
+	if viper.IsSet("mqtt.topic_prefix") {
+		publisher.TopicPrefix = viper.GetString("mqtt.topic_prefix")
+	}
+	if viper.IsSet("mqtt.language") {
+		publisher.Language = viper.GetString("mqtt.language")
+	}
+
+	log.Info().Str("broker", broker).Str("prefix", publisher.TopicPrefix).Msg("MQTT publisher enabled")
+
+	return publisher, nil
+}
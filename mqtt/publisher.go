@@ -0,0 +1,90 @@
+package mqtt
+
+import (
+	"strings"
+
+	"github.com/rijdendetreinen/gotrain/forwarder"
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+// DefaultTopicPrefix is the prefix of all topics when no prefix is configured
+const DefaultTopicPrefix = "gotrain"
+
+// Client publishes messages to a broker
+type Client interface {
+	// Publish publishes a (retained) message. An empty payload removes the retained message.
+	Publish(topic string, payload []byte)
+	Disconnect()
+}
+
+// Publisher publishes all processed departures, arrivals and services to hierarchical topics:
+//
+//	{prefix}/departures/{station}/{service date}/{service ID}
+//	{prefix}/arrivals/{station}/{service date}/{service ID}
+//	{prefix}/services/{service date}/{service number}
+//
+// Hidden and removed items are cleared with an empty message (tombstone).
+type Publisher struct {
+	TopicPrefix string
+	Language    string
+
+	client    Client
+	forwarder *forwarder.Forwarder
+}
+
+// NewPublisher creates a publisher for the given store collection
+func NewPublisher(collection *stores.StoreCollection, client Client) *Publisher {
+	publisher := &Publisher{
+		TopicPrefix: DefaultTopicPrefix,
+		Language:    "nl",
+		client:      client,
+	}
+
+	publisher.forwarder = forwarder.NewForwarder(collection, forwarder.Keys{
+		Departure: publisher.DepartureTopic,
+		Arrival:   publisher.ArrivalTopic,
+		Service:   publisher.ServiceTopic,
+	}, client.Publish)
+
+	return publisher
+}
+
+// Start registers the publisher as listener of the stores and publishes the current contents of
+// the stores, so the retained messages are up to date
+func (publisher *Publisher) Start() {
+	publisher.forwarder.Language = publisher.Language
+	publisher.forwarder.Start(true)
+}
+
+// Stop removes the listeners from the stores and disconnects from the broker
+func (publisher *Publisher) Stop() {
+	publisher.forwarder.Stop()
+	publisher.client.Disconnect()
+}
+
+// DepartureTopic returns the topic of a departure
+func (publisher *Publisher) DepartureTopic(departure models.Departure) string {
+	return publisher.topic("departures", departure.Station.Code, departure.ServiceDate, departure.ServiceID)
+}
+
+// ArrivalTopic returns the topic of an arrival
+func (publisher *Publisher) ArrivalTopic(arrival models.Arrival) string {
+	return publisher.topic("arrivals", arrival.Station.Code, arrival.ServiceDate, arrival.ServiceID)
+}
+
+// ServiceTopic returns the topic of a service
+func (publisher *Publisher) ServiceTopic(service models.Service) string {
+	return publisher.topic("services", service.ServiceDate, service.ServiceNumber)
+}
+
+// topicLevelReplacer replaces separators and wildcards, which are not allowed within a topic level
+var topicLevelReplacer = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+func (publisher *Publisher) topic(levels ...string) string {
+	for i, level := range levels {
+		levels[i] = topicLevelReplacer.Replace(level)
+	}
+
+	return publisher.TopicPrefix + "/" + strings.Join(levels, "/")
+}
@@ -0,0 +1,121 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rijdendetreinen/gotrain/forwarder/forwardertest"
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func TestPublisher(t *testing.T) {
+	collection := forwardertest.NewCollection()
+
+	// Departure in the store before starting:
+	existing := forwardertest.GenerateDeparture()
+	existing.ServiceID = "5678"
+	existing.GenerateID()
+	collection.DepartureStore.ProcessDeparture(existing)
+
+	client := forwardertest.NewRecorder()
+	publisher := NewPublisher(collection, client)
+	publisher.Language = "en"
+	publisher.Start()
+
+	if _, exists := client.Message("gotrain/departures/UT/2019-01-27/5678"); !exists {
+		t.Error("Existing departure should be published on start")
+	}
+
+	collection.DepartureStore.ProcessDeparture(forwardertest.GenerateDeparture())
+
+	topic := "gotrain/departures/UT/2019-01-27/1234"
+	payload, _ := client.Message(topic)
+	var message map[string]interface{}
+
+	if err := json.Unmarshal(payload, &message); err != nil {
+		t.Fatalf("Invalid message on %s: %s", topic, err)
+	}
+
+	if message["service_number"] != "1234" {
+		t.Errorf("Wrong message: %v", message)
+	}
+
+	publisher.Stop()
+
+	if !client.Closed() {
+		t.Error("Stopped publisher should disconnect")
+	}
+}
+
+func TestTopics(t *testing.T) {
+	publisher := NewPublisher(forwardertest.NewCollection(), forwardertest.NewRecorder())
+	publisher.TopicPrefix = "trains"
+
+	var service models.Service
+	service.ServiceDate = "2019-01-27"
+	service.ServiceNumber = "1234"
+
+	if topic := publisher.ServiceTopic(service); topic != "trains/services/2019-01-27/1234" {
+		t.Errorf("Wrong service topic: %s", topic)
+	}
+
+	departure := forwardertest.GenerateDeparture()
+	departure.ServiceID = "12/+#"
+
+	if topic := publisher.DepartureTopic(departure); topic != "trains/departures/UT/2019-01-27/12___" {
+		t.Errorf("Wrong departure topic: %s", topic)
+	}
+}
+
+// TestBroker publishes to a local broker, e.g. GOTRAIN_MQTT_BROKER=tcp://localhost:1883
+func TestBroker(t *testing.T) {
+	broker := os.Getenv("GOTRAIN_MQTT_BROKER")
+
+	if broker == "" {
+		t.Skip("GOTRAIN_MQTT_BROKER not set")
+	}
+
+	client, err := Connect(paho.NewClientOptions().AddBroker(broker).SetClientID("gotrain-test"), 1, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collection := forwardertest.NewCollection()
+	publisher := NewPublisher(collection, client)
+	publisher.TopicPrefix = "gotrain-test"
+	publisher.Start()
+	defer publisher.Stop()
+
+	departure := forwardertest.GenerateDeparture()
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	// A new subscriber should receive the retained message:
+	received := make(chan []byte, 1)
+	subscriber := paho.NewClient(paho.NewClientOptions().AddBroker(broker).SetClientID("gotrain-test-subscriber"))
+
+	if token := subscriber.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	defer subscriber.Disconnect(100)
+
+	time.Sleep(100 * time.Millisecond)
+	subscriber.Subscribe(publisher.DepartureTopic(departure), 1, func(_ paho.Client, message paho.Message) {
+		received <- message.Payload()
+	})
+
+	select {
+	case payload := <-received:
+		if len(payload) == 0 {
+			t.Error("Expected retained departure")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No retained message received")
+	}
+
+	// Clear the retained message:
+	client.Publish(publisher.DepartureTopic(departure), nil)
+}
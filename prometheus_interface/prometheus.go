@@ -5,6 +5,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rijdendetreinen/gotrain/receiver"
 	"github.com/rijdendetreinen/gotrain/stores"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -13,6 +14,9 @@ import (
 // Init counters enzo.
 func SetupPrometheus() {
 	registerStoreMetrics()
+	registerWALMetrics()
+	registerSourceMetrics()
+	registerPipelineMetrics()
 }
 
 func StartPrometheusInterface() {
@@ -242,3 +246,66 @@ func registerStoreMetrics() {
 		func() float64 { return float64(stores.Stores.ServiceStore.GetNumberOfServices()) },
 	))
 }
+
+func registerWALMetrics() {
+	prometheus.Register(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: "gotrain",
+			Subsystem: "wal",
+			Name:      "replay_seconds",
+			Help:      "Duration of the write-ahead log replay on startup",
+		},
+		func() float64 { return stores.GetWALReplay().Duration.Seconds() },
+	))
+
+	prometheus.Register(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: "gotrain",
+			Subsystem: "wal",
+			Name:      "replay_records",
+			Help:      "Number of records replayed from the write-ahead log on startup",
+		},
+		func() float64 { return float64(stores.GetWALReplay().Records) },
+	))
+}
+
+func registerSourceMetrics() {
+	prometheus.Register(prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Namespace: "gotrain",
+			Subsystem: "source",
+			Name:      "failovers",
+			Help:      "Number of switches between source servers",
+		},
+		func() float64 { return float64(receiver.GetSourceStatus().Failovers) },
+	))
+
+	prometheus.Register(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: "gotrain",
+			Subsystem: "source",
+			Name:      "active_priority",
+			Help:      "Priority of the currently active source server",
+		},
+		func() float64 { return float64(receiver.GetSourceStatus().ActivePriority) },
+	))
+}
+
+func registerPipelineMetrics() {
+	for _, messageType := range []string{receiver.MessageTypeArrivals, receiver.MessageTypeDepartures, receiver.MessageTypeServices} {
+		for _, stage := range []string{receiver.StageParse, receiver.StageStore} {
+			messageType, stage := messageType, stage
+
+			prometheus.Register(prometheus.NewGaugeFunc(
+				prometheus.GaugeOpts{
+					Namespace:   "gotrain",
+					Subsystem:   "pipeline",
+					Name:        "queue_depth",
+					Help:        "Number of messages waiting in a stage of the processing pipeline",
+					ConstLabels: prometheus.Labels{"type": messageType, "stage": stage},
+				},
+				func() float64 { return float64(receiver.QueueDepth(messageType, stage)) },
+			))
+		}
+	}
+}
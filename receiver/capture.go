@@ -0,0 +1,166 @@
+package receiver
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CapturedMessage is a raw multipart message as received from the ZMQ server.
+// The payload frames are stored as received, so they are still gzipped.
+type CapturedMessage struct {
+	Received time.Time
+	Frames   [][]byte
+}
+
+// Envelope returns the envelope (first frame) of the captured message
+func (message CapturedMessage) Envelope() string {
+	if len(message.Frames) == 0 {
+		return ""
+	}
+
+	return string(message.Frames[0])
+}
+
+// CaptureWriter writes captured messages to capture files in a directory.
+// A new file is started when the rotate interval has passed or the maximum file size has been reached.
+type CaptureWriter struct {
+	Directory      string
+	RotateInterval time.Duration
+	MaxSize        int64
+
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *gob.Encoder
+	counter *countingWriter
+	opened  time.Time
+}
+
+// countingWriter keeps track of the number of bytes written to a capture file
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+
+	return n, err
+}
+
+// NewCaptureWriter creates a capture writer for the given directory.
+// A rotateInterval or maxSize of 0 disables rotation on time or size, respectively.
+func NewCaptureWriter(directory string, rotateInterval time.Duration, maxSize int64) *CaptureWriter {
+	return &CaptureWriter{
+		Directory:      directory,
+		RotateInterval: rotateInterval,
+		MaxSize:        maxSize,
+	}
+}
+
+// Write appends a captured message to the current capture file, rotating the file when necessary
+func (w *CaptureWriter) Write(message CapturedMessage) error {
+	if w.file == nil || w.shouldRotate(message.Received) {
+		if err := w.rotate(message.Received); err != nil {
+			return err
+		}
+	}
+
+	return w.encoder.Encode(message)
+}
+
+func (w *CaptureWriter) shouldRotate(currentTime time.Time) bool {
+	if w.RotateInterval > 0 && currentTime.Sub(w.opened) >= w.RotateInterval {
+		return true
+	}
+
+	if w.MaxSize > 0 && w.counter.count >= w.MaxSize {
+		return true
+	}
+
+	return false
+}
+
+// rotate closes the current capture file (if any) and opens a new one
+func (w *CaptureWriter) rotate(currentTime time.Time) error {
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	filename := filepath.Join(w.Directory, fmt.Sprintf("capture-%s.gob", currentTime.UTC().Format("20060102-150405.000")))
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("file", filename).Msg("Opened new capture file")
+
+	w.file = file
+	w.writer = bufio.NewWriter(file)
+	w.counter = &countingWriter{writer: w.writer}
+	w.encoder = gob.NewEncoder(w.counter)
+	w.opened = currentTime
+
+	return nil
+}
+
+// Close flushes and closes the current capture file
+func (w *CaptureWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.writer.Flush()
+	closeErr := w.file.Close()
+
+	w.file = nil
+
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+// ReadCaptureFile reads all messages from a capture file and calls handler for every message.
+// Reading stops when the handler returns an error.
+func ReadCaptureFile(filename string, handler func(CapturedMessage) error) error {
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+
+	for {
+		var message CapturedMessage
+
+		err := decoder.Decode(&message)
+
+		if err == io.EOF {
+			return nil
+		} else if err == io.ErrUnexpectedEOF {
+			// Capture file was not closed properly, i.e. the recorder crashed
+			log.Warn().Str("file", filename).Msg("Capture file is truncated")
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := handler(message); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package receiver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+	directory := t.TempDir()
+	writer := NewCaptureWriter(directory, time.Hour, 0)
+
+	startTime := time.Date(2019, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		err := writer.Write(CapturedMessage{
+			Received: startTime.Add(time.Duration(i) * time.Second),
+			Frames:   [][]byte{[]byte("/RIG/InfoPlusDVSInterface4"), {0x1f, 0x8b, byte(i)}},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(directory, "capture-*.gob"))
+
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 capture file, found %d", len(files))
+	}
+
+	var messages []CapturedMessage
+
+	err := ReadCaptureFile(files[0], func(message CapturedMessage) error {
+		messages = append(messages, message)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, read %d", len(messages))
+	}
+
+	if messages[2].Envelope() != "/RIG/InfoPlusDVSInterface4" {
+		t.Errorf("Wrong envelope %s", messages[2].Envelope())
+	}
+
+	if messages[2].Frames[1][2] != 2 {
+		t.Error("Wrong payload for message 3")
+	}
+
+	if !messages[1].Received.Equal(startTime.Add(time.Second)) {
+		t.Errorf("Wrong receive time %v", messages[1].Received)
+	}
+}
+
+func TestCaptureRotation(t *testing.T) {
+	directory := t.TempDir()
+	writer := NewCaptureWriter(directory, time.Minute, 0)
+
+	startTime := time.Date(2019, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		writer.Write(CapturedMessage{
+			Received: startTime.Add(time.Duration(i) * 45 * time.Second),
+			Frames:   [][]byte{[]byte("envelope"), []byte("data")},
+		})
+	}
+
+	writer.Close()
+
+	files, _ := os.ReadDir(directory)
+
+	// Messages at 0s and 45s end up in the first file, the message at 90s in the second:
+	if len(files) != 2 {
+		t.Errorf("Expected 2 capture files, found %d", len(files))
+	}
+}
@@ -0,0 +1,88 @@
+package receiver
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// errReplayStopped is returned by the replay handler when replaying is interrupted
+var errReplayStopped = errors.New("replay stopped")
+
+// CaptureSource replays messages from capture files created by Record.
+// The speed determines the pacing: 1 replays in real-time, 10 is ten times as fast,
+// and 0 (or less) replays as fast as possible.
+type CaptureSource struct {
+	Filenames []string
+	Speed     float64
+}
+
+// Name returns the source description
+func (source *CaptureSource) Name() string {
+	return "capture"
+}
+
+// Receive replays all messages from the capture files
+func (source *CaptureSource) Receive(messages chan<- Message, stop <-chan struct{}) error {
+	var firstReceived time.Time
+	var startTime time.Time
+	replayed := 0
+
+	handler := func(captured CapturedMessage) error {
+		if len(captured.Frames) < 2 {
+			log.Warn().Str("envelope", captured.Envelope()).Msg("Captured message has no payload. Message ignored")
+			return nil
+		}
+
+		if firstReceived.IsZero() {
+			firstReceived = captured.Received
+			startTime = time.Now()
+		}
+
+		if source.Speed > 0 {
+			offset := time.Duration(float64(captured.Received.Sub(firstReceived)) / source.Speed)
+			wait := time.Until(startTime.Add(offset))
+
+			if wait > 0 {
+				select {
+				case <-stop:
+					return errReplayStopped
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		message := Message{
+			Envelope:   captured.Envelope(),
+			Payload:    captured.Frames[1],
+			Compressed: true,
+			Received:   time.Now(),
+		}
+
+		if !deliver(messages, message, stop) {
+			return errReplayStopped
+		}
+
+		replayed++
+
+		return nil
+	}
+
+	for _, filename := range source.Filenames {
+		log.Info().Str("file", filename).Msg("Replaying capture file")
+
+		err := ReadCaptureFile(filename, handler)
+
+		if err == errReplayStopped {
+			log.Info().Int("messages", replayed).Msg("Replay stopped")
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	log.Info().Int("messages", replayed).Msg("Replay finished")
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package receiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// maxRecentDeadLetters is the number of dead letters kept in memory for the API
+const maxRecentDeadLetters = 100
+
+// DeadLetters is the dead letter queue used by the dispatcher. It is nil when dead letter capture is disabled.
+var DeadLetters *DeadLetterQueue
+
+// DeadLetter is a message which could not be decompressed or parsed
+type DeadLetter struct {
+	Time       time.Time `json:"time"`
+	Received   time.Time `json:"received"`
+	Envelope   string    `json:"envelope"`
+	Type       string    `json:"type"`
+	Error      string    `json:"error"`
+	Compressed bool      `json:"compressed"`
+	Payload    []byte    `json:"payload,omitempty"`
+	Size       int       `json:"size"`
+	File       string    `json:"file,omitempty"`
+}
+
+// Message returns the original message of this dead letter
+func (letter DeadLetter) Message() Message {
+	return Message{
+		Envelope:   letter.Envelope,
+		Type:       letter.Type,
+		Payload:    letter.Payload,
+		Compressed: letter.Compressed,
+		Received:   letter.Received,
+	}
+}
+
+// DeadLetterQueue writes failed messages to a directory, keeping at most MaxFiles files
+type DeadLetterQueue struct {
+	Directory string
+	MaxFiles  int
+
+	mutex    sync.Mutex
+	recent   []DeadLetter
+	files    []string // Dead letter files in the directory, oldest first
+	sequence int
+}
+
+// SetupDeadLetters enables dead letter capture when deadletter.directory is configured
+func SetupDeadLetters() error {
+	directory := viper.GetString("deadletter.directory")
+
+	if directory == "" {
+		return nil
+	}
+
+	maxFiles := 1000
+
+	if viper.IsSet("deadletter.max_files") {
+		maxFiles = viper.GetInt("deadletter.max_files")
+	}
+
+	queue, err := NewDeadLetterQueue(directory, maxFiles)
+
+	if err != nil {
+		return err
+	}
+
+	DeadLetters = queue
+
+	log.Info().Str("directory", directory).Int("max_files", maxFiles).Msg("Dead letter capture enabled")
+
+	return nil
+}
+
+// NewDeadLetterQueue creates a dead letter queue, creating the directory if necessary.
+// Existing dead letter files count towards the maximum number of files.
+func NewDeadLetterQueue(directory string, maxFiles int) (*DeadLetterQueue, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	files, err := ListDeadLetters(directory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	queue := &DeadLetterQueue{
+		Directory: directory,
+		MaxFiles:  maxFiles,
+		recent:    make([]DeadLetter, 0),
+		files:     files,
+	}
+
+	queue.prune()
+
+	return queue, nil
+}
+
+// Add writes a failed message to the dead letter directory
+func (queue *DeadLetterQueue) Add(message Message, messageType string, cause error) {
+	if queue == nil {
+		return
+	}
+
+	letter := DeadLetter{
+		Time:       time.Now(),
+		Received:   message.Received,
+		Envelope:   message.Envelope,
+		Type:       messageType,
+		Error:      cause.Error(),
+		Compressed: message.Compressed,
+		Payload:    message.Payload,
+		Size:       len(message.Payload),
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	queue.sequence++
+	letter.File = fmt.Sprintf("deadletter-%s-%04d.json", letter.Time.UTC().Format("20060102-150405.000"), queue.sequence%10000)
+
+	data, err := json.Marshal(letter)
+
+	if err == nil {
+		err = os.WriteFile(filepath.Join(queue.Directory, letter.File), data, 0644)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("file", letter.File).Msg("Could not write dead letter")
+		letter.File = ""
+	} else {
+		queue.files = append(queue.files, filepath.Join(queue.Directory, letter.File))
+	}
+
+	// Only keep the metadata in memory:
+	letter.Payload = nil
+
+	queue.recent = append(queue.recent, letter)
+
+	if len(queue.recent) > maxRecentDeadLetters {
+		queue.recent = queue.recent[len(queue.recent)-maxRecentDeadLetters:]
+	}
+
+	queue.prune()
+}
+
+// prune removes the oldest dead letter files when there are more than MaxFiles files.
+// Must be called while holding the mutex (or before the queue is in use).
+func (queue *DeadLetterQueue) prune() {
+	if queue.MaxFiles <= 0 {
+		return
+	}
+
+	for len(queue.files) > queue.MaxFiles {
+		if err := os.Remove(queue.files[0]); err != nil && !os.IsNotExist(err) {
+			log.Error().Err(err).Str("file", queue.files[0]).Msg("Could not remove dead letter")
+		}
+
+		queue.files = queue.files[1:]
+	}
+}
+
+// Recent returns the most recent dead letters (without payload), newest first
+func (queue *DeadLetterQueue) Recent() []DeadLetter {
+	letters := make([]DeadLetter, 0)
+
+	if queue == nil {
+		return letters
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	for i := len(queue.recent) - 1; i >= 0; i-- {
+		letters = append(letters, queue.recent[i])
+	}
+
+	return letters
+}
+
+// ListDeadLetters returns all dead letter files in a directory, oldest first
+func ListDeadLetters(directory string) ([]string, error) {
+	entries, err := os.ReadDir(directory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "deadletter-") && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, filepath.Join(directory, entry.Name()))
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// ReadDeadLetter reads a dead letter file
+func ReadDeadLetter(filename string) (DeadLetter, error) {
+	var letter DeadLetter
+
+	data, err := os.ReadFile(filename)
+
+	if err != nil {
+		return letter, err
+	}
+
+	err = json.Unmarshal(data, &letter)
+
+	return letter, err
+}
+
+// Reparse decompresses and parses the payload of a dead letter again. It returns the message type and
+// the parsed item (a models.Departure, models.Arrival or models.Service).
+func (letter DeadLetter) Reparse() (string, interface{}, error) {
+	payload := letter.Payload
+
+	if letter.Compressed {
+		var err error
+		payload, err = gunzip(letter.Payload)
+
+		if err != nil {
+			return letter.Type, nil, err
+		}
+	}
+
+	dispatcher := &Dispatcher{Envelopes: getEnvelopes()}
+	messageType := dispatcher.messageType(letter.Message(), payload)
+
+	item, err := ParsePayload(messageType, payload)
+
+	return messageType, item, err
+}
@@ -0,0 +1,131 @@
+package receiver
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeadLetterCapture(t *testing.T) {
+	queue, err := NewDeadLetterQueue(t.TempDir(), 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := testDispatcher()
+	dispatcher.DeadLetters = queue
+
+	// Not gzipped, although the message claims to be:
+	dispatcher.Dispatch(Message{
+		Envelope:   "/RIG/InfoPlusDVSInterface4",
+		Payload:    []byte("garbage"),
+		Compressed: true,
+	})
+
+	// Departure which can not be parsed:
+	dispatcher.Dispatch(Message{
+		Type:    MessageTypeDepartures,
+		Payload: readTestMessage(t, "invalid.xml"),
+	})
+
+	recent := queue.Recent()
+
+	if len(recent) != 2 {
+		t.Fatalf("Expected 2 dead letters, got %d", len(recent))
+	}
+
+	if recent[0].Type != MessageTypeDepartures || recent[0].Error == "" {
+		t.Errorf("Wrong dead letter metadata: %+v", recent[0])
+	}
+
+	if recent[0].Payload != nil {
+		t.Error("Payload should not be kept in memory")
+	}
+
+	files, _ := ListDeadLetters(queue.Directory)
+
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 dead letter files, got %d", len(files))
+	}
+
+	letter, err := ReadDeadLetter(files[1])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(letter.Payload) != string(readTestMessage(t, "invalid.xml")) {
+		t.Error("Payload not stored in dead letter file")
+	}
+
+	if _, _, err := letter.Reparse(); err == nil {
+		t.Error("Invalid message should still fail to parse")
+	}
+
+	// Third dead letter, the oldest file should be removed:
+	dispatcher.Dispatch(Message{Type: MessageTypeServices, Payload: []byte("<invalid/>")})
+
+	files, _ = ListDeadLetters(queue.Directory)
+
+	if len(files) != 2 {
+		t.Errorf("Expected dead letter directory to be limited to 2 files, got %d", len(files))
+	}
+}
+
+func TestDeadLetterReparse(t *testing.T) {
+	letter := DeadLetter{
+		Payload:    gzipData(readTestMessage(t, "service.xml")),
+		Compressed: true,
+	}
+
+	messageType, item, err := letter.Reparse()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if messageType != MessageTypeServices || item == nil {
+		t.Errorf("Wrong reparse result: %s %v", messageType, item)
+	}
+}
+
+func TestDeadLettersDisabled(t *testing.T) {
+	var queue *DeadLetterQueue
+
+	// Should not panic:
+	queue.Add(Message{}, "", nil)
+
+	if len(queue.Recent()) != 0 {
+		t.Error("Disabled queue should not return dead letters")
+	}
+}
+
+func TestDeadLetterExistingFiles(t *testing.T) {
+	directory := t.TempDir()
+	queue, _ := NewDeadLetterQueue(directory, 0)
+
+	for i := 0; i < 3; i++ {
+		queue.Add(Message{Type: MessageTypeServices}, MessageTypeServices, errors.New("invalid"))
+	}
+
+	// Existing files should be counted when the queue is created again:
+	queue, err := NewDeadLetterQueue(directory, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := ListDeadLetters(directory)
+
+	if len(files) != 2 {
+		t.Fatalf("Expected existing dead letters to be limited to 2 files, got %d", len(files))
+	}
+
+	queue.Add(Message{Type: MessageTypeServices}, MessageTypeServices, errors.New("invalid"))
+
+	newFiles, _ := ListDeadLetters(directory)
+
+	if len(newFiles) != 2 || newFiles[0] == files[0] || newFiles[1] == files[0] {
+		t.Errorf("Expected oldest existing dead letter to be removed, got %v", newFiles)
+	}
+}
@@ -0,0 +1,102 @@
+package receiver
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DirectorySource reads XML messages from files in a directory.
+// Files ending in .gz are treated as gzipped messages.
+type DirectorySource struct {
+	Directory string
+
+	// Watch keeps polling the directory for new files once all existing files have been read
+	Watch        bool
+	PollInterval time.Duration
+
+	processed map[string]struct{}
+}
+
+// Name returns the source description
+func (source *DirectorySource) Name() string {
+	return "directory " + source.Directory
+}
+
+// Receive reads all files in the directory (sorted by name)
+func (source *DirectorySource) Receive(messages chan<- Message, stop <-chan struct{}) error {
+	source.processed = make(map[string]struct{})
+
+	pollInterval := source.PollInterval
+
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+
+	for {
+		if err := source.readNewFiles(messages, stop); err != nil {
+			return err
+		}
+
+		if !source.Watch || stopped(stop) {
+			return nil
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func (source *DirectorySource) readNewFiles(messages chan<- Message, stop <-chan struct{}) error {
+	entries, err := os.ReadDir(source.Directory)
+
+	if err != nil {
+		return err
+	}
+
+	var filenames []string
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if entry.IsDir() || !(strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".xml.gz")) {
+			continue
+		}
+
+		if _, done := source.processed[name]; !done {
+			filenames = append(filenames, name)
+		}
+	}
+
+	sort.Strings(filenames)
+
+	for _, name := range filenames {
+		source.processed[name] = struct{}{}
+
+		payload, err := os.ReadFile(filepath.Join(source.Directory, name))
+
+		if err != nil {
+			log.Error().Err(err).Str("file", name).Msg("Could not read message file")
+			continue
+		}
+
+		message := Message{
+			Payload:    payload,
+			Compressed: strings.HasSuffix(name, ".gz"),
+			Received:   time.Now(),
+		}
+
+		if !deliver(messages, message, stop) {
+			return nil
+		}
+	}
+
+	return nil
+}
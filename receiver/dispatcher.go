@@ -0,0 +1,196 @@
+package receiver
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/rijdendetreinen/gotrain/archiver"
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/parsers"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/rs/zerolog/log"
+)
+
+// Dispatcher decompresses and parses messages and hands the results to the stores and/or the archiver
+type Dispatcher struct {
+	Envelopes       map[string]string
+	ProcessStores   bool
+	ArchiveServices bool
+	DeadLetters     *DeadLetterQueue
+}
+
+// NewDispatcher creates a dispatcher for the configured envelopes and the current receiver mode
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		Envelopes:       getEnvelopes(),
+		ProcessStores:   ProcessStores,
+		ArchiveServices: ArchiveServices,
+		DeadLetters:     DeadLetters,
+	}
+}
+
+// messageType determines the type of a message: either explicitly set by the source, based on the envelope,
+// or based on the (decompressed) content
+func (dispatcher *Dispatcher) messageType(message Message, payload []byte) string {
+	if message.Type != "" {
+		return message.Type
+	}
+
+	if message.Envelope != "" {
+		for key, envelope := range dispatcher.Envelopes {
+			if envelope != "" && strings.HasPrefix(message.Envelope, envelope) {
+				return key
+			}
+		}
+	}
+
+	return DetectMessageType(payload)
+}
+
+// decodedMessage is the result of decoding a message. Item is nil when the message could not be
+// decoded (Failed is set) or should not be processed.
+type decodedMessage struct {
+	Type   string
+	Item   interface{}
+	Failed bool
+}
+
+// Dispatch decompresses and parses a single message and processes it
+func (dispatcher *Dispatcher) Dispatch(message Message) {
+	dispatcher.process(dispatcher.decode(message))
+}
+
+// decode decompresses and parses a message. It does not modify the stores, so it is safe
+// to decode multiple messages concurrently.
+func (dispatcher *Dispatcher) decode(message Message) decodedMessage {
+	payload := message.Payload
+
+	// Decompress message:
+	if message.Compressed {
+		var err error
+		payload, err = gunzip(message.Payload)
+
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("envelope", message.Envelope).
+				Str("message", string(message.Payload)).
+				Msg("Error decompressing message. Message ignored")
+
+			dispatcher.DeadLetters.Add(message, message.Type, err)
+
+			return decodedMessage{Type: message.Type}
+		}
+	}
+
+	messageType := dispatcher.messageType(message, payload)
+
+	if !dispatcher.ProcessStores && messageType != MessageTypeServices {
+		return decodedMessage{Type: messageType}
+	}
+
+	item, err := ParsePayload(messageType, payload)
+
+	if err != nil {
+		switch messageType {
+		case MessageTypeDepartures:
+			log.Error().Err(err).Msg("Could not parse departure message")
+		case MessageTypeArrivals:
+			log.Error().Err(err).Msg("Could not parse arrival message")
+		case MessageTypeServices:
+			log.Error().Err(err).Msg("Could not parse service message")
+		default:
+			log.Warn().
+				Str("envelope", message.Envelope).
+				Msg("Unknown envelope")
+
+			return decodedMessage{}
+		}
+
+		dispatcher.DeadLetters.Add(message, messageType, err)
+
+		return decodedMessage{Type: messageType, Failed: true}
+	}
+
+	return decodedMessage{Type: messageType, Item: item}
+}
+
+// process hands a decoded item to the stores and/or the archiver
+func (dispatcher *Dispatcher) process(decoded decodedMessage) {
+	if decoded.Failed {
+		switch decoded.Type {
+		case MessageTypeDepartures:
+			stores.Stores.DepartureStore.Counters.Error++
+		case MessageTypeArrivals:
+			stores.Stores.ArrivalStore.Counters.Error++
+		case MessageTypeServices:
+			stores.Stores.ServiceStore.Counters.Error++
+		}
+
+		return
+	}
+
+	switch item := decoded.Item.(type) {
+	case models.Departure:
+		if dispatcher.ProcessStores {
+			stores.Stores.DepartureStore.ProcessDeparture(item)
+			stores.Stores.StationStore.ProcessDeparture(item)
+		}
+
+		log.Debug().
+			Str("ProductID", item.ProductID).
+			Str("DepartureID", item.ID).
+			Msg("Departure received")
+
+	case models.Arrival:
+		if dispatcher.ProcessStores {
+			stores.Stores.ArrivalStore.ProcessArrival(item)
+			stores.Stores.StationStore.ProcessArrival(item)
+		}
+
+		log.Debug().
+			Str("ProductID", item.ProductID).
+			Str("ArrivalID", item.ID).
+			Msg("Arrival received")
+
+	case models.Service:
+		if dispatcher.ProcessStores {
+			stores.Stores.ServiceStore.ProcessService(item)
+			stores.Stores.StationStore.ProcessService(item)
+		}
+		if dispatcher.ArchiveServices {
+			archiver.ProcessService(item)
+		}
+
+		log.Debug().
+			Str("ProductID", item.ProductID).
+			Str("ServiceID", item.ID).
+			Msg("Service received")
+	}
+}
+
+// routeType determines the message type without decompressing the message, so it can be routed
+// to the right pipeline lane. It returns an empty string when the type can only be determined from the content.
+func (dispatcher *Dispatcher) routeType(message Message) string {
+	if message.Type != "" || message.Compressed {
+		return dispatcher.messageType(message, nil)
+	}
+
+	return dispatcher.messageType(message, message.Payload)
+}
+
+// ParsePayload parses a decompressed message of the given type. It returns a models.Departure,
+// models.Arrival or models.Service.
+func ParsePayload(messageType string, payload []byte) (interface{}, error) {
+	switch messageType {
+	case MessageTypeDepartures:
+		return parsers.ParseDvsMessage(bytes.NewReader(payload))
+	case MessageTypeArrivals:
+		return parsers.ParseDasMessage(bytes.NewReader(payload))
+	case MessageTypeServices:
+		return parsers.ParseRitMessage(bytes.NewReader(payload))
+	}
+
+	return nil, fmt.Errorf("unknown message type: %q", messageType)
+}
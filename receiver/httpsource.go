@@ -0,0 +1,86 @@
+package receiver
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HTTPSource receives messages which are pushed with HTTP POST requests.
+// The message type is determined by the path (/departures, /arrivals or /services), or by
+// the message content when posted to /. Gzipped messages must be posted with Content-Encoding: gzip.
+type HTTPSource struct {
+	Address string
+}
+
+// Name returns the source description
+func (source *HTTPSource) Name() string {
+	return "http " + source.Address
+}
+
+// Receive starts the HTTP server and receives messages until the stop channel is closed
+func (source *HTTPSource) Receive(messages chan<- Message, stop <-chan struct{}) error {
+	srv := &http.Server{
+		Addr:    source.Address,
+		Handler: source.handler(messages, stop),
+	}
+
+	serverError := make(chan error, 1)
+
+	go func() {
+		serverError <- srv.ListenAndServe()
+	}()
+
+	log.Info().Str("address", source.Address).Msg("HTTP push endpoint started")
+
+	select {
+	case err := <-serverError:
+		return err
+	case <-stop:
+		srv.Close()
+		return nil
+	}
+}
+
+func (source *HTTPSource) handler(messages chan<- Message, stop <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		messageType := strings.Trim(r.URL.Path, "/")
+
+		switch messageType {
+		case "", MessageTypeArrivals, MessageTypeDepartures, MessageTypeServices:
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		payload, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		message := Message{
+			Envelope:   r.Header.Get("X-Envelope"),
+			Type:       messageType,
+			Payload:    payload,
+			Compressed: r.Header.Get("Content-Encoding") == "gzip",
+			Received:   time.Now(),
+		}
+
+		if !deliver(messages, message, stop) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
@@ -0,0 +1,257 @@
+package receiver
+
+import (
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Pipeline stages, used for queue depth metrics
+const (
+	StageParse = "parse"
+	StageStore = "store"
+)
+
+// laneOther is the lane for messages of which the type can only be determined after decompressing
+const laneOther = "other"
+
+// defaultWorkers is the default number of parse workers per message type
+var defaultWorkers = map[string]int{
+	MessageTypeDepartures: 2,
+	MessageTypeArrivals:   1,
+	MessageTypeServices:   2,
+	laneOther:             1,
+}
+
+// defaultQueueSize is the default maximum number of messages per message type which are being parsed
+const defaultQueueSize = 1000
+
+// Pipeline processes messages in three stages. The source delivers messages, which are routed to a lane per
+// message type. Each lane has a pool of workers which decompress and parse messages concurrently. The parsed
+// items are put back in their original order and handed to a single goroutine per store, so items with the
+// same ID are always processed in the order in which they were received.
+type Pipeline struct {
+	dispatcher  *Dispatcher
+	lanes       map[string]*pipelineLane
+	storeQueues map[string]chan decodedMessage
+	storeWait   sync.WaitGroup
+}
+
+// pipelineLane is the parse stage for a single message type
+type pipelineLane struct {
+	name     string
+	jobs     chan pipelineJob
+	results  chan pipelineJob
+	slots    chan struct{}
+	sequence uint64
+
+	workerWait  sync.WaitGroup
+	reorderDone chan struct{}
+}
+
+// pipelineJob is a message in the pipeline, numbered in order of arrival per lane
+type pipelineJob struct {
+	sequence uint64
+	message  Message
+	decoded  decodedMessage
+}
+
+var currentPipeline *Pipeline
+var currentPipelineMutex sync.RWMutex
+
+// NewPipeline creates a pipeline with the given number of parse workers per message type and the
+// maximum number of messages per type which may be in the parse stage at the same time
+func NewPipeline(dispatcher *Dispatcher, workers map[string]int, queueSize int) *Pipeline {
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	pipeline := &Pipeline{
+		dispatcher:  dispatcher,
+		lanes:       make(map[string]*pipelineLane),
+		storeQueues: make(map[string]chan decodedMessage),
+	}
+
+	for _, messageType := range []string{MessageTypeArrivals, MessageTypeDepartures, MessageTypeServices} {
+		pipeline.storeQueues[messageType] = make(chan decodedMessage, queueSize)
+	}
+
+	for name, defaultCount := range defaultWorkers {
+		count := workers[name]
+
+		if count <= 0 {
+			count = defaultCount
+		}
+
+		lane := &pipelineLane{
+			name:        name,
+			jobs:        make(chan pipelineJob, queueSize),
+			results:     make(chan pipelineJob, queueSize),
+			slots:       make(chan struct{}, queueSize),
+			reorderDone: make(chan struct{}),
+		}
+
+		for i := 0; i < count; i++ {
+			lane.workerWait.Add(1)
+			go pipeline.parseWorker(lane)
+		}
+
+		go pipeline.reorder(lane)
+
+		pipeline.lanes[name] = lane
+	}
+
+	for messageType, queue := range pipeline.storeQueues {
+		pipeline.storeWait.Add(1)
+		go pipeline.storeWorker(messageType, queue)
+	}
+
+	return pipeline
+}
+
+// NewPipelineFromConfig creates a pipeline with the worker pool sizes configured in the receiver: section
+func NewPipelineFromConfig(dispatcher *Dispatcher) *Pipeline {
+	workers := make(map[string]int)
+
+	for name := range defaultWorkers {
+		workers[name] = viper.GetInt("receiver.workers." + name)
+	}
+
+	return NewPipeline(dispatcher, workers, viper.GetInt("receiver.queue_size"))
+}
+
+// Submit adds a message to the pipeline. It blocks when the parse stage for this message type is full.
+func (pipeline *Pipeline) Submit(message Message) {
+	lane, exists := pipeline.lanes[pipeline.dispatcher.routeType(message)]
+
+	if !exists {
+		lane = pipeline.lanes[laneOther]
+	}
+
+	lane.slots <- struct{}{}
+
+	lane.jobs <- pipelineJob{
+		sequence: lane.sequence,
+		message:  message,
+	}
+
+	lane.sequence++
+}
+
+// Close waits until all submitted messages have been processed and stops all workers.
+// A closed pipeline no longer reports its queue depths.
+func (pipeline *Pipeline) Close() {
+	for _, lane := range pipeline.lanes {
+		close(lane.jobs)
+	}
+
+	for _, lane := range pipeline.lanes {
+		lane.workerWait.Wait()
+		close(lane.results)
+		<-lane.reorderDone
+	}
+
+	for _, queue := range pipeline.storeQueues {
+		close(queue)
+	}
+
+	pipeline.storeWait.Wait()
+
+	clearCurrentPipeline(pipeline)
+}
+
+// parseWorker decompresses and parses messages
+func (pipeline *Pipeline) parseWorker(lane *pipelineLane) {
+	defer lane.workerWait.Done()
+
+	for job := range lane.jobs {
+		job.decoded = pipeline.dispatcher.decode(job.message)
+		job.message = Message{}
+
+		lane.results <- job
+	}
+}
+
+// reorder puts parsed messages back in their original order and hands them to the store stage
+func (pipeline *Pipeline) reorder(lane *pipelineLane) {
+	defer close(lane.reorderDone)
+
+	pending := make(map[uint64]decodedMessage)
+	var next uint64
+
+	for job := range lane.results {
+		pending[job.sequence] = job.decoded
+
+		for {
+			decoded, exists := pending[next]
+
+			if !exists {
+				break
+			}
+
+			delete(pending, next)
+			next++
+			<-lane.slots
+
+			if queue, exists := pipeline.storeQueues[decoded.Type]; exists {
+				queue <- decoded
+			}
+		}
+	}
+}
+
+// storeWorker processes all parsed items for a single store
+func (pipeline *Pipeline) storeWorker(messageType string, queue chan decodedMessage) {
+	defer pipeline.storeWait.Done()
+
+	for decoded := range queue {
+		pipeline.dispatcher.process(decoded)
+	}
+
+	log.Debug().Str("system", messageType).Msg("Store worker stopped")
+}
+
+// QueueDepth returns the number of messages in a stage of the pipeline for the given message type
+func (pipeline *Pipeline) QueueDepth(messageType, stage string) int {
+	switch stage {
+	case StageParse:
+		if lane, exists := pipeline.lanes[messageType]; exists {
+			return len(lane.slots)
+		}
+	case StageStore:
+		if queue, exists := pipeline.storeQueues[messageType]; exists {
+			return len(queue)
+		}
+	}
+
+	return 0
+}
+
+// setCurrentPipeline registers the pipeline which is currently running, for metrics
+func setCurrentPipeline(pipeline *Pipeline) {
+	currentPipelineMutex.Lock()
+	currentPipeline = pipeline
+	currentPipelineMutex.Unlock()
+}
+
+// clearCurrentPipeline unregisters a closed pipeline, unless another pipeline has been started since
+func clearCurrentPipeline(pipeline *Pipeline) {
+	currentPipelineMutex.Lock()
+	if currentPipeline == pipeline {
+		currentPipeline = nil
+	}
+	currentPipelineMutex.Unlock()
+}
+
+// QueueDepth returns the queue depth of a stage of the currently running pipeline
+func QueueDepth(messageType, stage string) int {
+	currentPipelineMutex.RLock()
+	defer currentPipelineMutex.RUnlock()
+
+	if currentPipeline == nil {
+		return 0
+	}
+
+	return currentPipeline.QueueDepth(messageType, stage)
+}
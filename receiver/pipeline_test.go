@@ -0,0 +1,74 @@
+package receiver
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+func TestPipelineOrdering(t *testing.T) {
+	dispatcher := testDispatcher()
+	pipeline := NewPipeline(dispatcher, map[string]int{MessageTypeDepartures: 4}, 10)
+
+	original := readTestMessage(t, "departure.xml")
+
+	// Submit the same departure with increasing timestamps. With multiple workers, the messages may be
+	// parsed out of order, but they must be processed in order, so none of them is outdated.
+	for i := 0; i < 20; i++ {
+		timestamp := time.Date(2019, time.April, 6, 21, 43, i, 0, time.UTC).Format("2006-01-02T15:04:05.000Z")
+		payload := bytes.Replace(original, []byte("2019-04-06T21:43:20.597Z"), []byte(timestamp), 1)
+
+		pipeline.Submit(Message{Type: MessageTypeDepartures, Payload: payload})
+	}
+
+	pipeline.Close()
+
+	if stores.Stores.DepartureStore.Counters.Processed != 20 {
+		t.Errorf("Expected 20 processed departures, got %d", stores.Stores.DepartureStore.Counters.Processed)
+	}
+
+	if stores.Stores.DepartureStore.Counters.Outdated != 0 {
+		t.Errorf("Departures processed out of order: %d outdated", stores.Stores.DepartureStore.Counters.Outdated)
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	dispatcher := testDispatcher()
+	pipeline := NewPipeline(dispatcher, nil, 0)
+
+	pipeline.Submit(Message{Type: MessageTypeServices, Payload: readTestMessage(t, "invalid.xml")})
+	pipeline.Submit(Message{Payload: readTestMessage(t, "arrival.xml")})
+	pipeline.Submit(Message{Envelope: "/RIG/InfoPlusRITInterface5", Payload: gzipData(readTestMessage(t, "service.xml")), Compressed: true})
+
+	pipeline.Close()
+
+	if stores.Stores.ServiceStore.Counters.Error != 1 {
+		t.Errorf("Expected 1 service error, got %d", stores.Stores.ServiceStore.Counters.Error)
+	}
+	if stores.Stores.ServiceStore.GetNumberOfServices() != 1 {
+		t.Error("Service not processed")
+	}
+	if stores.Stores.ArrivalStore.GetNumberOfArrivals() != 1 {
+		t.Error("Arrival without type not processed")
+	}
+
+	if pipeline.QueueDepth(MessageTypeServices, StageParse) != 0 || pipeline.QueueDepth(MessageTypeServices, StageStore) != 0 {
+		t.Error("Queues should be empty after closing the pipeline")
+	}
+}
+
+func TestCurrentPipelineCleared(t *testing.T) {
+	pipeline := NewPipeline(testDispatcher(), nil, 0)
+	setCurrentPipeline(pipeline)
+
+	pipeline.Close()
+
+	currentPipelineMutex.RLock()
+	defer currentPipelineMutex.RUnlock()
+
+	if currentPipeline != nil {
+		t.Error("Closed pipeline should no longer be the current pipeline")
+	}
+}
@@ -3,14 +3,7 @@ package receiver
 import (
 	"bytes"
 	"compress/gzip"
-	"io"
-	"strings"
-	"time"
-
-	"github.com/pebbe/zmq4"
-	"github.com/rijdendetreinen/gotrain/archiver"
-	"github.com/rijdendetreinen/gotrain/parsers"
-	"github.com/rijdendetreinen/gotrain/stores"
+
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
@@ -18,160 +11,109 @@ import (
 var ArchiveServices bool
 var ProcessStores bool
 
-// ReceiveData connects to the ZMQ server and starts receiving data
+// ReceiveData starts receiving data from the configured source
 func ReceiveData(exit chan bool) {
-	subscriber, _ := zmq4.NewSocket(zmq4.SUB)
+	source, err := NewSource()
 
-	defer subscriber.Close()
+	if err != nil {
+		log.Error().Err(err).Msg("Could not set up source")
 
-	subscriber.SetLinger(0)
-	subscriber.SetRcvtimeo(1 * time.Second)
+		<-exit
+		exit <- true
 
-	replacer := strings.NewReplacer(".", "_")
-	viper.SetEnvKeyReplacer(replacer)
+		return
+	}
 
-	zmqHost := viper.GetString("source.server")
+	Run(source, NewDispatcher(), exit)
+}
 
-	envelopes := map[string]string{
-		"arrivals":   viper.GetString("source.envelopes.arrivals"),
-		"departures": viper.GetString("source.envelopes.departures"),
-		"services":   viper.GetString("source.envelopes.services"),
-	}
+// Run receives messages from a source and dispatches them until exit is signalled
+func Run(source Source, dispatcher *Dispatcher, exit chan bool) {
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		if err := Process(source, dispatcher, stop); err != nil {
+			log.Error().Err(err).Str("source", source.Name()).Msg("Error while receiving data")
+		} else if !stopped(stop) {
+			log.Info().Str("source", source.Name()).Msg("Source exhausted, no more data to receive")
+		}
+
+		close(finished)
+	}()
+
+	<-exit
+	log.Info().Msg("Shutting down receiver")
+
+	close(stop)
+	<-finished
+
+	log.Info().Msg("Receiver shut down")
+
+	exit <- true
+}
+
+// Process sends all messages from a source through the processing pipeline until the source is exhausted or the stop channel is closed
+func Process(source Source, dispatcher *Dispatcher, stop <-chan struct{}) error {
+	messages := make(chan Message, 100)
+	sourceError := make(chan error, 1)
 
-	subscriber.Connect(zmqHost)
-	log.Info().Str("host", zmqHost).Msg("Connected to server")
+	// Reset the status before the source starts, since the source updates it when connecting:
+	setSourceName(source.Name())
 
-	// Subscribe to all envelopes:
-	if !ProcessStores && ArchiveServices {
-		log.Info().Msg("Archiver enabled, not processing departures and arrivals. Only subscribing to services")
+	go func() {
+		sourceError <- source.Receive(messages, stop)
+		close(messages)
+	}()
+
+	pipeline := NewPipelineFromConfig(dispatcher)
+	setCurrentPipeline(pipeline)
+
+	log.Info().Str("source", source.Name()).Msg("Receiving data...")
+
+	for message := range messages {
+		pipeline.Submit(message)
 	}
 
-	for key, envelope := range envelopes {
-		if !ProcessStores && ArchiveServices {
-			if key != "services" {
-				continue
-			}
-		}
-		log.Info().
-			Str("system", key).
-			Str("envelope", envelope).
-			Msg("Subscribed to envelope")
-		subscriber.SetSubscribe(envelope)
+	pipeline.Close()
+
+	return <-sourceError
+}
+
+// Replay feeds all messages from the given capture files through the regular processing path.
+// See CaptureSource for the meaning of speed.
+func Replay(filenames []string, speed float64, stop <-chan struct{}) error {
+	source := &CaptureSource{
+		Filenames: filenames,
+		Speed:     speed,
 	}
 
-	listen(subscriber, envelopes, exit)
+	return Process(source, NewDispatcher(), stop)
 }
 
-// Listen for messages
-func listen(subscriber *zmq4.Socket, envelopes map[string]string, exit chan bool) {
-	log.Info().Msg("Receiving data...")
-
-	for {
-		select {
-		case <-exit:
-			log.Info().Msg("Shutting down receiver")
-
-			subscriber.Close()
-			log.Info().Msg("Receiver shut down")
-
-			exit <- true
-
-			return
-		default:
-			msg, err := subscriber.RecvMessageBytes(0)
-
-			if err != nil {
-				continue
-			}
-
-			envelope := string(msg[0])
-
-			// Decompress message:
-			message, err := gunzip(msg[1])
-
-			if err != nil {
-				log.Error().
-					Err(err).
-					Str("envelope", envelope).
-					Str("message", string(msg[1])).
-					Msg("Error decompressing message. Message ignored")
-			} else {
-				switch {
-				case strings.HasPrefix(envelope, envelopes["departures"]):
-					departure, err := parsers.ParseDvsMessage(message)
-
-					if err != nil {
-						log.Error().Err(err).Msg("Could not parse departure message")
-						stores.Stores.DepartureStore.Counters.Error++
-					} else {
-						if ProcessStores {
-							stores.Stores.DepartureStore.ProcessDeparture(departure)
-						}
-
-						log.Debug().
-							Str("ProductID", departure.ProductID).
-							Str("DepartureID", departure.ID).
-							Msg("Departure received")
-					}
-
-				case strings.HasPrefix(envelope, envelopes["arrivals"]):
-					arrival, err := parsers.ParseDasMessage(message)
-
-					if err != nil {
-						log.Error().Err(err).Msg("Could not parse arrival message")
-						stores.Stores.ArrivalStore.Counters.Error++
-					} else {
-						if ProcessStores {
-							stores.Stores.ArrivalStore.ProcessArrival(arrival)
-						}
-
-						log.Debug().
-							Str("ProductID", arrival.ProductID).
-							Str("ArrivalID", arrival.ID).
-							Msg("Arrival received")
-					}
-
-				case strings.HasPrefix(envelope, envelopes["services"]):
-					service, err := parsers.ParseRitMessage(message)
-
-					if err != nil {
-						log.Error().Err(err).Msg("Could not parse service message")
-						stores.Stores.ServiceStore.Counters.Error++
-					} else {
-						if ProcessStores {
-							stores.Stores.ServiceStore.ProcessService(service)
-						}
-						if ArchiveServices {
-							archiver.ProcessService(service)
-						}
-
-						log.Debug().
-							Str("ProductID", service.ProductID).
-							Str("ServiceID", service.ID).
-							Msg("Service received")
-					}
-
-				default:
-					log.Warn().
-						Str("envelope", envelope).
-						Msg("Unknown envelope")
-				}
-			}
-		}
+// getEnvelopes returns the configured envelopes for all message types
+func getEnvelopes() map[string]string {
+	return map[string]string{
+		MessageTypeArrivals:   viper.GetString("source.envelopes.arrivals"),
+		MessageTypeDepartures: viper.GetString("source.envelopes.departures"),
+		MessageTypeServices:   viper.GetString("source.envelopes.services"),
 	}
 }
 
-func gunzip(data []byte) (io.Reader, error) {
-	buf := bytes.NewBuffer(data)
-	reader, err := gzip.NewReader(buf)
-	defer reader.Close()
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
 
 	if err != nil {
 		return nil, err
 	}
 
-	buf3 := new(bytes.Buffer)
-	buf3.ReadFrom(reader)
+	defer reader.Close()
+
+	buffer := new(bytes.Buffer)
+
+	if _, err := buffer.ReadFrom(reader); err != nil {
+		return nil, err
+	}
 
-	return buf3, nil
+	return buffer.Bytes(), nil
 }
@@ -0,0 +1,60 @@
+package receiver
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+// Record connects to the ZMQ server and writes all raw messages to capture files
+func Record(writer *CaptureWriter, exit chan bool) {
+	source := newZMQSourceFromConfig()
+
+	messages := make(chan Message, 100)
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		if err := source.Receive(messages, stop); err != nil {
+			log.Error().Err(err).Str("source", source.Name()).Msg("Error while receiving data")
+		}
+
+		close(messages)
+	}()
+
+	go func() {
+		recorded := 0
+
+		for message := range messages {
+			err := writer.Write(CapturedMessage{
+				Received: message.Received,
+				Frames:   [][]byte{[]byte(message.Envelope), message.Payload},
+			})
+
+			if err != nil {
+				log.Error().Err(err).Str("envelope", message.Envelope).Msg("Could not write message to capture file")
+				continue
+			}
+
+			recorded++
+		}
+
+		log.Info().Int("messages", recorded).Msg("Recording stopped")
+
+		close(finished)
+	}()
+
+	log.Info().Str("directory", writer.Directory).Msg("Recording data...")
+
+	<-exit
+	log.Info().Msg("Shutting down recorder")
+
+	close(stop)
+	<-finished
+
+	if err := writer.Close(); err != nil {
+		log.Error().Err(err).Msg("Error while closing capture file")
+	}
+
+	log.Info().Msg("Recorder shut down")
+
+	exit <- true
+}
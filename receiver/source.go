@@ -0,0 +1,113 @@
+package receiver
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Message types, which match the keys of the envelopes configuration
+const (
+	MessageTypeArrivals   = "arrivals"
+	MessageTypeDepartures = "departures"
+	MessageTypeServices   = "services"
+)
+
+// Message is a single message received from a source
+type Message struct {
+	// Envelope is the ZMQ envelope, if the source has one
+	Envelope string
+
+	// Type is the message type (departures, arrivals or services). When empty, the type
+	// is determined from the envelope
+	Type string
+
+	Payload    []byte
+	Compressed bool
+	Received   time.Time
+}
+
+// Source is a source of InfoPlus messages
+type Source interface {
+	// Name returns a short description of the source, used for logging
+	Name() string
+
+	// Receive sends all received messages to the messages channel. It returns when the source
+	// is exhausted, when an error occurs or when the stop channel is closed.
+	Receive(messages chan<- Message, stop <-chan struct{}) error
+}
+
+// NewSource creates the source which is configured in the source: section of the configuration
+func NewSource() (Source, error) {
+	sourceType := viper.GetString("source.type")
+
+	switch sourceType {
+	case "", "zmq":
+		return newZMQSourceFromConfig(), nil
+	case "directory":
+		return &DirectorySource{
+			Directory:    viper.GetString("source.directory.path"),
+			Watch:        viper.GetBool("source.directory.watch"),
+			PollInterval: viper.GetDuration("source.directory.interval"),
+		}, nil
+	case "stdin":
+		return NewStdinSource(), nil
+	case "http":
+		return &HTTPSource{
+			Address: viper.GetString("source.http.address"),
+		}, nil
+	case "capture":
+		return &CaptureSource{
+			Filenames: viper.GetStringSlice("source.capture.files"),
+			Speed:     viper.GetFloat64("source.capture.speed"),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown source type: %s", sourceType)
+}
+
+// subscribedTypes returns the message types which should be received, based on the receiver mode
+func subscribedTypes() []string {
+	if !ProcessStores && ArchiveServices {
+		return []string{MessageTypeServices}
+	}
+
+	return []string{MessageTypeArrivals, MessageTypeDepartures, MessageTypeServices}
+}
+
+// DetectMessageType determines the message type based on the XML content of a message
+func DetectMessageType(payload []byte) string {
+	switch {
+	case bytes.Contains(payload, []byte("ReisInformatieProductDVS")):
+		return MessageTypeDepartures
+	case bytes.Contains(payload, []byte("ReisInformatieProductDAS")):
+		return MessageTypeArrivals
+	case bytes.Contains(payload, []byte("ReisInformatieProductRitInfo")):
+		return MessageTypeServices
+	}
+
+	return ""
+}
+
+// deliver sends a message to the messages channel, unless the stop channel is closed first.
+// It returns false when the source should stop.
+func deliver(messages chan<- Message, message Message, stop <-chan struct{}) bool {
+	select {
+	case messages <- message:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// stopped checks (without blocking) whether the stop channel has been closed
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,163 @@
+package receiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+func testDispatcher() *Dispatcher {
+	stores.InitializeStores()
+
+	return &Dispatcher{
+		Envelopes: map[string]string{
+			MessageTypeArrivals:   "/RIG/InfoPlusDASInterface4",
+			MessageTypeDepartures: "/RIG/InfoPlusDVSInterface4",
+			MessageTypeServices:   "/RIG/InfoPlusRITInterface5",
+		},
+		ProcessStores: true,
+	}
+}
+
+func readTestMessage(t *testing.T, filename string) []byte {
+	data, err := os.ReadFile(filepath.Join("..", "parsers", "testdata", filename))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func gzipData(data []byte) []byte {
+	var buffer bytes.Buffer
+
+	writer := gzip.NewWriter(&buffer)
+	writer.Write(data)
+	writer.Close()
+
+	return buffer.Bytes()
+}
+
+func TestDetectMessageType(t *testing.T) {
+	tests := map[string]string{
+		"departure.xml": MessageTypeDepartures,
+		"arrival.xml":   MessageTypeArrivals,
+		"service.xml":   MessageTypeServices,
+	}
+
+	for filename, expected := range tests {
+		if messageType := DetectMessageType(readTestMessage(t, filename)); messageType != expected {
+			t.Errorf("%s: expected type %s, got %s", filename, expected, messageType)
+		}
+	}
+}
+
+func TestDirectorySource(t *testing.T) {
+	directory := t.TempDir()
+
+	os.WriteFile(filepath.Join(directory, "01.xml"), readTestMessage(t, "departure.xml"), 0644)
+	os.WriteFile(filepath.Join(directory, "02.xml"), readTestMessage(t, "arrival.xml"), 0644)
+	os.WriteFile(filepath.Join(directory, "03.xml.gz"), gzipData(readTestMessage(t, "service.xml")), 0644)
+	os.WriteFile(filepath.Join(directory, "readme.txt"), []byte("not a message"), 0644)
+
+	err := Process(&DirectorySource{Directory: directory}, testDispatcher(), make(chan struct{}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stores.Stores.DepartureStore.GetNumberOfDepartures() != 1 {
+		t.Error("Departure not processed")
+	}
+	if stores.Stores.ArrivalStore.GetNumberOfArrivals() != 1 {
+		t.Error("Arrival not processed")
+	}
+	if stores.Stores.ServiceStore.GetNumberOfServices() != 1 {
+		t.Error("Gzipped service not processed")
+	}
+}
+
+func TestStdinSource(t *testing.T) {
+	input := string(readTestMessage(t, "departure.xml")) + "\n" + string(readTestMessage(t, "departure_delay.xml"))
+
+	source := &StdinSource{Reader: strings.NewReader(input)}
+
+	err := Process(source, testDispatcher(), make(chan struct{}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stores.Stores.DepartureStore.Counters.Received != 2 {
+		t.Errorf("Expected 2 received departures, got %d", stores.Stores.DepartureStore.Counters.Received)
+	}
+	if stores.Stores.DepartureStore.Counters.Error != 0 {
+		t.Errorf("Expected no errors, got %d", stores.Stores.DepartureStore.Counters.Error)
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	source := &HTTPSource{}
+	messages := make(chan Message, 1)
+	handler := source.handler(messages, make(chan struct{}))
+
+	request := httptest.NewRequest(http.MethodPost, "/services", bytes.NewReader(gzipData(readTestMessage(t, "service.xml"))))
+	request.Header.Set("Content-Encoding", "gzip")
+	response := httptest.NewRecorder()
+
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("Wrong status code %d", response.Code)
+	}
+
+	message := <-messages
+
+	if message.Type != MessageTypeServices || !message.Compressed {
+		t.Errorf("Wrong message metadata: type=%s compressed=%v", message.Type, message.Compressed)
+	}
+
+	testDispatcher().Dispatch(message)
+
+	if stores.Stores.ServiceStore.GetNumberOfServices() != 1 {
+		t.Error("Pushed service not processed")
+	}
+
+	request = httptest.NewRequest(http.MethodPost, "/unknown", strings.NewReader("<xml/>"))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown path, got %d", response.Code)
+	}
+}
+
+func TestDispatchEnvelope(t *testing.T) {
+	dispatcher := testDispatcher()
+
+	dispatcher.Dispatch(Message{
+		Envelope:   "/RIG/InfoPlusDASInterface4",
+		Payload:    gzipData(readTestMessage(t, "arrival.xml")),
+		Compressed: true,
+	})
+
+	if stores.Stores.ArrivalStore.GetNumberOfArrivals() != 1 {
+		t.Error("Arrival not processed")
+	}
+
+	// Archiver mode: departures should be ignored
+	dispatcher.ProcessStores = false
+	dispatcher.Dispatch(Message{Payload: readTestMessage(t, "departure.xml")})
+
+	if stores.Stores.DepartureStore.Counters.Received != 0 {
+		t.Error("Departure should not be processed in archiver mode")
+	}
+}
@@ -0,0 +1,73 @@
+package receiver
+
+import (
+	"sync"
+	"time"
+)
+
+// maxFailoverEvents is the number of failover events which are kept for the status overview
+const maxFailoverEvents = 10
+
+// SourceStatus contains the status of the active source, including failover information
+type SourceStatus struct {
+	Source         string          `json:"source"`
+	ActiveEndpoint string          `json:"active_endpoint,omitempty"`
+	ActivePriority int             `json:"active_priority"`
+	Endpoints      []ZMQEndpoint   `json:"endpoints,omitempty"`
+	Failovers      int             `json:"failovers"`
+	FailoverEvents []FailoverEvent `json:"failover_events"`
+}
+
+// FailoverEvent is a switch from one endpoint to another
+type FailoverEvent struct {
+	Time   time.Time `json:"time"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+}
+
+var sourceStatus = SourceStatus{FailoverEvents: []FailoverEvent{}}
+var sourceStatusMutex sync.RWMutex
+
+// GetSourceStatus returns a copy of the current source status
+func GetSourceStatus() SourceStatus {
+	sourceStatusMutex.RLock()
+	defer sourceStatusMutex.RUnlock()
+
+	status := sourceStatus
+	status.Endpoints = append([]ZMQEndpoint{}, sourceStatus.Endpoints...)
+	status.FailoverEvents = append([]FailoverEvent{}, sourceStatus.FailoverEvents...)
+
+	return status
+}
+
+// setSourceName resets the source status for a new source
+func setSourceName(name string) {
+	sourceStatusMutex.Lock()
+	sourceStatus.Source = name
+	sourceStatus.ActiveEndpoint = ""
+	sourceStatus.ActivePriority = 0
+	sourceStatus.Endpoints = nil
+	sourceStatusMutex.Unlock()
+}
+
+// setActiveEndpoint updates the status with the currently active endpoint
+func setActiveEndpoint(endpoint ZMQEndpoint, endpoints []ZMQEndpoint) {
+	sourceStatusMutex.Lock()
+	sourceStatus.ActiveEndpoint = endpoint.Address
+	sourceStatus.ActivePriority = endpoint.Priority
+	sourceStatus.Endpoints = endpoints
+	sourceStatusMutex.Unlock()
+}
+
+// addFailoverEvent registers a failover event
+func addFailoverEvent(event FailoverEvent) {
+	sourceStatusMutex.Lock()
+	sourceStatus.Failovers++
+	sourceStatus.FailoverEvents = append(sourceStatus.FailoverEvents, event)
+
+	if len(sourceStatus.FailoverEvents) > maxFailoverEvents {
+		sourceStatus.FailoverEvents = sourceStatus.FailoverEvents[len(sourceStatus.FailoverEvents)-maxFailoverEvents:]
+	}
+	sourceStatusMutex.Unlock()
+}
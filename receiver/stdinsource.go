@@ -0,0 +1,70 @@
+package receiver
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"time"
+)
+
+// StdinSource reads XML messages from a reader (normally stdin).
+// Messages are separated by their XML declaration (<?xml ...?>), so multiple
+// messages can simply be concatenated.
+type StdinSource struct {
+	Reader io.Reader
+}
+
+// NewStdinSource creates a source which reads messages from stdin
+func NewStdinSource() *StdinSource {
+	return &StdinSource{Reader: os.Stdin}
+}
+
+// Name returns the source description
+func (source *StdinSource) Name() string {
+	return "stdin"
+}
+
+// Receive reads messages until the reader is exhausted
+func (source *StdinSource) Receive(messages chan<- Message, stop <-chan struct{}) error {
+	scanner := bufio.NewScanner(source.Reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var buffer bytes.Buffer
+
+	flush := func() bool {
+		payload := bytes.TrimSpace(buffer.Bytes())
+
+		if len(payload) == 0 {
+			return true
+		}
+
+		message := Message{
+			Payload:  append([]byte(nil), payload...),
+			Received: time.Now(),
+		}
+
+		buffer.Reset()
+
+		return deliver(messages, message, stop)
+	}
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("<?xml")) && !flush() {
+			return nil
+		}
+
+		buffer.Write(line)
+		buffer.WriteByte('\n')
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	flush()
+
+	return nil
+}
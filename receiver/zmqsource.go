@@ -0,0 +1,305 @@
+package receiver
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pebbe/zmq4"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// ZMQEndpoint is a ZMQ server. Endpoints with a lower priority value are preferred.
+type ZMQEndpoint struct {
+	Address  string `json:"address" mapstructure:"address"`
+	Priority int    `json:"priority" mapstructure:"priority"`
+}
+
+// ZMQSource receives messages from a ZMQ publisher, like the NDOV InfoPlus feed.
+// When multiple endpoints are configured, the source switches to the next endpoint when the
+// active connection is silent, and switches back when an endpoint with a higher priority recovers.
+type ZMQSource struct {
+	Endpoints []ZMQEndpoint
+	Envelopes map[string]string
+	Types     []string
+
+	// SilenceFactor determines after how many expected message intervals (based on the minimum
+	// average of the downtime detection) a connection is considered silent. DowntimeDetection
+	// returns the current downtime detection configuration, so configuration changes are applied.
+	SilenceFactor     float64
+	DowntimeDetection func() stores.DowntimeDetectionConfig
+
+	// FailbackInterval is the interval for checking whether a preferred endpoint has recovered,
+	// ProbeTimeout the maximum time to wait for a message from that endpoint
+	FailbackInterval time.Duration
+	ProbeTimeout     time.Duration
+}
+
+// measurementInterval is the interval for taking connection measurements, equal to the store measurements
+const measurementInterval = 20 * time.Second
+
+// newZMQSourceFromConfig creates a ZMQ source for the configured servers and envelopes
+func newZMQSourceFromConfig() *ZMQSource {
+	replacer := strings.NewReplacer(".", "_")
+	viper.SetEnvKeyReplacer(replacer)
+
+	var endpoints []ZMQEndpoint
+
+	if err := viper.UnmarshalKey("source.servers", &endpoints); err != nil {
+		log.Error().Err(err).Msg("Invalid source.servers configuration")
+	}
+
+	if len(endpoints) == 0 {
+		endpoints = []ZMQEndpoint{{Address: viper.GetString("source.server")}}
+	}
+
+	source := &ZMQSource{
+		Endpoints:         endpoints,
+		Envelopes:         getEnvelopes(),
+		Types:             subscribedTypes(),
+		SilenceFactor:     3,
+		DowntimeDetection: stores.Stores.ServiceStore.GetDowntimeDetection,
+		FailbackInterval:  5 * time.Minute,
+		ProbeTimeout:      30 * time.Second,
+	}
+
+	if viper.IsSet("source.failover.silence_factor") {
+		source.SilenceFactor = viper.GetFloat64("source.failover.silence_factor")
+	}
+	if viper.IsSet("source.failover.failback_interval") {
+		source.FailbackInterval = viper.GetDuration("source.failover.failback_interval")
+	}
+	if viper.IsSet("source.failover.probe_timeout") {
+		source.ProbeTimeout = viper.GetDuration("source.failover.probe_timeout")
+	}
+
+	source.sortEndpoints()
+
+	return source
+}
+
+// sortEndpoints sorts the endpoints on priority (preferred endpoints first)
+func (source *ZMQSource) sortEndpoints() {
+	sort.SliceStable(source.Endpoints, func(i, j int) bool {
+		return source.Endpoints[i].Priority < source.Endpoints[j].Priority
+	})
+}
+
+// Name returns the source description
+func (source *ZMQSource) Name() string {
+	addresses := make([]string, 0, len(source.Endpoints))
+
+	for _, endpoint := range source.Endpoints {
+		addresses = append(addresses, endpoint.Address)
+	}
+
+	return "zmq " + strings.Join(addresses, ",")
+}
+
+// connect sets up a subscriber socket, connects to the ZMQ server and subscribes to all envelopes
+func (source *ZMQSource) connect(endpoint ZMQEndpoint, timeout time.Duration) (*zmq4.Socket, error) {
+	subscriber, err := zmq4.NewSocket(zmq4.SUB)
+
+	if err != nil {
+		return nil, err
+	}
+
+	subscriber.SetLinger(0)
+	subscriber.SetRcvtimeo(timeout)
+
+	if err := subscriber.Connect(endpoint.Address); err != nil {
+		subscriber.Close()
+		return nil, err
+	}
+
+	for _, key := range source.Types {
+		subscriber.SetSubscribe(source.Envelopes[key])
+	}
+
+	return subscriber, nil
+}
+
+// newMonitor creates a store which is only used to measure the messages on a connection,
+// so the regular downtime detection can be reused to detect a silent connection
+func (source *ZMQSource) newMonitor() *stores.Store {
+	monitor := &stores.Store{DowntimeDetection: source.DowntimeDetection()}
+	monitor.ResetStatus()
+
+	return monitor
+}
+
+// isSilent checks whether the active connection is considered silent: either no messages have been
+// received for a multiple of the expected message interval, or the downtime detection reports DOWN.
+func (source *ZMQSource) isSilent(monitor *stores.Store, lastMessage, currentTime time.Time) bool {
+	if monitor.Status == stores.StatusDown {
+		return true
+	}
+
+	minimumAverage := source.DowntimeDetection().CurrentMinimumAverage(currentTime)
+
+	if minimumAverage <= 0 || source.SilenceFactor <= 0 {
+		return false
+	}
+
+	silenceTimeout := time.Duration(source.SilenceFactor / minimumAverage * float64(time.Second))
+
+	return currentTime.Sub(lastMessage) > silenceTimeout
+}
+
+// Receive receives messages until the stop channel is closed
+func (source *ZMQSource) Receive(messages chan<- Message, stop <-chan struct{}) error {
+	if len(source.Types) < len(source.Envelopes) {
+		log.Info().Strs("systems", source.Types).Msg("Not subscribing to all envelopes")
+	}
+
+	for _, key := range source.Types {
+		log.Info().
+			Str("system", key).
+			Str("envelope", source.Envelopes[key]).
+			Msg("Subscribed to envelope")
+	}
+
+	active := 0
+	subscriber, err := source.connect(source.Endpoints[active], 1*time.Second)
+
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("host", source.Endpoints[active].Address).Msg("Connected to server")
+	setActiveEndpoint(source.Endpoints[active], source.Endpoints)
+
+	defer func() {
+		subscriber.Close()
+	}()
+
+	monitor := source.newMonitor()
+	lastMessage := time.Now()
+	lastMeasurement := time.Now()
+	lastProbe := time.Now()
+
+	probing := false
+	probeResult := make(chan int, 1)
+
+	switchEndpoint := func(index int, reason string) {
+		newSubscriber, err := source.connect(source.Endpoints[index], 1*time.Second)
+
+		if err != nil {
+			log.Error().Err(err).Str("host", source.Endpoints[index].Address).Msg("Could not connect to server")
+			return
+		}
+
+		event := FailoverEvent{
+			Time:   time.Now(),
+			From:   source.Endpoints[active].Address,
+			To:     source.Endpoints[index].Address,
+			Reason: reason,
+		}
+
+		log.Warn().
+			Str("from", event.From).
+			Str("to", event.To).
+			Str("reason", reason).
+			Msg("Switching to other server")
+
+		subscriber.Close()
+		subscriber = newSubscriber
+		active = index
+
+		monitor = source.newMonitor()
+		lastMessage = time.Now()
+		lastProbe = time.Now()
+
+		addFailoverEvent(event)
+		setActiveEndpoint(source.Endpoints[active], source.Endpoints)
+	}
+
+	for !stopped(stop) {
+		currentTime := time.Now()
+
+		if currentTime.Sub(lastMeasurement) >= measurementInterval {
+			monitor.SetPolicies(stores.RetentionPolicies{}, source.DowntimeDetection())
+			monitor.TakeMeasurement()
+			lastMeasurement = currentTime
+		}
+
+		if len(source.Endpoints) > 1 {
+			select {
+			case index := <-probeResult:
+				probing = false
+				lastProbe = currentTime
+
+				if index >= 0 && index < active {
+					switchEndpoint(index, "failback")
+				}
+			default:
+			}
+
+			if source.isSilent(monitor, lastMessage, currentTime) {
+				switchEndpoint((active+1)%len(source.Endpoints), "silence")
+			} else if active > 0 && !probing && currentTime.Sub(lastProbe) >= source.FailbackInterval {
+				probing = true
+				go source.probe(source.Endpoints[:active], probeResult)
+			}
+		}
+
+		msg, err := subscriber.RecvMessageBytes(0)
+
+		if err != nil || len(msg) < 2 {
+			continue
+		}
+
+		lastMessage = time.Now()
+		monitor.Counters.Processed++
+
+		message := Message{
+			Envelope:   string(msg[0]),
+			Payload:    msg[1],
+			Compressed: true,
+			Received:   lastMessage,
+		}
+
+		if !deliver(messages, message, stop) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// probe checks the given (preferred) endpoints in order and reports the index of the first
+// endpoint which delivers a message, or -1 when none of them do
+func (source *ZMQSource) probe(endpoints []ZMQEndpoint, result chan<- int) {
+	for index, endpoint := range endpoints {
+		if source.probeEndpoint(endpoint) {
+			result <- index
+			return
+		}
+	}
+
+	result <- -1
+}
+
+// probeEndpoint waits for a single message on an endpoint
+func (source *ZMQSource) probeEndpoint(endpoint ZMQEndpoint) bool {
+	subscriber, err := source.connect(endpoint, source.ProbeTimeout)
+
+	if err != nil {
+		return false
+	}
+
+	defer subscriber.Close()
+
+	_, err = subscriber.RecvMessageBytes(0)
+
+	if err != nil {
+		log.Debug().Str("host", endpoint.Address).Msg("No messages received from preferred server")
+		return false
+	}
+
+	log.Info().Str("host", endpoint.Address).Msg("Preferred server has recovered")
+
+	return true
+}
@@ -0,0 +1,107 @@
+package receiver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/spf13/viper"
+)
+
+func TestZMQSourceEndpoints(t *testing.T) {
+	viper.Set("source.servers", []map[string]interface{}{
+		{"address": "tcp://backup:1234", "priority": 2},
+		{"address": "tcp://primary:1234", "priority": 1},
+	})
+	defer viper.Set("source.servers", nil)
+
+	source := newZMQSourceFromConfig()
+
+	if len(source.Endpoints) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %d", len(source.Endpoints))
+	}
+
+	if source.Endpoints[0].Address != "tcp://primary:1234" {
+		t.Errorf("Primary endpoint should be first, got %s", source.Endpoints[0].Address)
+	}
+}
+
+func TestZMQSourceSingleServer(t *testing.T) {
+	viper.Set("source.server", "tcp://single:1234")
+	defer viper.Set("source.server", nil)
+
+	source := newZMQSourceFromConfig()
+
+	if len(source.Endpoints) != 1 || source.Endpoints[0].Address != "tcp://single:1234" {
+		t.Errorf("Expected single endpoint from source.server, got %+v", source.Endpoints)
+	}
+}
+
+func TestZMQSourceSilence(t *testing.T) {
+	source := &ZMQSource{
+		SilenceFactor:     3,
+		DowntimeDetection: func() stores.DowntimeDetectionConfig { return stores.ServiceDowntimeDetection },
+	}
+
+	monitor := source.newMonitor()
+
+	// Day time: one message per minute expected, so silent after 3 minutes
+	day := time.Date(2019, time.January, 1, 12, 0, 0, 0, time.Local)
+
+	if source.isSilent(monitor, day.Add(-2*time.Minute), day) {
+		t.Error("Connection should not be silent after 2 minutes")
+	}
+	if !source.isSilent(monitor, day.Add(-4*time.Minute), day) {
+		t.Error("Connection should be silent after 4 minutes")
+	}
+
+	// Night time: one message per 30 minutes expected
+	night := time.Date(2019, time.January, 1, 3, 0, 0, 0, time.Local)
+
+	if source.isSilent(monitor, night.Add(-30*time.Minute), night) {
+		t.Error("Connection should not be silent at night after 30 minutes")
+	}
+
+	// Downtime detection reports DOWN:
+	monitor.Status = stores.StatusDown
+
+	if !source.isSilent(monitor, day, day) {
+		t.Error("Connection should be silent when downtime detection reports DOWN")
+	}
+}
+
+func TestZMQSourceConfiguredDowntimeDetection(t *testing.T) {
+	defer stores.Stores.ServiceStore.SetPolicies(stores.RetentionPolicies{}, stores.ServiceDowntimeDetection)
+
+	source := newZMQSourceFromConfig()
+	monitor := source.newMonitor()
+	day := time.Date(2019, time.January, 1, 12, 0, 0, 0, time.Local)
+
+	// One message per 10 minutes expected after a configuration change:
+	detection := stores.ServiceDowntimeDetection
+	detection.MinAverage = float64(1) / 600
+	stores.Stores.ServiceStore.SetPolicies(stores.RetentionPolicies{}, detection)
+
+	if source.isSilent(monitor, day.Add(-10*time.Minute), day) {
+		t.Error("Connection should not be silent after 10 minutes with the changed configuration")
+	}
+	if !source.isSilent(monitor, day.Add(-31*time.Minute), day) {
+		t.Error("Connection should be silent after 31 minutes with the changed configuration")
+	}
+}
+
+func TestFailoverEvents(t *testing.T) {
+	for i := 0; i < maxFailoverEvents+5; i++ {
+		addFailoverEvent(FailoverEvent{Time: time.Now(), From: "a", To: "b", Reason: "silence"})
+	}
+
+	status := GetSourceStatus()
+
+	if len(status.FailoverEvents) != maxFailoverEvents {
+		t.Errorf("Expected %d failover events, got %d", maxFailoverEvents, len(status.FailoverEvents))
+	}
+
+	if status.Failovers < maxFailoverEvents+5 {
+		t.Errorf("Wrong number of failovers: %d", status.Failovers)
+	}
+}
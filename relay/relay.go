@@ -0,0 +1,93 @@
+package relay
+
+import (
+	"strings"
+
+	"github.com/rijdendetreinen/gotrain/forwarder"
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rijdendetreinen/gotrain/stores"
+)
+
+// DefaultPrefix is the prefix of all envelopes when no prefix is configured
+const DefaultPrefix = "/gotrain"
+
+// Sender sends multipart messages consisting of an envelope and a payload
+type Sender interface {
+	// Send sends a message. An empty payload indicates that the item has been hidden or removed.
+	Send(envelope string, payload []byte)
+	Close()
+}
+
+// Relay re-publishes all processed departures, arrivals and services as JSON, using envelopes
+// which allow subscribers to filter on a prefix:
+//
+//	{prefix}/departures/{station}/{service type}/{service ID}
+//	{prefix}/arrivals/{station}/{service type}/{service ID}
+//	{prefix}/services/{service type}/{service number}
+//
+// ZMQ subscriptions match on a prefix of the envelope, so subscribe with a trailing slash:
+// {prefix}/departures/UT also matches departures from UTG, {prefix}/departures/UT/ does not.
+//
+// Hidden and removed items are sent with an empty payload (tombstone).
+type Relay struct {
+	Prefix   string
+	Language string
+
+	sender    Sender
+	forwarder *forwarder.Forwarder
+}
+
+// NewRelay creates a relay for the given store collection
+func NewRelay(collection *stores.StoreCollection, sender Sender) *Relay {
+	relay := &Relay{
+		Prefix:   DefaultPrefix,
+		Language: "nl",
+		sender:   sender,
+	}
+
+	relay.forwarder = forwarder.NewForwarder(collection, forwarder.Keys{
+		Departure: relay.DepartureEnvelope,
+		Arrival:   relay.ArrivalEnvelope,
+		Service:   relay.ServiceEnvelope,
+	}, sender.Send)
+
+	return relay
+}
+
+// Start registers the relay as listener of the stores
+func (relay *Relay) Start() {
+	relay.forwarder.Language = relay.Language
+	relay.forwarder.Start(false)
+}
+
+// Stop removes the listeners from the stores and closes the socket
+func (relay *Relay) Stop() {
+	relay.forwarder.Stop()
+	relay.sender.Close()
+}
+
+// DepartureEnvelope returns the envelope of a departure
+func (relay *Relay) DepartureEnvelope(departure models.Departure) string {
+	return relay.envelope("departures", departure.Station.Code, departure.ServiceTypeCode, departure.ServiceID)
+}
+
+// ArrivalEnvelope returns the envelope of an arrival
+func (relay *Relay) ArrivalEnvelope(arrival models.Arrival) string {
+	return relay.envelope("arrivals", arrival.Station.Code, arrival.ServiceTypeCode, arrival.ServiceID)
+}
+
+// ServiceEnvelope returns the envelope of a service
+func (relay *Relay) ServiceEnvelope(service models.Service) string {
+	return relay.envelope("services", service.ServiceTypeCode, service.ServiceNumber)
+}
+
+// levelReplacer replaces separators within an envelope level
+var levelReplacer = strings.NewReplacer("/", "_")
+
+func (relay *Relay) envelope(levels ...string) string {
+	for i, level := range levels {
+		levels[i] = levelReplacer.Replace(level)
+	}
+
+	return relay.Prefix + "/" + strings.Join(levels, "/")
+}
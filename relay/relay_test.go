@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/forwarder/forwardertest"
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func TestRelay(t *testing.T) {
+	collection := forwardertest.NewCollection()
+	collection.DepartureStore.ProcessDeparture(forwardertest.GenerateDeparture())
+
+	sender := forwardertest.NewRecorder()
+	relay := NewRelay(collection, sender)
+	relay.Start()
+
+	if sender.Count() != 0 {
+		t.Error("Store contents should not be sent on start")
+	}
+
+	departure := forwardertest.GenerateDeparture()
+	departure.Timestamp = departure.Timestamp.Add(time.Minute)
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	envelope := "/gotrain/departures/UT/IC/1234"
+	payload, _ := sender.Message(envelope)
+	var message map[string]interface{}
+
+	if err := json.Unmarshal(payload, &message); err != nil {
+		t.Fatalf("Invalid message on %s: %s", envelope, err)
+	}
+
+	if message["service_number"] != "1234" {
+		t.Errorf("Wrong message: %v", message)
+	}
+
+	relay.Stop()
+
+	if !sender.Closed() {
+		t.Error("Stopped relay should close the sender")
+	}
+}
+
+func TestEnvelopes(t *testing.T) {
+	relay := NewRelay(forwardertest.NewCollection(), forwardertest.NewRecorder())
+	relay.Prefix = "/trains"
+
+	var service models.Service
+	service.ServiceTypeCode = "SPR"
+	service.ServiceNumber = "1234"
+
+	if envelope := relay.ServiceEnvelope(service); envelope != "/trains/services/SPR/1234" {
+		t.Errorf("Wrong service envelope: %s", envelope)
+	}
+
+	var arrival models.Arrival
+	arrival.Station.Code = "ASD"
+	arrival.ServiceTypeCode = "IC"
+	arrival.ServiceID = "12/34"
+
+	if envelope := relay.ArrivalEnvelope(arrival); envelope != "/trains/arrivals/ASD/IC/12_34" {
+		t.Errorf("Wrong arrival envelope: %s", envelope)
+	}
+}
@@ -0,0 +1,82 @@
+package relay
+
+import (
+	"sync"
+
+	"github.com/pebbe/zmq4"
+	"github.com/rijdendetreinen/gotrain/stores"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// SocketSender sends messages on a ZMQ PUB socket
+type SocketSender struct {
+	// ZMQ sockets are not thread safe, while the stores notify their listeners concurrently
+	mutex  sync.Mutex
+	socket *zmq4.Socket
+}
+
+// Bind creates a ZMQ PUB socket bound to the given address (e.g. tcp://*:7665)
+func Bind(address string) (*SocketSender, error) {
+	socket, err := zmq4.NewSocket(zmq4.PUB)
+
+	if err != nil {
+		return nil, err
+	}
+
+	socket.SetLinger(0)
+
+	if err := socket.Bind(address); err != nil {
+		socket.Close()
+		return nil, err
+	}
+
+	return &SocketSender{socket: socket}, nil
+}
+
+// Send sends a message consisting of an envelope frame and a payload frame
+func (sender *SocketSender) Send(envelope string, payload []byte) {
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+
+	if _, err := sender.socket.SendMessage(envelope, payload); err != nil {
+		log.Warn().Err(err).Str("envelope", envelope).Msg("Could not send relay message")
+	}
+}
+
+// Close closes the socket
+func (sender *SocketSender) Close() {
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+
+	sender.socket.Close()
+}
+
+// SetupFromConfig binds the configured relay socket and creates a relay.
+// It returns nil when no relay address is configured.
+func SetupFromConfig(collection *stores.StoreCollection) (*Relay, error) {
+	address := viper.GetString("relay.address")
+
+	if address == "" {
+		return nil, nil
+	}
+
+	sender, err := Bind(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	relay := NewRelay(collection, sender)
+
+	if viper.IsSet("relay.prefix") {
+		relay.Prefix = viper.GetString("relay.prefix")
+	}
+	if viper.IsSet("relay.language") {
+		relay.Language = viper.GetString("relay.language")
+	}
+
+	log.Info().Str("address", address).Str("prefix", relay.Prefix).Msg("ZMQ relay enabled")
+
+	return relay, nil
+}
@@ -0,0 +1,38 @@
+package serializers
+
+import (
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// ArrivalToJSON generates an interface (convertible to JSON) with all arrival details
+func ArrivalToJSON(arrival models.Arrival, language string) map[string]interface{} {
+	response := map[string]interface{}{
+		"service_id":          arrival.ServiceID,
+		"name":                NullString(arrival.ServiceName),
+		"line_number":         NullString(arrival.LineNumber),
+		"timestamp":           arrival.Timestamp,
+		"status":              arrival.Status,
+		"service_date":        arrival.ServiceDate,
+		"service_number":      arrival.ServiceNumber,
+		"station":             arrival.Station.Code,
+		"type":                arrival.ServiceType,
+		"type_code":           arrival.ServiceTypeCode,
+		"company":             arrival.Company,
+		"origin_actual":       NullString(arrival.ActualOriginString()),
+		"origin_planned":      NullString(arrival.PlannedOriginString()),
+		"origin_actual_codes": arrival.ActualOriginCodes(),
+		"via":                 NullString(arrival.ViaStationsString()),
+		"arrival_time":        LocalTimeString(arrival.ArrivalTime),
+		"platform_actual":     NullString(arrival.PlatformActual),
+		"platform_planned":    NullString(arrival.PlatformPlanned),
+		"delay":               arrival.Delay,
+		"cancelled":           arrival.Cancelled,
+		"platform_changed":    arrival.PlatformChanged(),
+
+		"remarks": []interface{}{},
+	}
+
+	response["remarks"] = models.GetRemarks(arrival.Modifications, language)
+
+	return response
+}
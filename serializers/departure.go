@@ -0,0 +1,123 @@
+package serializers
+
+import (
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// DepartureToJSON generates an interface (convertible to JSON) with all departure details
+func DepartureToJSON(departure models.Departure, language string, verbose bool, service *models.Service) map[string]interface{} {
+	response := map[string]interface{}{
+		"service_id":               departure.ServiceID,
+		"name":                     NullString(departure.ServiceName),
+		"line_number":              NullString(departure.LineNumber),
+		"timestamp":                departure.Timestamp,
+		"status":                   departure.Status,
+		"service_date":             departure.ServiceDate,
+		"service_number":           departure.ServiceNumber,
+		"station":                  departure.Station.Code,
+		"type":                     departure.ServiceType,
+		"type_code":                departure.ServiceTypeCode,
+		"company":                  departure.Company,
+		"destination_actual":       NullString(departure.ActualDestinationString()),
+		"destination_planned":      NullString(departure.PlannedDestinationString()),
+		"destination_actual_codes": departure.ActualDestinationCodes(),
+		"via":                      NullString(departure.ActualViaStationsString()),
+		"departure_time":           LocalTimeString(departure.DepartureTime),
+		"platform_actual":          NullString(departure.PlatformActual),
+		"platform_planned":         NullString(departure.PlatformPlanned),
+		"delay":                    departure.Delay,
+		"cancelled":                departure.Cancelled,
+		"platform_changed":         departure.PlatformChanged(),
+
+		"remarks": []interface{}{},
+		"tips":    []interface{}{},
+
+		"wings": []interface{}{},
+	}
+
+	response["remarks"], response["tips"] = departure.GetRemarksTips(language)
+
+	responseWings := []interface{}{}
+
+	if departure.Cancelled {
+		// Override actual destination and via stations with planned destination and via:
+		response["destination_actual"] = response["destination_planned"]
+		response["via"] = NullString(departure.PlannedViaStationsString())
+		response["delay"] = 0
+	}
+
+	if verbose {
+		var serviceStops map[string]models.ServiceStop
+
+		if service != nil {
+			serviceStops = service.GetStops()
+		}
+
+		for _, trainWing := range departure.TrainWings {
+			wingResponse := map[string]interface{}{
+				"destination_actual":  trainWing.DestinationActualString(),
+				"destination_planned": trainWing.DestinationPlannedString(),
+				"remarks":             models.GetRemarks(trainWing.Modifications, language),
+				"stops":               []interface{}{},
+			}
+
+			stops := []interface{}{}
+
+			wingStops := trainWing.Stations
+
+			if departure.Cancelled {
+				wingStops = trainWing.StationsPlanned
+			}
+
+			for _, station := range wingStops {
+				stopData := map[string]interface{}{
+					"code":                       station.Code,
+					"short":                      station.NameShort,
+					"medium":                     station.NameMedium,
+					"long":                       station.NameLong,
+					"assistance_available":       false,
+					"accessible":                 false,
+					"arrival_time":               nil,
+					"arrival_platform":           nil,
+					"arrival_cancelled":          false,
+					"arrival_delay":              0,
+					"arrival_platform_changed":   false,
+					"departure_time":             nil,
+					"departure_platform":         nil,
+					"departure_cancelled":        false,
+					"departure_delay":            0,
+					"departure_platform_changed": false,
+				}
+
+				serviceStop, exists := serviceStops[station.Code]
+				if exists {
+					stopData["assistance_available"] = serviceStop.AssistanceAvailable
+					stopData["accessible"] = serviceStop.StationAccessible
+
+					stopData["arrival_time"] = LocalTimeString(serviceStop.ArrivalTime)
+					stopData["arrival_platform"] = NullString(serviceStop.ArrivalPlatformActual)
+					stopData["arrival_cancelled"] = serviceStop.ArrivalCancelled
+					stopData["arrival_delay"] = serviceStop.ArrivalDelay
+					stopData["arrival_platform_changed"] = serviceStop.ArrivalPlatformChanged()
+
+					stopData["departure_time"] = LocalTimeString(serviceStop.DepartureTime)
+					stopData["departure_platform"] = NullString(serviceStop.DeparturePlatformActual)
+					stopData["departure_cancelled"] = serviceStop.DepartureCancelled
+					stopData["departure_delay"] = serviceStop.DepartureDelay
+					stopData["departure_platform_changed"] = serviceStop.DeparturePlatformChanged()
+				}
+
+				stops = append(stops, stopData)
+			}
+
+			wingResponse["material"] = materialsToJSON(trainWing.Material, language, verbose)
+			wingResponse["stops"] = stops
+
+			responseWings = append(responseWings, wingResponse)
+		}
+	}
+
+	response["wings"] = responseWings
+
+	return response
+}
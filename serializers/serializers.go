@@ -0,0 +1,54 @@
+// Package serializers converts departures, arrivals and services to the JSON structure which is
+// used by the REST API and the publishers
+package serializers
+
+import (
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// LocalTimeString formats a time as RFC 3339 in the local time zone, or returns nil for a zero time
+func LocalTimeString(originalTime time.Time) *string {
+	if !originalTime.IsZero() {
+		formattedTime := originalTime.Local().Format(time.RFC3339)
+		return &formattedTime
+	}
+
+	return nil
+}
+
+// NullString returns nil for an empty string
+func NullString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+func materialToJSON(material models.Material, language string, verbose bool) map[string]interface{} {
+	materialResponse := map[string]interface{}{
+		"type":             material.NaterialType,
+		"accessible":       material.Accessible,
+		"number":           material.NormalizedNumber(),
+		"position":         material.Position,
+		"remains_behind":   material.RemainsBehind,
+		"closed":           material.Closed,
+		"added":            material.Added,
+		"destination":      material.DestinationActual.NameLong,
+		"destination_code": material.DestinationActual.Code,
+	}
+
+	return materialResponse
+}
+
+func materialsToJSON(materials []models.Material, language string, verbose bool) []map[string]interface{} {
+	materialsResponse := []map[string]interface{}{}
+
+	for _, material := range materials {
+		materialsResponse = append(materialsResponse, materialToJSON(material, language, verbose))
+	}
+
+	return materialsResponse
+}
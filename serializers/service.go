@@ -0,0 +1,99 @@
+package serializers
+
+import (
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// ServiceToJSON generates an interface (convertible to JSON) with all service details
+func ServiceToJSON(service models.Service, language string, verbose bool) map[string]interface{} {
+	response := map[string]interface{}{
+		"id":             service.ID,
+		"timestamp":      service.Timestamp,
+		"service_date":   service.ServiceDate,
+		"service_number": service.ServiceNumber,
+		"type":           service.ServiceType,
+		"type_code":      service.ServiceTypeCode,
+		"line_number":    NullString(service.LineNumber),
+		"company":        service.Company,
+
+		"journey_planner":      service.JourneyPlanner,
+		"reservation_required": service.ReservationRequired,
+		"special_ticket":       service.SpecialTicket,
+		"with_supplement":      service.WithSupplement,
+
+		"parts":   []interface{}{},
+		"remarks": models.GetRemarks(service.Modifications, language),
+		"tips":    []interface{}{},
+	}
+
+	responseParts := []interface{}{}
+
+	for _, part := range service.ServiceParts {
+		partResponse := map[string]interface{}{
+			"service_number": part.ServiceNumber,
+			"remarks":        models.GetRemarks(part.Modifications, language),
+			"tips":           []interface{}{},
+			"stops":          []interface{}{},
+		}
+
+		var stops []models.ServiceStop
+
+		if verbose {
+			stops = part.Stops
+		} else {
+			stops = part.GetStoppingStations()
+		}
+
+		responseStops := []interface{}{}
+
+		for _, stop := range stops {
+			responseStops = append(responseStops, serviceStopToJSON(stop, language, verbose))
+		}
+
+		partResponse["stops"] = responseStops
+		responseParts = append(responseParts, partResponse)
+	}
+
+	response["parts"] = responseParts
+
+	return response
+}
+
+func serviceStopToJSON(stop models.ServiceStop, language string, verbose bool) map[string]interface{} {
+	stopResponse := map[string]interface{}{
+		"station":                  stop.Station,
+		"recognizable_destination": stop.RecognizableDestination,
+		"station_accessible":       stop.StationAccessible,
+		"assistance_available":     stop.AssistanceAvailable,
+		"stopping_actual":          stop.StoppingActual,
+		"stopping_planned":         stop.StoppingPlanned,
+		"stop_type":                stop.StopType,
+		"do_not_board":             stop.DoNotBoard,
+
+		"arrival_time":             LocalTimeString(stop.ArrivalTime),
+		"arrival_platform_actual":  NullString(stop.ArrivalPlatformActual),
+		"arrival_platform_planned": NullString(stop.ArrivalPlatformPlanned),
+		"arrival_delay":            stop.ArrivalDelay,
+		"arrival_cancelled":        stop.ArrivalCancelled,
+
+		"departure_time":             LocalTimeString(stop.DepartureTime),
+		"departure_platform_actual":  NullString(stop.DeparturePlatformActual),
+		"departure_platform_planned": NullString(stop.DeparturePlatformPlanned),
+		"departure_delay":            stop.DepartureDelay,
+		"departure_cancelled":        stop.DepartureCancelled,
+
+		"remarks":  models.GetRemarks(stop.Modifications, language),
+		"tips":     []interface{}{},
+		"material": materialsToJSON(stop.Material, language, verbose),
+	}
+
+	if stop.DoNotBoard {
+		if language == "nl" {
+			stopResponse["remarks"] = append(stopResponse["tips"].([]interface{}), "Niet instappen")
+		} else {
+			stopResponse["remarks"] = append(stopResponse["tips"].([]interface{}), "Do not board")
+		}
+	}
+
+	return stopResponse
+}
@@ -0,0 +1,167 @@
+package siri
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// EstimatedTimetableDelivery contains the estimated journeys
+type EstimatedTimetableDelivery struct {
+	Version                      string                       `xml:"version,attr"`
+	ResponseTimestamp            time.Time                    `xml:"ResponseTimestamp"`
+	EstimatedJourneyVersionFrame EstimatedJourneyVersionFrame `xml:"EstimatedJourneyVersionFrame"`
+}
+
+// EstimatedJourneyVersionFrame groups the estimated journeys
+type EstimatedJourneyVersionFrame struct {
+	RecordedAtTime          time.Time                 `xml:"RecordedAtTime"`
+	EstimatedVehicleJourney []EstimatedVehicleJourney `xml:"EstimatedVehicleJourney"`
+}
+
+// EstimatedVehicleJourney is a single journey (service part) with its calls
+type EstimatedVehicleJourney struct {
+	RecordedAtTime          *time.Time              `xml:"RecordedAtTime,omitempty"`
+	LineRef                 string                  `xml:"LineRef"`
+	DirectionRef            string                  `xml:"DirectionRef"`
+	FramedVehicleJourneyRef FramedVehicleJourneyRef `xml:"FramedVehicleJourneyRef"`
+	ExtraJourney            bool                    `xml:"ExtraJourney,omitempty"`
+	Cancellation            bool                    `xml:"Cancellation,omitempty"`
+	VehicleMode             string                  `xml:"VehicleMode"`
+	PublishedLineName       string                  `xml:"PublishedLineName,omitempty"`
+	OperatorRef             string                  `xml:"OperatorRef,omitempty"`
+	ProductCategoryRef      string                  `xml:"ProductCategoryRef,omitempty"`
+	Monitored               bool                    `xml:"Monitored"`
+	EstimatedCalls          []EstimatedCall         `xml:"EstimatedCalls>EstimatedCall"`
+	IsCompleteStopSequence  bool                    `xml:"IsCompleteStopSequence"`
+}
+
+// EstimatedCall is a call of a journey at a stop
+type EstimatedCall struct {
+	StopPointRef          string     `xml:"StopPointRef"`
+	Order                 int        `xml:"Order"`
+	StopPointName         string     `xml:"StopPointName,omitempty"`
+	ExtraCall             bool       `xml:"ExtraCall,omitempty"`
+	Cancellation          bool       `xml:"Cancellation,omitempty"`
+	AimedArrivalTime      *time.Time `xml:"AimedArrivalTime,omitempty"`
+	ExpectedArrivalTime   *time.Time `xml:"ExpectedArrivalTime,omitempty"`
+	ArrivalStatus         string     `xml:"ArrivalStatus,omitempty"`
+	ArrivalPlatformName   string     `xml:"ArrivalPlatformName,omitempty"`
+	AimedDepartureTime    *time.Time `xml:"AimedDepartureTime,omitempty"`
+	ExpectedDepartureTime *time.Time `xml:"ExpectedDepartureTime,omitempty"`
+	DepartureStatus       string     `xml:"DepartureStatus,omitempty"`
+	DeparturePlatformName string     `xml:"DeparturePlatformName,omitempty"`
+}
+
+// EstimatedTimetable creates a SIRI-ET document with a journey for every part of the given services.
+// Hidden services are ignored.
+func EstimatedTimetable(services []models.Service, currentTime time.Time) *Siri {
+	document := newSiri(currentTime)
+
+	delivery := &EstimatedTimetableDelivery{
+		Version:           Version,
+		ResponseTimestamp: currentTime,
+		EstimatedJourneyVersionFrame: EstimatedJourneyVersionFrame{
+			RecordedAtTime:          currentTime,
+			EstimatedVehicleJourney: make([]EstimatedVehicleJourney, 0),
+		},
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ID < services[j].ID
+	})
+
+	for _, service := range services {
+		if service.Hidden {
+			continue
+		}
+
+		for _, part := range service.ServiceParts {
+			delivery.EstimatedJourneyVersionFrame.EstimatedVehicleJourney = append(
+				delivery.EstimatedJourneyVersionFrame.EstimatedVehicleJourney, estimatedVehicleJourney(service, part))
+		}
+	}
+
+	document.ServiceDelivery.EstimatedTimetableDelivery = delivery
+
+	return document
+}
+
+// estimatedVehicleJourney converts a service part to a journey
+func estimatedVehicleJourney(service models.Service, part models.ServicePart) EstimatedVehicleJourney {
+	serviceNumber := part.ServiceNumber
+
+	if serviceNumber == "" {
+		serviceNumber = service.ServiceNumber
+	}
+
+	journey := EstimatedVehicleJourney{
+		RecordedAtTime: timeRef(service.Timestamp),
+		LineRef:        lineRef(service.LineNumber, serviceNumber),
+		FramedVehicleJourneyRef: FramedVehicleJourneyRef{
+			DataFrameRef:           service.ServiceDate,
+			DatedVehicleJourneyRef: serviceNumber,
+		},
+		ExtraJourney:           hasModification(service.Modifications, models.ModificationExtraTrain) || hasModification(part.Modifications, models.ModificationExtraTrain),
+		Cancellation:           hasModification(service.Modifications, models.ModificationCancelledTrain) || hasModification(part.Modifications, models.ModificationCancelledTrain),
+		VehicleMode:            "rail",
+		PublishedLineName:      service.ServiceType,
+		OperatorRef:            service.Company,
+		ProductCategoryRef:     service.ServiceTypeCode,
+		Monitored:              true,
+		IsCompleteStopSequence: true,
+	}
+
+	order := 0
+
+	for _, stop := range part.Stops {
+		if !stop.IsStopping() {
+			continue
+		}
+
+		order++
+		journey.EstimatedCalls = append(journey.EstimatedCalls, estimatedCall(stop, order))
+		journey.DirectionRef = stop.Station.Code
+	}
+
+	return journey
+}
+
+// estimatedCall converts a service stop to a call
+func estimatedCall(stop models.ServiceStop, order int) EstimatedCall {
+	call := EstimatedCall{
+		StopPointRef:  stop.Station.Code,
+		Order:         order,
+		StopPointName: stop.Station.NameLong,
+		ExtraCall:     stop.StoppingActual && !stop.StoppingPlanned,
+		Cancellation:  !stop.StoppingActual,
+	}
+
+	if !stop.ArrivalTime.IsZero() {
+		call.AimedArrivalTime = timeRef(stop.ArrivalTime)
+		call.ExpectedArrivalTime = expectedTimeRef(stop.ArrivalTime, stop.ArrivalDelay)
+		call.ArrivalStatus = callStatus(stop.ArrivalDelay, stop.ArrivalCancelled || !stop.StoppingActual, true)
+		call.ArrivalPlatformName = stop.ArrivalPlatformActual
+	}
+
+	if !stop.DepartureTime.IsZero() {
+		call.AimedDepartureTime = timeRef(stop.DepartureTime)
+		call.ExpectedDepartureTime = expectedTimeRef(stop.DepartureTime, stop.DepartureDelay)
+		call.DepartureStatus = callStatus(stop.DepartureDelay, stop.DepartureCancelled || !stop.StoppingActual, true)
+		call.DeparturePlatformName = stop.DeparturePlatformActual
+	}
+
+	return call
+}
+
+// hasModification checks whether a modification of the given type is present
+func hasModification(modifications []models.Modification, modificationType int) bool {
+	for _, modification := range modifications {
+		if modification.ModificationType == modificationType {
+			return true
+		}
+	}
+
+	return false
+}
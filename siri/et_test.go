@@ -0,0 +1,34 @@
+package siri
+
+import (
+	"testing"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func TestEstimatedTimetable(t *testing.T) {
+	hidden := generateService()
+	hidden.ServiceNumber = "5678"
+	hidden.GenerateID()
+	hidden.Hidden = true
+
+	document := EstimatedTimetable([]models.Service{generateService(), hidden}, testTime)
+
+	assertSampleDocument(t, document, "estimated_timetable.xml")
+}
+
+func TestEstimatedTimetableCancelled(t *testing.T) {
+	service := generateService()
+	service.Modifications = []models.Modification{{ModificationType: models.ModificationCancelledTrain}}
+
+	document := EstimatedTimetable([]models.Service{service}, testTime)
+	journeys := document.ServiceDelivery.EstimatedTimetableDelivery.EstimatedJourneyVersionFrame.EstimatedVehicleJourney
+
+	if len(journeys) != 1 || !journeys[0].Cancellation {
+		t.Error("Journey should be cancelled")
+	}
+
+	if journeys[0].ExtraJourney {
+		t.Error("Journey should not be an extra journey")
+	}
+}
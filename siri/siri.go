@@ -0,0 +1,109 @@
+// Package siri exports the contents of the stores as SIRI (Service Interface for Real Time Information)
+// documents: Estimated Timetable (SIRI-ET) and Stop Monitoring (SIRI-SM)
+package siri
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Namespace is the SIRI XML namespace
+const Namespace = "http://www.siri.org.uk/siri"
+
+// Version is the SIRI version of the generated documents
+const Version = "2.0"
+
+// ProducerRef identifies GoTrain as producer of the documents
+const ProducerRef = "GoTrain"
+
+// Call statuses
+const (
+	StatusOnTime    = "onTime"
+	StatusDelayed   = "delayed"
+	StatusCancelled = "cancelled"
+	StatusNoReport  = "noReport"
+)
+
+// Siri is the root element of a SIRI document
+type Siri struct {
+	XMLName         xml.Name        `xml:"http://www.siri.org.uk/siri Siri"`
+	Version         string          `xml:"version,attr"`
+	ServiceDelivery ServiceDelivery `xml:"ServiceDelivery"`
+}
+
+// ServiceDelivery contains the delivered data
+type ServiceDelivery struct {
+	ResponseTimestamp          time.Time                   `xml:"ResponseTimestamp"`
+	ProducerRef                string                      `xml:"ProducerRef"`
+	StopMonitoringDelivery     *StopMonitoringDelivery     `xml:"StopMonitoringDelivery,omitempty"`
+	EstimatedTimetableDelivery *EstimatedTimetableDelivery `xml:"EstimatedTimetableDelivery,omitempty"`
+}
+
+// FramedVehicleJourneyRef identifies a journey on an operating day
+type FramedVehicleJourneyRef struct {
+	DataFrameRef           string `xml:"DataFrameRef"`
+	DatedVehicleJourneyRef string `xml:"DatedVehicleJourneyRef"`
+}
+
+// newSiri creates a SIRI document with an empty service delivery
+func newSiri(responseTimestamp time.Time) *Siri {
+	return &Siri{
+		Version: Version,
+		ServiceDelivery: ServiceDelivery{
+			ResponseTimestamp: responseTimestamp,
+			ProducerRef:       ProducerRef,
+		},
+	}
+}
+
+// Marshal encodes a SIRI document as indented XML, including the XML declaration
+func Marshal(document *Siri) ([]byte, error) {
+	data, err := xml.MarshalIndent(document, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+// callStatus returns the status of an arrival or departure
+func callStatus(delay int, cancelled, realTime bool) string {
+	switch {
+	case cancelled:
+		return StatusCancelled
+	case !realTime:
+		return StatusNoReport
+	case delay > 0:
+		return StatusDelayed
+	}
+
+	return StatusOnTime
+}
+
+// timeRef returns a reference to a time, or nil for the zero time
+func timeRef(value time.Time) *time.Time {
+	if value.IsZero() {
+		return nil
+	}
+
+	return &value
+}
+
+// expectedTimeRef returns a reference to the planned time plus the delay, or nil for the zero time
+func expectedTimeRef(planned time.Time, delay int) *time.Time {
+	if planned.IsZero() {
+		return nil
+	}
+
+	return timeRef(planned.Add(time.Duration(delay) * time.Second))
+}
+
+// lineRef returns the line number, or the service number for services without line number
+func lineRef(lineNumber, serviceNumber string) string {
+	if lineNumber != "" {
+		return lineNumber
+	}
+
+	return serviceNumber
+}
@@ -0,0 +1,132 @@
+package siri
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+var testTime = time.Date(2019, time.January, 27, 12, 0, 0, 0, time.UTC)
+
+func generateService() models.Service {
+	var service models.Service
+
+	service.ServiceNumber = "1234"
+	service.ServiceDate = "2019-01-27"
+	service.ServiceType = "Intercity"
+	service.ServiceTypeCode = "IC"
+	service.Company = "NS"
+	service.GenerateID()
+	service.Timestamp = testTime
+
+	departure := time.Date(2019, time.January, 27, 12, 30, 0, 0, time.UTC)
+
+	service.ServiceParts = []models.ServicePart{{
+		ServiceNumber: "1234",
+		Stops: []models.ServiceStop{
+			{
+				Station:                 models.Station{Code: "UT", NameLong: "Utrecht Centraal"},
+				StoppingActual:          true,
+				StoppingPlanned:         true,
+				DepartureTime:           departure,
+				DepartureDelay:          120,
+				DeparturePlatformActual: "5",
+			},
+			{
+				Station:         models.Station{Code: "ASB", NameLong: "Amsterdam Bijlmer ArenA"},
+				StoppingActual:  false,
+				StoppingPlanned: true,
+				ArrivalTime:     departure.Add(15 * time.Minute),
+				DepartureTime:   departure.Add(16 * time.Minute),
+			},
+			{
+				Station: models.Station{Code: "DVD", NameLong: "Duivendrecht"},
+			},
+			{
+				Station:               models.Station{Code: "ASD", NameLong: "Amsterdam Centraal"},
+				StoppingActual:        true,
+				StoppingPlanned:       true,
+				ArrivalTime:           departure.Add(30 * time.Minute),
+				ArrivalDelay:          60,
+				ArrivalPlatformActual: "7a",
+			},
+		},
+	}}
+
+	return service
+}
+
+func generateDeparture() models.Departure {
+	var departure models.Departure
+
+	departure.ServiceID = "1234"
+	departure.ServiceNumber = "1234"
+	departure.ServiceDate = "2019-01-27"
+	departure.ServiceType = "Intercity"
+	departure.ServiceTypeCode = "IC"
+	departure.Company = "NS"
+	departure.Station = models.Station{Code: "UT", NameLong: "Utrecht Centraal"}
+	departure.DestinationActual = []models.Station{{Code: "ASD", NameLong: "Amsterdam Centraal"}}
+	departure.GenerateID()
+	departure.Timestamp = testTime
+	departure.DepartureTime = time.Date(2019, time.January, 27, 12, 30, 0, 0, time.UTC)
+	departure.Delay = 120
+	departure.PlatformActual = "5"
+
+	return departure
+}
+
+func generateArrival() models.Arrival {
+	var arrival models.Arrival
+
+	arrival.ServiceID = "1234"
+	arrival.ServiceNumber = "1234"
+	arrival.ServiceDate = "2019-01-27"
+	arrival.ServiceType = "Intercity"
+	arrival.ServiceTypeCode = "IC"
+	arrival.Company = "NS"
+	arrival.Station = models.Station{Code: "UT", NameLong: "Utrecht Centraal"}
+	arrival.OriginActual = []models.Station{{Code: "EHV", NameLong: "Eindhoven Centraal"}}
+	arrival.GenerateID()
+	arrival.Timestamp = testTime.Add(-time.Minute)
+	arrival.ArrivalTime = time.Date(2019, time.January, 27, 12, 26, 0, 0, time.UTC)
+	arrival.PlatformActual = "5"
+
+	return arrival
+}
+
+// assertSampleDocument compares a generated document with a sample document in testdata,
+// and checks whether the sample document can be decoded again
+func assertSampleDocument(t *testing.T, document *Siri, filename string) {
+	t.Helper()
+
+	generated, err := Marshal(document)
+
+	if err != nil {
+		t.Fatalf("Could not marshal document: %v", err)
+	}
+
+	sample, err := os.ReadFile("testdata/" + filename)
+
+	if err != nil {
+		t.Fatalf("Could not read sample document: %v", err)
+	}
+
+	if !bytes.Equal(bytes.TrimSpace(generated), bytes.TrimSpace(sample)) {
+		t.Errorf("Generated document does not match %s:\n%s", filename, generated)
+	}
+
+	var decoded Siri
+
+	if err := xml.Unmarshal(sample, &decoded); err != nil {
+		t.Fatalf("Could not decode sample document: %v", err)
+	}
+
+	if decoded.XMLName.Space != Namespace || decoded.Version != Version {
+		t.Errorf("Wrong namespace or version: %s %s", decoded.XMLName.Space, decoded.Version)
+	}
+}
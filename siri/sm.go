@@ -0,0 +1,195 @@
+package siri
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// StopMonitoringDelivery contains the visits at a stop
+type StopMonitoringDelivery struct {
+	Version            string               `xml:"version,attr"`
+	ResponseTimestamp  time.Time            `xml:"ResponseTimestamp"`
+	MonitoringRef      string               `xml:"MonitoringRef"`
+	MonitoredStopVisit []MonitoredStopVisit `xml:"MonitoredStopVisit"`
+}
+
+// MonitoredStopVisit is a visit of a journey at the monitored stop
+type MonitoredStopVisit struct {
+	RecordedAtTime          time.Time               `xml:"RecordedAtTime"`
+	ItemIdentifier          string                  `xml:"ItemIdentifier"`
+	MonitoringRef           string                  `xml:"MonitoringRef"`
+	MonitoredVehicleJourney MonitoredVehicleJourney `xml:"MonitoredVehicleJourney"`
+}
+
+// MonitoredVehicleJourney is the journey which visits the monitored stop
+type MonitoredVehicleJourney struct {
+	LineRef                 string                  `xml:"LineRef"`
+	DirectionRef            string                  `xml:"DirectionRef"`
+	FramedVehicleJourneyRef FramedVehicleJourneyRef `xml:"FramedVehicleJourneyRef"`
+	VehicleMode             string                  `xml:"VehicleMode"`
+	PublishedLineName       string                  `xml:"PublishedLineName,omitempty"`
+	OperatorRef             string                  `xml:"OperatorRef,omitempty"`
+	ProductCategoryRef      string                  `xml:"ProductCategoryRef,omitempty"`
+	OriginRef               string                  `xml:"OriginRef,omitempty"`
+	OriginName              string                  `xml:"OriginName,omitempty"`
+	DestinationRef          string                  `xml:"DestinationRef,omitempty"`
+	DestinationName         string                  `xml:"DestinationName,omitempty"`
+	Monitored               bool                    `xml:"Monitored"`
+	MonitoredCall           MonitoredCall           `xml:"MonitoredCall"`
+}
+
+// MonitoredCall is the call at the monitored stop
+type MonitoredCall struct {
+	StopPointRef          string     `xml:"StopPointRef"`
+	StopPointName         string     `xml:"StopPointName,omitempty"`
+	AimedArrivalTime      *time.Time `xml:"AimedArrivalTime,omitempty"`
+	ExpectedArrivalTime   *time.Time `xml:"ExpectedArrivalTime,omitempty"`
+	ArrivalStatus         string     `xml:"ArrivalStatus,omitempty"`
+	ArrivalPlatformName   string     `xml:"ArrivalPlatformName,omitempty"`
+	AimedDepartureTime    *time.Time `xml:"AimedDepartureTime,omitempty"`
+	ExpectedDepartureTime *time.Time `xml:"ExpectedDepartureTime,omitempty"`
+	DepartureStatus       string     `xml:"DepartureStatus,omitempty"`
+	DeparturePlatformName string     `xml:"DeparturePlatformName,omitempty"`
+}
+
+// StopMonitoring creates a SIRI-SM document for a station. Arrivals and departures of the same
+// service are combined into a single visit. Hidden arrivals and departures are ignored.
+func StopMonitoring(station string, departures []models.Departure, arrivals []models.Arrival, currentTime time.Time) *Siri {
+	document := newSiri(currentTime)
+
+	visits := make(map[string]*MonitoredStopVisit)
+	sortTimes := make(map[string]time.Time)
+
+	visit := func(serviceDate, serviceNumber string) *MonitoredStopVisit {
+		key := serviceDate + "-" + serviceNumber
+
+		if _, exists := visits[key]; !exists {
+			visits[key] = &MonitoredStopVisit{
+				ItemIdentifier: key + "-" + station,
+				MonitoringRef:  station,
+				MonitoredVehicleJourney: MonitoredVehicleJourney{
+					FramedVehicleJourneyRef: FramedVehicleJourneyRef{
+						DataFrameRef:           serviceDate,
+						DatedVehicleJourneyRef: serviceNumber,
+					},
+					VehicleMode: "rail",
+					Monitored:   true,
+				},
+			}
+		}
+
+		return visits[key]
+	}
+
+	for _, arrival := range arrivals {
+		if arrival.Hidden || arrival.Station.Code != station {
+			continue
+		}
+
+		stopVisit := visit(arrival.ServiceDate, arrival.ServiceNumber)
+		stopVisit.addArrival(arrival)
+
+		sortTimes[stopVisit.ItemIdentifier] = arrival.RealArrivalTime()
+	}
+
+	for _, departure := range departures {
+		if departure.Hidden || departure.Station.Code != station {
+			continue
+		}
+
+		stopVisit := visit(departure.ServiceDate, departure.ServiceNumber)
+		stopVisit.addDeparture(departure)
+
+		// Departures are ordered on their departure time, arriving trains on their arrival time:
+		sortTimes[stopVisit.ItemIdentifier] = departure.RealDepartureTime()
+	}
+
+	delivery := &StopMonitoringDelivery{
+		Version:            Version,
+		ResponseTimestamp:  currentTime,
+		MonitoringRef:      station,
+		MonitoredStopVisit: make([]MonitoredStopVisit, 0, len(visits)),
+	}
+
+	for _, stopVisit := range visits {
+		delivery.MonitoredStopVisit = append(delivery.MonitoredStopVisit, *stopVisit)
+	}
+
+	sort.Slice(delivery.MonitoredStopVisit, func(i, j int) bool {
+		timeI := sortTimes[delivery.MonitoredStopVisit[i].ItemIdentifier]
+		timeJ := sortTimes[delivery.MonitoredStopVisit[j].ItemIdentifier]
+
+		if timeI.Equal(timeJ) {
+			return delivery.MonitoredStopVisit[i].ItemIdentifier < delivery.MonitoredStopVisit[j].ItemIdentifier
+		}
+
+		return timeI.Before(timeJ)
+	})
+
+	document.ServiceDelivery.StopMonitoringDelivery = delivery
+
+	return document
+}
+
+// addArrival adds the details of an arrival to the visit
+func (visit *MonitoredStopVisit) addArrival(arrival models.Arrival) {
+	journey := &visit.MonitoredVehicleJourney
+	journey.setService(arrival.LineNumber, arrival.ServiceNumber, arrival.ServiceType, arrival.ServiceTypeCode, arrival.Company)
+
+	if len(arrival.OriginActual) > 0 {
+		journey.OriginRef = arrival.OriginActual[0].Code
+		journey.OriginName = arrival.ActualOriginString()
+	}
+
+	// Terminating trains run in the direction of this station, departures override the direction:
+	if journey.DirectionRef == "" {
+		journey.DirectionRef = arrival.Station.Code
+	}
+
+	journey.MonitoredCall.StopPointRef = arrival.Station.Code
+	journey.MonitoredCall.StopPointName = arrival.Station.NameLong
+	journey.MonitoredCall.AimedArrivalTime = timeRef(arrival.ArrivalTime)
+	journey.MonitoredCall.ExpectedArrivalTime = expectedTimeRef(arrival.ArrivalTime, arrival.Delay)
+	journey.MonitoredCall.ArrivalStatus = callStatus(arrival.Delay, arrival.Cancelled, !arrival.NotRealTime)
+	journey.MonitoredCall.ArrivalPlatformName = arrival.PlatformActual
+
+	visit.updateRecordedAtTime(arrival.Timestamp)
+}
+
+// addDeparture adds the details of a departure to the visit
+func (visit *MonitoredStopVisit) addDeparture(departure models.Departure) {
+	journey := &visit.MonitoredVehicleJourney
+	journey.setService(departure.LineNumber, departure.ServiceNumber, departure.ServiceType, departure.ServiceTypeCode, departure.Company)
+
+	if len(departure.DestinationActual) > 0 {
+		journey.DirectionRef = departure.DestinationActual[len(departure.DestinationActual)-1].Code
+		journey.DestinationRef = journey.DirectionRef
+		journey.DestinationName = departure.ActualDestinationString()
+	}
+
+	journey.MonitoredCall.StopPointRef = departure.Station.Code
+	journey.MonitoredCall.StopPointName = departure.Station.NameLong
+	journey.MonitoredCall.AimedDepartureTime = timeRef(departure.DepartureTime)
+	journey.MonitoredCall.ExpectedDepartureTime = expectedTimeRef(departure.DepartureTime, departure.Delay)
+	journey.MonitoredCall.DepartureStatus = callStatus(departure.Delay, departure.Cancelled, !departure.NotRealTime)
+	journey.MonitoredCall.DeparturePlatformName = departure.PlatformActual
+
+	visit.updateRecordedAtTime(departure.Timestamp)
+}
+
+// setService sets the line and service details of the journey
+func (journey *MonitoredVehicleJourney) setService(lineNumber, serviceNumber, serviceType, serviceTypeCode, company string) {
+	journey.LineRef = lineRef(lineNumber, serviceNumber)
+	journey.PublishedLineName = serviceType
+	journey.OperatorRef = company
+	journey.ProductCategoryRef = serviceTypeCode
+}
+
+// updateRecordedAtTime keeps the time of the most recent information for this visit
+func (visit *MonitoredStopVisit) updateRecordedAtTime(timestamp time.Time) {
+	if timestamp.After(visit.RecordedAtTime) {
+		visit.RecordedAtTime = timestamp
+	}
+}
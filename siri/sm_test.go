@@ -0,0 +1,41 @@
+package siri
+
+import (
+	"testing"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func TestStopMonitoring(t *testing.T) {
+	other := generateDeparture()
+	other.Station.Code = "ASD"
+	other.GenerateID()
+
+	document := StopMonitoring("UT", []models.Departure{generateDeparture(), other}, []models.Arrival{generateArrival()}, testTime)
+
+	assertSampleDocument(t, document, "stop_monitoring.xml")
+}
+
+func TestStopMonitoringCancelled(t *testing.T) {
+	departure := generateDeparture()
+	departure.Cancelled = true
+
+	notRealTime := generateDeparture()
+	notRealTime.ServiceNumber = "5678"
+	notRealTime.NotRealTime = true
+
+	document := StopMonitoring("UT", []models.Departure{departure, notRealTime}, nil, testTime)
+	visits := document.ServiceDelivery.StopMonitoringDelivery.MonitoredStopVisit
+
+	if len(visits) != 2 {
+		t.Fatalf("Expected 2 visits, got %d", len(visits))
+	}
+
+	if status := visits[0].MonitoredVehicleJourney.MonitoredCall.DepartureStatus; status != StatusCancelled {
+		t.Errorf("Wrong status for cancelled departure: %s", status)
+	}
+
+	if status := visits[1].MonitoredVehicleJourney.MonitoredCall.DepartureStatus; status != StatusNoReport {
+		t.Errorf("Wrong status for non-realtime departure: %s", status)
+	}
+}
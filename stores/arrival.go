@@ -26,14 +26,14 @@ func (store *ArrivalStore) ProcessArrival(newArrival models.Arrival) {
 	if arrivalExists {
 		// Check for duplicate:
 		if existingArrival.ProductID == newArrival.ProductID {
-			log.Info().Str("ProductID", newArrival.ProductID).Msg("Arrival is duplicate")
+			store.messageLog().Info().Str("ProductID", newArrival.ProductID).Msg("Arrival is duplicate")
 
 			store.Counters.Duplicates++
 		}
 
 		// Check whether newArrival is actually newer:
 		if existingArrival.Timestamp.After(newArrival.Timestamp) {
-			log.Info().
+			store.messageLog().Info().
 				Str("ProductID", newArrival.ProductID).
 				Time("ExistingTimestamp", existingArrival.Timestamp).
 				Time("NewTimestamp", newArrival.Timestamp).
@@ -52,16 +52,28 @@ func (store *ArrivalStore) ProcessArrival(newArrival models.Arrival) {
 	threshold = threshold.Add(-10 * time.Second)
 
 	if newArrival.Timestamp.Before(threshold) {
-		log.Debug().Str("ProductID", newArrival.ProductID).Msg("Arrival is outdated")
+		store.messageLog().Debug().Str("ProductID", newArrival.ProductID).Msg("Arrival is outdated")
 		store.Counters.TooLate++
 	}
 
 	store.Lock()
+	previous, previousExists := store.arrivals[newArrival.ID]
+	store.recordHistory(newArrival.ID, newArrival.StoreItem, arrivalChanges(previous, newArrival))
 	store.arrivals[newArrival.ID] = newArrival
+	store.persist(bucketArrivals, newArrival.ID, newArrival)
+	store.wal.Append(walRecord{Arrival: &newArrival})
 	store.updateStationReference(newArrival.Station.Code, newArrival.ID)
 	store.Unlock()
 
 	store.Counters.Processed++
+
+	change := Change{ChangeUpdated, newArrival.ID, newArrival.Station.Code, newArrival, nil}
+
+	if previousExists {
+		change.Previous = previous
+	}
+
+	store.notifyChange(change)
 }
 
 func (store *ArrivalStore) updateStationReference(station, ID string) {
@@ -81,12 +93,10 @@ func (store *ArrivalStore) updateStationReference(station, ID string) {
 func (store *ArrivalStore) InitStore() {
 	store.arrivals = make(map[string]models.Arrival)
 	store.stations = make(map[string]map[string]struct{})
+	store.history = make(map[string][]HistoryEntry)
 
-	store.DowntimeDetection.MinAverage = float64(1) / 60        // One message per minute
-	store.DowntimeDetection.MinAverageNight = float64(1) / 1200 // One message per 20 minutes
-	store.DowntimeDetection.NightStartHour = 2                  // Night starts at 02:00
-	store.DowntimeDetection.NightEndHour = 5                    // Night ends at 05:00
-	store.DowntimeDetection.RecoveryTime = 70                   // 70 mins recovery time
+	store.Retention = RetentionPolicies{Default: ArrivalRetention}
+	store.DowntimeDetection = ArrivalDowntimeDetection
 }
 
 // GetNumberOfArrivals returns the number of arrivals in the store (unfiltered)
@@ -107,6 +117,21 @@ func (store *ArrivalStore) GetAllArrivals() map[string]models.Arrival {
 	return arrivals
 }
 
+// GetArrivals returns all arrivals in the store
+func (store *ArrivalStore) GetArrivals(includeHidden bool) []models.Arrival {
+	var arrivals []models.Arrival
+
+	store.RLock()
+	for _, arrival := range store.arrivals {
+		if includeHidden || !arrival.Hidden {
+			arrivals = append(arrivals, arrival)
+		}
+	}
+	store.RUnlock()
+
+	return arrivals
+}
+
 // GetStationArrivals returns all arrivals for a given station
 func (store *ArrivalStore) GetStationArrivals(station string, includeHidden bool) []models.Arrival {
 	var arrivals []models.Arrival
@@ -145,7 +170,7 @@ func (store *ArrivalStore) GetArrival(serviceID, serviceDate string, station str
 
 // ReadStore reads the save store contents
 func (store *ArrivalStore) ReadStore() error {
-	err := readGob("arrivals.gob", &store.arrivals)
+	err := readGob(arrivalsFile, &store.arrivals)
 
 	if err != nil {
 		return err
@@ -158,11 +183,36 @@ func (store *ArrivalStore) ReadStore() error {
 	return nil
 }
 
+// ReadBackend reads the arrivals from the backend
+func (store *ArrivalStore) ReadBackend() error {
+	return store.backend.Load(bucketArrivals, func(decode func(item interface{}) error) error {
+		var arrival models.Arrival
+
+		if err := decode(&arrival); err != nil {
+			return err
+		}
+
+		store.arrivals[arrival.ID] = arrival
+		store.updateStationReference(arrival.Station.Code, arrival.ID)
+
+		return nil
+	})
+}
+
+// persistAll writes all arrivals to the backend
+func (store *ArrivalStore) persistAll() {
+	store.Lock()
+	for ID, arrival := range store.arrivals {
+		store.persist(bucketArrivals, ID, arrival)
+	}
+	store.Unlock()
+}
+
 // SaveStore saves the arrivals store contents
 func (store *ArrivalStore) SaveStore() error {
 	store.RLock()
 
-	err := writeGob("arrivals.gob", store.arrivals)
+	err := writeGob(arrivalsFile, store.arrivals)
 
 	store.RUnlock()
 	return err
@@ -174,13 +224,18 @@ func (store *ArrivalStore) hideArrival(ID string) {
 	arrival := store.arrivals[ID]
 	arrival.Hidden = true
 	store.arrivals[ID] = arrival
+	store.persist(bucketArrivals, ID, arrival)
 	store.Unlock()
+
+	store.notifyChange(Change{ChangeHidden, ID, arrival.Station.Code, arrival, nil})
 }
 
 // deleteArrival deletes an arrival
 func (store *ArrivalStore) deleteArrival(arrival models.Arrival) {
 	store.Lock()
 	delete(store.arrivals, arrival.ID)
+	store.deleteHistory(arrival.ID)
+	store.unpersist(bucketArrivals, arrival.ID)
 
 	_, stationExists := store.stations[arrival.Station.Code]
 
@@ -192,15 +247,13 @@ func (store *ArrivalStore) deleteArrival(arrival models.Arrival) {
 	}
 
 	store.Unlock()
+
+	store.notifyChange(Change{ChangeRemoved, arrival.ID, arrival.Station.Code, arrival, nil})
 }
 
 // CleanUp removes outdated items
 func (store *ArrivalStore) CleanUp(currentTime time.Time) {
-	// Remove arrivals which have arrived 4 hours ago:
-	thresholdRemove := currentTime.Add(-4 * time.Hour)
-
-	// Hide arrivals which should have arrived 30 minutes ago:
-	thresholdHide := currentTime.Add(-30 * time.Minute)
+	retention := store.retentionPolicies()
 
 	log.Debug().Msg("Cleaning up arrival store")
 
@@ -210,6 +263,14 @@ func (store *ArrivalStore) CleanUp(currentTime time.Time) {
 	for arrivalID, arrival := range store.arrivals {
 		store.RUnlock()
 
+		policy := retention.Policy(arrival.Station.Code, arrival.ServiceTypeCode)
+
+		// Remove arrivals which have arrived some time ago (default 4 hours):
+		thresholdRemove := currentTime.Add(-policy.Remove)
+
+		// Hide arrivals which should have arrived recently (default 30 minutes ago):
+		thresholdHide := currentTime.Add(-policy.Hide)
+
 		if !arrival.Hidden && arrival.RealArrivalTime().Before(thresholdHide) {
 			log.Debug().Str("ArrivalID", arrivalID).Msg("Hiding arrival")
 
@@ -0,0 +1,263 @@
+package stores
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the departure, arrival and service stores
+const (
+	bucketDepartures = "departures"
+	bucketArrivals   = "arrivals"
+	bucketServices   = "services"
+)
+
+// Backend names
+const (
+	BackendMemory = "memory"
+	BackendBolt   = "bolt"
+)
+
+// Backend persists the items of the departure, arrival and service stores.
+// The stores keep all items in memory as well, so the backend is only read on startup.
+type Backend interface {
+	// Persistent returns true when every change is persisted. In that case, the stores are
+	// loaded from the backend instead of the saved store files.
+	Persistent() bool
+
+	// Put adds or replaces an item
+	Put(bucket, key string, item interface{}) error
+
+	// Delete removes an item
+	Delete(bucket, key string) error
+
+	// Load calls fn for every item in a bucket. decode decodes the item into the given pointer.
+	Load(bucket string, fn func(decode func(item interface{}) error) error) error
+
+	Close() error
+}
+
+// MemoryBackend keeps the items in memory only. Store contents are persisted by saving the
+// store files (on shutdown and periodically).
+type MemoryBackend struct{}
+
+// Persistent returns false, items are not persisted
+func (MemoryBackend) Persistent() bool { return false }
+
+// Put does nothing
+func (MemoryBackend) Put(bucket, key string, item interface{}) error { return nil }
+
+// Delete does nothing
+func (MemoryBackend) Delete(bucket, key string) error { return nil }
+
+// Load does nothing
+func (MemoryBackend) Load(bucket string, fn func(decode func(item interface{}) error) error) error {
+	return nil
+}
+
+// Close does nothing
+func (MemoryBackend) Close() error { return nil }
+
+// boltWriteBuffer is the number of changes which can be queued before Put and Delete block
+const boltWriteBuffer = 10000
+
+// boltWrite is a queued change of the bolt backend. A write with a done channel is a flush
+// request, which is closed when all earlier changes have been committed.
+type boltWrite struct {
+	bucket string
+	key    string
+	value  []byte // nil for a delete
+	done   chan struct{}
+}
+
+// BoltBackend writes every change through to an embedded bbolt database, so the contents of the
+// stores survive a crash. Items are stored as gob.
+// Changes are queued and committed by a single writer, so the stores don't wait for the disk.
+// The writer commits all queued changes in one transaction, in the order they were made.
+type BoltBackend struct {
+	db       *bolt.DB
+	writes   chan boltWrite
+	finished chan struct{}
+}
+
+// OpenBoltBackend opens (or creates) a bbolt database
+func OpenBoltBackend(path string) (*BoltBackend, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{bucketDepartures, bucketArrivals, bucketServices} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	backend := &BoltBackend{
+		db:       db,
+		writes:   make(chan boltWrite, boltWriteBuffer),
+		finished: make(chan struct{}),
+	}
+
+	go backend.write()
+
+	return backend, nil
+}
+
+// Persistent returns true, every change is written to the database
+func (backend *BoltBackend) Persistent() bool { return true }
+
+// Put queues adding or replacing an item
+func (backend *BoltBackend) Put(bucket, key string, item interface{}) error {
+	var buffer bytes.Buffer
+
+	if err := gob.NewEncoder(&buffer).Encode(item); err != nil {
+		return err
+	}
+
+	backend.writes <- boltWrite{bucket: bucket, key: key, value: buffer.Bytes()}
+
+	return nil
+}
+
+// Delete queues removing an item
+func (backend *BoltBackend) Delete(bucket, key string) error {
+	backend.writes <- boltWrite{bucket: bucket, key: key}
+
+	return nil
+}
+
+// Flush waits until all queued changes have been committed
+func (backend *BoltBackend) Flush() {
+	done := make(chan struct{})
+	backend.writes <- boltWrite{done: done}
+	<-done
+}
+
+// write commits the queued changes until the queue is closed
+func (backend *BoltBackend) write() {
+	defer close(backend.finished)
+
+	for write := range backend.writes {
+		batch := []boltWrite{write}
+
+		// Commit all changes which are already queued in the same transaction:
+	queued:
+		for {
+			select {
+			case write, ok := <-backend.writes:
+				if !ok {
+					break queued
+				}
+
+				batch = append(batch, write)
+			default:
+				break queued
+			}
+		}
+
+		err := backend.db.Update(func(tx *bolt.Tx) error {
+			for _, write := range batch {
+				var err error
+
+				switch {
+				case write.done != nil:
+					continue
+				case write.value == nil:
+					err = tx.Bucket([]byte(write.bucket)).Delete([]byte(write.key))
+				default:
+					err = tx.Bucket([]byte(write.bucket)).Put([]byte(write.key), write.value)
+				}
+
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			log.Error().Err(err).Int("changes", len(batch)).Msg("Could not persist items")
+		}
+
+		for _, write := range batch {
+			if write.done != nil {
+				close(write.done)
+			}
+		}
+	}
+}
+
+// Load calls fn for every item in a bucket, after committing the queued changes
+func (backend *BoltBackend) Load(bucket string, fn func(decode func(item interface{}) error) error) error {
+	backend.Flush()
+
+	return backend.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).ForEach(func(key, value []byte) error {
+			return fn(func(item interface{}) error {
+				return gob.NewDecoder(bytes.NewReader(value)).Decode(item)
+			})
+		})
+	})
+}
+
+// Close commits the queued changes and closes the database. Put and Delete must not be called
+// after closing the backend.
+func (backend *BoltBackend) Close() error {
+	close(backend.writes)
+	<-backend.finished
+
+	return backend.db.Close()
+}
+
+// OpenBackend opens a backend by name. path is the database file for the bolt backend.
+func OpenBackend(name, path string) (Backend, error) {
+	switch name {
+	case "", BackendMemory:
+		return MemoryBackend{}, nil
+	case BackendBolt:
+		return OpenBoltBackend(path)
+	}
+
+	return nil, fmt.Errorf("unknown store backend %q", name)
+}
+
+// persist writes an item to the backend of the store. Must be called while holding the write lock,
+// so the backend receives the changes in the same order as the store. Backends must not block on
+// disk I/O, since that would block all readers of the store.
+func (store *Store) persist(bucket, key string, item interface{}) {
+	if store.backend == nil {
+		return
+	}
+
+	if err := store.backend.Put(bucket, key, item); err != nil {
+		log.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("Could not persist item")
+	}
+}
+
+// unpersist removes an item from the backend of the store. Must be called while holding the write lock.
+func (store *Store) unpersist(bucket, key string) {
+	if store.backend == nil {
+		return
+	}
+
+	if err := store.backend.Delete(bucket, key); err != nil {
+		log.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("Could not remove persisted item")
+	}
+}
@@ -0,0 +1,122 @@
+package stores
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoltBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stores.db")
+	backend, err := OpenBoltBackend(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collection := &StoreCollection{}
+	collection.DepartureStore.InitStore()
+	collection.ArrivalStore.InitStore()
+	collection.ServiceStore.InitStore()
+	collection.SetBackend(backend)
+
+	departure := generateDeparture()
+	collection.DepartureStore.ProcessDeparture(departure)
+	collection.ArrivalStore.ProcessArrival(generateArrival())
+	collection.ServiceStore.ProcessService(generateService())
+
+	// Hide the departure, and remove the arrival:
+	collection.DepartureStore.hideDeparture(departure.ID)
+	collection.ArrivalStore.CleanUp(time.Date(2019, time.January, 28, 0, 0, 0, 0, time.UTC))
+	collection.ArrivalStore.CleanUp(time.Date(2019, time.January, 28, 0, 0, 0, 0, time.UTC))
+
+	if collection.ArrivalStore.GetNumberOfArrivals() != 0 {
+		t.Fatal("Arrival should be removed")
+	}
+
+	backend.Close()
+
+	// Read in empty stores:
+	backend, err = OpenBoltBackend(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	restored := &StoreCollection{}
+	restored.DepartureStore.InitStore()
+	restored.ArrivalStore.InitStore()
+	restored.ServiceStore.InitStore()
+	restored.SetBackend(backend)
+
+	for _, err := range []error{restored.DepartureStore.ReadBackend(), restored.ArrivalStore.ReadBackend(), restored.ServiceStore.ReadBackend()} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restoredDeparture := restored.DepartureStore.GetDeparture("1234", "2019-01-27", "UT")
+
+	if restoredDeparture == nil || !restoredDeparture.Hidden || restoredDeparture.Station.NameLong != "Utrecht Centraal" {
+		t.Errorf("Wrong restored departure: %+v", restoredDeparture)
+	}
+
+	if len(restored.DepartureStore.GetStationDepartures("UT", true)) != 1 {
+		t.Error("Station reference of restored departure missing")
+	}
+
+	if restored.ArrivalStore.GetNumberOfArrivals() != 0 {
+		t.Error("Removed arrival should not be restored")
+	}
+
+	if len(restored.ServiceStore.GetServicesByNumber("1234")) != 1 {
+		t.Error("Service should be restored, including number reference")
+	}
+}
+
+func TestBoltBackendOrder(t *testing.T) {
+	backend, err := OpenBoltBackend(filepath.Join(t.TempDir(), "stores.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	// Changes of the same key must be committed in order:
+	for i := 0; i < 100; i++ {
+		backend.Put(bucketDepartures, "a", i)
+		backend.Put(bucketDepartures, "b", i)
+	}
+	backend.Delete(bucketDepartures, "b")
+
+	values := make(map[int]bool)
+
+	err = backend.Load(bucketDepartures, func(decode func(item interface{}) error) error {
+		var value int
+		err := decode(&value)
+		values[value] = true
+
+		return err
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 1 || !values[99] {
+		t.Errorf("Expected only the last value of a, got %v", values)
+	}
+}
+
+func TestOpenBackend(t *testing.T) {
+	backend, err := OpenBackend("", "")
+
+	if err != nil || backend.Persistent() {
+		t.Error("Default backend should be the memory backend")
+	}
+
+	if _, err := OpenBackend("redis", ""); err == nil {
+		t.Error("Unknown backend should return an error")
+	}
+}
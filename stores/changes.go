@@ -0,0 +1,68 @@
+package stores
+
+import "sync"
+
+// ChangeUpdated is the action for a new or updated item
+const ChangeUpdated = "updated"
+
+// ChangeHidden is the action for an item which has been hidden
+const ChangeHidden = "hidden"
+
+// ChangeRemoved is the action for an item which has been removed from the store
+const ChangeRemoved = "removed"
+
+// Change describes a change of a single item in a store
+type Change struct {
+	Action  string
+	ID      string
+	Station string // Station code (empty for services)
+
+	// Item is the models.Departure, models.Arrival or models.Service after the change
+	Item interface{}
+
+	// Previous is the item before an update, or nil for new items and other actions
+	Previous interface{}
+}
+
+// ChangeListener is called for every change in a store. Listeners are called synchronously
+// while processing messages, so they should return quickly.
+type ChangeListener func(Change)
+
+// changeListeners keeps track of all listeners of a store
+type changeListeners struct {
+	mutex     sync.RWMutex
+	listeners map[int]ChangeListener
+	nextID    int
+}
+
+// AddListener registers a change listener. It returns an ID which can be used to remove the listener.
+func (store *Store) AddListener(listener ChangeListener) int {
+	store.changeListeners.mutex.Lock()
+	defer store.changeListeners.mutex.Unlock()
+
+	if store.changeListeners.listeners == nil {
+		store.changeListeners.listeners = make(map[int]ChangeListener)
+	}
+
+	store.changeListeners.nextID++
+	store.changeListeners.listeners[store.changeListeners.nextID] = listener
+
+	return store.changeListeners.nextID
+}
+
+// RemoveListener removes a change listener
+func (store *Store) RemoveListener(id int) {
+	store.changeListeners.mutex.Lock()
+	delete(store.changeListeners.listeners, id)
+	store.changeListeners.mutex.Unlock()
+}
+
+// notifyChange calls all listeners for a change
+func (store *Store) notifyChange(change Change) {
+	store.changeListeners.mutex.RLock()
+	defer store.changeListeners.mutex.RUnlock()
+
+	for _, listener := range store.changeListeners.listeners {
+		listener(change)
+	}
+}
@@ -0,0 +1,70 @@
+package stores
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func TestChangeListeners(t *testing.T) {
+	var store DepartureStore
+	store.InitStore()
+
+	var changes []Change
+
+	id := store.AddListener(func(change Change) {
+		changes = append(changes, change)
+	})
+
+	departure := generateDeparture()
+	store.ProcessDeparture(departure)
+
+	if len(changes) != 1 || changes[0].Action != ChangeUpdated || changes[0].ID != departure.ID || changes[0].Station != "UT" {
+		t.Fatalf("Expected update for new departure, got %+v", changes)
+	}
+
+	if changes[0].Previous != nil {
+		t.Error("New departure should not have a previous version")
+	}
+
+	updated := departure
+	updated.Timestamp = departure.Timestamp.Add(time.Minute)
+	updated.PlatformActual = "7"
+	store.ProcessDeparture(updated)
+
+	if len(changes) != 2 || changes[1].Previous == nil || changes[1].Previous.(models.Departure).PlatformActual != "" {
+		t.Fatalf("Expected update with previous version, got %+v", changes)
+	}
+
+	changes = changes[:1]
+	departure = updated
+
+	// Outdated departures should not result in a change:
+	outdated := departure
+	outdated.Timestamp = departure.Timestamp.Add(-time.Minute)
+	store.ProcessDeparture(outdated)
+
+	if len(changes) != 1 {
+		t.Fatalf("Outdated departure should not result in a change, got %d changes", len(changes))
+	}
+
+	store.CleanUp(departure.DepartureTime.Add(15 * time.Minute))
+
+	if len(changes) != 2 || changes[1].Action != ChangeHidden {
+		t.Fatalf("Expected hidden change after cleanup, got %+v", changes)
+	}
+
+	store.CleanUp(departure.DepartureTime.Add(5 * time.Hour))
+
+	if len(changes) != 3 || changes[2].Action != ChangeRemoved {
+		t.Fatalf("Expected removed change after cleanup, got %+v", changes)
+	}
+
+	store.RemoveListener(id)
+	store.ProcessDeparture(generateDeparture())
+
+	if len(changes) != 3 {
+		t.Error("Removed listener should not be called")
+	}
+}
@@ -0,0 +1,93 @@
+package stores
+
+import (
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// Connection is a departure which calls at a destination station
+type Connection struct {
+	Departure models.Departure
+
+	// Arrival is the stop at the destination station. It is nil when the service is not known,
+	// and the connection is only based on the stations of the train wings.
+	Arrival *models.ServiceStop
+}
+
+// ExpectedArrivalTime returns the arrival time at the destination station including delay,
+// or the zero time when the arrival time is unknown
+func (connection Connection) ExpectedArrivalTime() time.Time {
+	if connection.Arrival == nil || connection.Arrival.ArrivalTime.IsZero() {
+		return time.Time{}
+	}
+
+	return connection.Arrival.ArrivalTime.Add(time.Duration(connection.Arrival.ArrivalDelay) * time.Second)
+}
+
+// GetConnections returns the (visible) departures from a station which call at the destination station.
+// The stops of the service are used when the service is known, otherwise the stations of the train wings.
+func (collection *StoreCollection) GetConnections(from, to string) []Connection {
+	var connections []Connection
+
+	if from == to {
+		return connections
+	}
+
+	for _, departure := range collection.DepartureStore.GetStationDepartures(from, false) {
+		service := collection.ServiceStore.GetService(departure.ServiceNumber, departure.ServiceDate)
+
+		if service != nil {
+			if stop := findArrivalStop(*service, from, to); stop != nil {
+				connections = append(connections, Connection{departure, stop})
+			}
+
+			continue
+		}
+
+		if wingsCallAt(departure, to) {
+			connections = append(connections, Connection{Departure: departure})
+		}
+	}
+
+	return connections
+}
+
+// findArrivalStop finds the stop at station to, after station from, in any part of the service.
+// It returns nil when the service does not (or no longer) call at that station.
+func findArrivalStop(service models.Service, from, to string) *models.ServiceStop {
+	for _, part := range service.ServiceParts {
+		departed := false
+
+		for _, stop := range part.Stops {
+			if stop.Station.Code == from && stop.IsStopping() {
+				departed = true
+				continue
+			}
+
+			if departed && stop.Station.Code == to {
+				// Not stopping in this part, another part (wing) may still call at the station:
+				if !stop.StoppingActual || stop.ArrivalCancelled {
+					break
+				}
+
+				return &stop
+			}
+		}
+	}
+
+	return nil
+}
+
+// wingsCallAt checks whether any of the train wings of a departure calls at a station
+func wingsCallAt(departure models.Departure, station string) bool {
+	for _, wing := range departure.TrainWings {
+		for _, wingStation := range wing.Stations {
+			if wingStation.Code == station {
+				return true
+			}
+		}
+	}
+
+	return false
+}
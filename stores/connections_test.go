@@ -0,0 +1,116 @@
+package stores
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func generateConnectionService() models.Service {
+	var service models.Service
+
+	service.ServiceNumber = "1234"
+	service.ServiceDate = "2019-01-27"
+	service.GenerateID()
+
+	departure := time.Date(2019, time.January, 27, 12, 34, 56, 78, time.UTC)
+
+	service.ServiceParts = []models.ServicePart{{
+		ServiceNumber: "1234",
+		Stops: []models.ServiceStop{
+			{Station: models.Station{Code: "RTD"}, StoppingActual: true, StoppingPlanned: true, DepartureTime: departure.Add(-30 * time.Minute)},
+			{Station: models.Station{Code: "UT"}, StoppingActual: true, StoppingPlanned: true, DepartureTime: departure},
+			{Station: models.Station{Code: "DVD"}},
+			{Station: models.Station{Code: "ASD"}, StoppingActual: true, StoppingPlanned: true, ArrivalTime: departure.Add(30 * time.Minute), ArrivalDelay: 120},
+		},
+	}}
+
+	return service
+}
+
+func TestConnectionsFromService(t *testing.T) {
+	var collection StoreCollection
+	collection.DepartureStore.InitStore()
+	collection.ServiceStore.InitStore()
+
+	collection.DepartureStore.ProcessDeparture(generateDeparture())
+	collection.ServiceStore.ProcessService(generateConnectionService())
+
+	connections := collection.GetConnections("UT", "ASD")
+
+	if len(connections) != 1 {
+		t.Fatalf("Expected 1 connection, got %d", len(connections))
+	}
+
+	expected := time.Date(2019, time.January, 27, 13, 6, 56, 78, time.UTC)
+
+	if !connections[0].ExpectedArrivalTime().Equal(expected) {
+		t.Errorf("Wrong expected arrival time: %s", connections[0].ExpectedArrivalTime())
+	}
+
+	// Passing stations and earlier stations are not connections:
+	for _, to := range []string{"DVD", "RTD", "UT"} {
+		if len(collection.GetConnections("UT", to)) != 0 {
+			t.Errorf("Unexpected connection from UT to %s", to)
+		}
+	}
+}
+
+func TestConnectionsCancelledStop(t *testing.T) {
+	var collection StoreCollection
+	collection.DepartureStore.InitStore()
+	collection.ServiceStore.InitStore()
+
+	service := generateConnectionService()
+	service.ServiceParts[0].Stops[3].StoppingActual = false
+
+	collection.DepartureStore.ProcessDeparture(generateDeparture())
+	collection.ServiceStore.ProcessService(service)
+
+	if len(collection.GetConnections("UT", "ASD")) != 0 {
+		t.Error("Cancelled stop should not be a connection")
+	}
+}
+
+func TestConnectionsOtherPart(t *testing.T) {
+	var collection StoreCollection
+	collection.DepartureStore.InitStore()
+	collection.ServiceStore.InitStore()
+
+	// The first part no longer calls at ASD, the second part (wing) does:
+	service := generateConnectionService()
+	wing := generateConnectionService().ServiceParts[0]
+	wing.ServiceNumber = "11234"
+	service.ServiceParts[0].Stops[3].StoppingActual = false
+	service.ServiceParts = append(service.ServiceParts, wing)
+
+	collection.DepartureStore.ProcessDeparture(generateDeparture())
+	collection.ServiceStore.ProcessService(service)
+
+	connections := collection.GetConnections("UT", "ASD")
+
+	if len(connections) != 1 || connections[0].Arrival == nil || !connections[0].Arrival.StoppingActual {
+		t.Errorf("Expected a connection with the second part: %+v", connections)
+	}
+}
+
+func TestConnectionsFromWings(t *testing.T) {
+	var collection StoreCollection
+	collection.DepartureStore.InitStore()
+	collection.ServiceStore.InitStore()
+
+	departure := generateDeparture()
+	departure.TrainWings = []models.TrainWing{{Stations: []models.Station{{Code: "ASB"}, {Code: "ASD"}}}}
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	connections := collection.GetConnections("UT", "ASD")
+
+	if len(connections) != 1 {
+		t.Fatalf("Expected 1 connection, got %d", len(connections))
+	}
+
+	if connections[0].Arrival != nil || !connections[0].ExpectedArrivalTime().IsZero() {
+		t.Error("Arrival should be unknown for connections based on wings")
+	}
+}
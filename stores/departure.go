@@ -26,14 +26,14 @@ func (store *DepartureStore) ProcessDeparture(newDeparture models.Departure) {
 	if departureExists {
 		// Check for duplicate:
 		if existingDeparture.ProductID == newDeparture.ProductID {
-			log.Info().Str("ProductID", newDeparture.ProductID).Msg("Departure is duplicate")
+			store.messageLog().Info().Str("ProductID", newDeparture.ProductID).Msg("Departure is duplicate")
 
 			store.Counters.Duplicates++
 		}
 
 		// Check whether newDeparture is actually newer:
 		if existingDeparture.Timestamp.After(newDeparture.Timestamp) {
-			log.Info().
+			store.messageLog().Info().
 				Str("ProductID", newDeparture.ProductID).
 				Time("ExistingTimestamp", existingDeparture.Timestamp).
 				Time("NewTimestamp", newDeparture.Timestamp).
@@ -51,7 +51,7 @@ func (store *DepartureStore) ProcessDeparture(newDeparture models.Departure) {
 	threshold = threshold.Add(-10 * time.Second)
 
 	if newDeparture.Timestamp.Before(threshold) {
-		log.Debug().Str("ProductID", newDeparture.ProductID).Msg("Departure is outdated")
+		store.messageLog().Debug().Str("ProductID", newDeparture.ProductID).Msg("Departure is outdated")
 		store.Counters.TooLate++
 	}
 
@@ -61,11 +61,23 @@ func (store *DepartureStore) ProcessDeparture(newDeparture models.Departure) {
 	}
 
 	store.Lock()
+	previous, previousExists := store.departures[newDeparture.ID]
+	store.recordHistory(newDeparture.ID, newDeparture.StoreItem, departureChanges(previous, newDeparture))
 	store.departures[newDeparture.ID] = newDeparture
 	store.updateStationReference(newDeparture.Station.Code, newDeparture.ID)
+	store.persist(bucketDepartures, newDeparture.ID, newDeparture)
+	store.wal.Append(walRecord{Departure: &newDeparture})
 	store.Unlock()
 
 	store.Counters.Processed++
+
+	change := Change{ChangeUpdated, newDeparture.ID, newDeparture.Station.Code, newDeparture, nil}
+
+	if previousExists {
+		change.Previous = previous
+	}
+
+	store.notifyChange(change)
 }
 
 func (store *DepartureStore) updateStationReference(station, ID string) {
@@ -82,16 +94,14 @@ func (store *DepartureStore) updateStationReference(station, ID string) {
 }
 
 // InitStore initializes the departure store by creating the departures map
-// and sets the downtime detection config
+// and sets the default retention policy and downtime detection config
 func (store *DepartureStore) InitStore() {
 	store.departures = make(map[string]models.Departure)
 	store.stations = make(map[string]map[string]struct{})
+	store.history = make(map[string][]HistoryEntry)
 
-	store.DowntimeDetection.MinAverage = float64(1) / 60       // One message per minute
-	store.DowntimeDetection.MinAverageNight = float64(1) / 600 // One message per 10 minutes
-	store.DowntimeDetection.NightStartHour = 2                 // Night starts at 02:00
-	store.DowntimeDetection.NightEndHour = 5                   // Night ends at 05:00
-	store.DowntimeDetection.RecoveryTime = 70                  // 70 mins recovery time
+	store.Retention = RetentionPolicies{Default: DepartureRetention}
+	store.DowntimeDetection = DepartureDowntimeDetection
 }
 
 // GetNumberOfDepartures returns the number of departures in the store (unfiltered)
@@ -112,6 +122,21 @@ func (store *DepartureStore) GetAllDepartures() map[string]models.Departure {
 	return departures
 }
 
+// GetDepartures returns all departures in the store
+func (store *DepartureStore) GetDepartures(includeHidden bool) []models.Departure {
+	var departures []models.Departure
+
+	store.RLock()
+	for _, departure := range store.departures {
+		if includeHidden || !departure.Hidden {
+			departures = append(departures, departure)
+		}
+	}
+	store.RUnlock()
+
+	return departures
+}
+
 // GetStationDepartures returns all departures for a given station
 func (store *DepartureStore) GetStationDepartures(station string, includeHidden bool) []models.Departure {
 	var departures []models.Departure
@@ -150,7 +175,7 @@ func (store *DepartureStore) GetDeparture(serviceID, serviceDate string, station
 
 // ReadStore reads the save store contents
 func (store *DepartureStore) ReadStore() error {
-	err := readGob("departures.gob", &store.departures)
+	err := readGob(departuresFile, &store.departures)
 
 	if err != nil {
 		return err
@@ -163,11 +188,36 @@ func (store *DepartureStore) ReadStore() error {
 	return nil
 }
 
+// ReadBackend reads the departures from the backend
+func (store *DepartureStore) ReadBackend() error {
+	return store.backend.Load(bucketDepartures, func(decode func(item interface{}) error) error {
+		var departure models.Departure
+
+		if err := decode(&departure); err != nil {
+			return err
+		}
+
+		store.departures[departure.ID] = departure
+		store.updateStationReference(departure.Station.Code, departure.ID)
+
+		return nil
+	})
+}
+
+// persistAll writes all departures to the backend
+func (store *DepartureStore) persistAll() {
+	store.Lock()
+	for ID, departure := range store.departures {
+		store.persist(bucketDepartures, ID, departure)
+	}
+	store.Unlock()
+}
+
 // SaveStore saves the departures store contents
 func (store *DepartureStore) SaveStore() error {
 	store.RLock()
 
-	err := writeGob("departures.gob", store.departures)
+	err := writeGob(departuresFile, store.departures)
 
 	store.RUnlock()
 	return err
@@ -179,13 +229,18 @@ func (store *DepartureStore) hideDeparture(ID string) {
 	departure := store.departures[ID]
 	departure.Hidden = true
 	store.departures[ID] = departure
+	store.persist(bucketDepartures, ID, departure)
 	store.Unlock()
+
+	store.notifyChange(Change{ChangeHidden, ID, departure.Station.Code, departure, nil})
 }
 
 // deleteDeparture deletes a departure
 func (store *DepartureStore) deleteDeparture(departure models.Departure) {
 	store.Lock()
 	delete(store.departures, departure.ID)
+	store.deleteHistory(departure.ID)
+	store.unpersist(bucketDepartures, departure.ID)
 
 	_, stationExists := store.stations[departure.Station.Code]
 
@@ -197,18 +252,13 @@ func (store *DepartureStore) deleteDeparture(departure models.Departure) {
 	}
 
 	store.Unlock()
+
+	store.notifyChange(Change{ChangeRemoved, departure.ID, departure.Station.Code, departure, nil})
 }
 
 // CleanUp removes outdated items
 func (store *DepartureStore) CleanUp(currentTime time.Time) {
-	// Remove departures which should have departured 4 hours ago:
-	thresholdRemove := currentTime.Add(-4 * time.Hour)
-
-	// Hide departures which should have departed 10 minutes ago:
-	thresholdHide := currentTime.Add(-10 * time.Minute)
-
-	// Hide departures which should have departed 1 minute ago if they are not realtime:
-	thresholdHideNonRealtime := currentTime.Add(-1 * time.Minute)
+	retention := store.retentionPolicies()
 
 	log.Debug().Msg("Cleaning up departure store")
 
@@ -218,6 +268,17 @@ func (store *DepartureStore) CleanUp(currentTime time.Time) {
 	for departureID, departure := range store.departures {
 		store.RUnlock()
 
+		policy := retention.Policy(departure.Station.Code, departure.ServiceTypeCode)
+
+		// Remove departures which should have departed some time ago (default 4 hours):
+		thresholdRemove := currentTime.Add(-policy.Remove)
+
+		// Hide departures which should have departed recently (default 10 minutes ago):
+		thresholdHide := currentTime.Add(-policy.Hide)
+
+		// Hide departures which are not realtime a bit earlier (default 1 minute after departure):
+		thresholdHideNonRealtime := currentTime.Add(-policy.HideNonRealtime)
+
 		if !departure.Hidden && departure.RealDepartureTime().Before(thresholdHide) {
 			log.Debug().Str("DepartureID", departureID).Msg("Hiding departure")
 
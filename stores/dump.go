@@ -0,0 +1,170 @@
+package stores
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// Saved store files in the data directory
+const (
+	departuresFile = "departures.gob"
+	arrivalsFile   = "arrivals.gob"
+	servicesFile   = "services.gob"
+	stationsFile   = "stations.gob"
+)
+
+// Dump record types
+const (
+	DumpDeparture = "departure"
+	DumpArrival   = "arrival"
+	DumpService   = "service"
+)
+
+// DumpRecord is a single departure, arrival or service in a dump of the saved stores.
+// The store item fields are included separately, since they are not part of the JSON encoding
+// of the models.
+type DumpRecord struct {
+	Type      string    `json:"type"`
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	ProductID string    `json:"product_id"`
+
+	Departure *models.Departure `json:"departure,omitempty"`
+	Arrival   *models.Arrival   `json:"arrival,omitempty"`
+	Service   *models.Service   `json:"service,omitempty"`
+}
+
+// StoreFiles contains the contents of the saved store files. Maps are nil when a file does not exist.
+type StoreFiles struct {
+	Departures map[string]models.Departure
+	Arrivals   map[string]models.Arrival
+	Services   map[string]models.Service
+}
+
+// ReadStoreFiles reads the saved departures, arrivals and services from the data directory
+func ReadStoreFiles() (StoreFiles, error) {
+	var files StoreFiles
+
+	if err := readStoreFile(departuresFile, &files.Departures); err != nil {
+		return files, err
+	}
+	if err := readStoreFile(arrivalsFile, &files.Arrivals); err != nil {
+		return files, err
+	}
+	if err := readStoreFile(servicesFile, &files.Services); err != nil {
+		return files, err
+	}
+
+	return files, nil
+}
+
+func readStoreFile(filePath string, object interface{}) error {
+	err := readGob(filePath, object)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// WriteStoreFiles writes the saved store files to the data directory. Only the files with
+// contents (non-nil maps) are written.
+func WriteStoreFiles(files StoreFiles) error {
+	if files.Departures != nil {
+		if err := writeGob(departuresFile, files.Departures); err != nil {
+			return err
+		}
+	}
+	if files.Arrivals != nil {
+		if err := writeGob(arrivalsFile, files.Arrivals); err != nil {
+			return err
+		}
+	}
+	if files.Services != nil {
+		if err := writeGob(servicesFile, files.Services); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadSnapshotHeader reads the header of a saved store file, and verifies its checksum
+func ReadSnapshotHeader(filePath string) (SnapshotHeader, error) {
+	header, _, err := readSnapshot(getDataDirectory() + filePath)
+
+	return header, err
+}
+
+// StoreFileNames returns the names of the saved departure, arrival and service files
+func StoreFileNames() []string {
+	return []string{departuresFile, arrivalsFile, servicesFile}
+}
+
+// Records returns all items as dump records, sorted by type and ID
+func (files StoreFiles) Records() []DumpRecord {
+	var departures, arrivals, services []DumpRecord
+
+	for _, departure := range files.Departures {
+		departure := departure
+		departures = append(departures, DumpRecord{DumpDeparture, departure.ID, departure.Timestamp, departure.ProductID, &departure, nil, nil})
+	}
+	for _, arrival := range files.Arrivals {
+		arrival := arrival
+		arrivals = append(arrivals, DumpRecord{DumpArrival, arrival.ID, arrival.Timestamp, arrival.ProductID, nil, &arrival, nil})
+	}
+	for _, service := range files.Services {
+		service := service
+		services = append(services, DumpRecord{DumpService, service.ID, service.Timestamp, service.ProductID, nil, nil, &service})
+	}
+
+	var records []DumpRecord
+
+	for _, typeRecords := range [][]DumpRecord{departures, arrivals, services} {
+		sort.Slice(typeRecords, func(i, j int) bool {
+			return typeRecords[i].ID < typeRecords[j].ID
+		})
+
+		records = append(records, typeRecords...)
+	}
+
+	return records
+}
+
+// Add adds the item of a dump record, restoring its store item fields
+func (files *StoreFiles) Add(record DumpRecord) error {
+	item := models.StoreItem{ID: record.ID, Timestamp: record.Timestamp, ProductID: record.ProductID}
+
+	switch {
+	case record.Type == DumpDeparture && record.Departure != nil:
+		if files.Departures == nil {
+			files.Departures = make(map[string]models.Departure)
+		}
+
+		record.Departure.StoreItem = item
+		files.Departures[item.ID] = *record.Departure
+	case record.Type == DumpArrival && record.Arrival != nil:
+		if files.Arrivals == nil {
+			files.Arrivals = make(map[string]models.Arrival)
+		}
+
+		record.Arrival.StoreItem = item
+		files.Arrivals[item.ID] = *record.Arrival
+	case record.Type == DumpService && record.Service != nil:
+		if files.Services == nil {
+			files.Services = make(map[string]models.Service)
+		}
+
+		record.Service.StoreItem = item
+		files.Services[item.ID] = *record.Service
+	default:
+		return fmt.Errorf("invalid record %q of type %q", record.ID, record.Type)
+	}
+
+	return nil
+}
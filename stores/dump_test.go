@@ -0,0 +1,68 @@
+package stores
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func TestDumpRoundTrip(t *testing.T) {
+	useTemporaryDataDirectory(t)
+
+	departure := generateDeparture()
+	service := generateService()
+
+	files := StoreFiles{
+		Departures: map[string]models.Departure{departure.ID: departure},
+		Services:   map[string]models.Service{service.ID: service},
+	}
+
+	var imported StoreFiles
+
+	for _, record := range files.Records() {
+		data, err := json.Marshal(record)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded DumpRecord
+
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := imported.Add(decoded); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := WriteStoreFiles(imported); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadStoreFiles()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read.Arrivals != nil {
+		t.Error("Arrivals file should not be written")
+	}
+
+	readDeparture := read.Departures[departure.ID]
+
+	if readDeparture.ID != departure.ID || !readDeparture.Timestamp.Equal(departure.Timestamp) || readDeparture.ProductID != departure.ProductID {
+		t.Errorf("Store item fields not restored: %+v", readDeparture.StoreItem)
+	}
+
+	if len(read.Services[service.ID].ServiceParts[0].Stops) != 2 {
+		t.Error("Wrong service after import")
+	}
+
+	if err := imported.Add(DumpRecord{Type: DumpArrival, ID: "x"}); err == nil {
+		t.Error("Record without item should be rejected")
+	}
+}
@@ -0,0 +1,190 @@
+package stores
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// maxHistoryEntries is the maximum number of history entries kept per item
+const maxHistoryEntries = 25
+
+// Fields which are tracked in the history
+const (
+	FieldPlatform    = "platform"
+	FieldDelay       = "delay"
+	FieldDestination = "destination"
+	FieldOrigin      = "origin"
+	FieldCancelled   = "cancelled"
+	FieldMaterial    = "material"
+
+	FieldArrivalPlatform    = "arrival_platform"
+	FieldArrivalDelay       = "arrival_delay"
+	FieldArrivalCancelled   = "arrival_cancelled"
+	FieldDeparturePlatform  = "departure_platform"
+	FieldDepartureDelay     = "departure_delay"
+	FieldDepartureCancelled = "departure_cancelled"
+)
+
+// FieldChange is a change of a single field of an item. For services, Station is the
+// station of the stop which has changed.
+type FieldChange struct {
+	Field   string
+	Station string
+	Old     string
+	New     string
+}
+
+// HistoryEntry contains all field changes caused by a single message. The first entry of an
+// item contains the initial values of all fields which are set.
+type HistoryEntry struct {
+	Timestamp time.Time
+	ProductID string
+	Changes   []FieldChange
+}
+
+// GetHistory returns the history of an item, oldest entry first
+func (store *Store) GetHistory(ID string) []HistoryEntry {
+	store.RLock()
+	defer store.RUnlock()
+
+	return append([]HistoryEntry(nil), store.history[ID]...)
+}
+
+// recordHistory adds an entry to the history of an item when it has changes (or when it is the
+// first entry of the item). The caller must hold the store lock.
+func (store *Store) recordHistory(ID string, item models.StoreItem, changes []FieldChange) {
+	entries := store.history[ID]
+
+	if len(entries) > 0 && len(changes) == 0 {
+		return
+	}
+
+	entries = append(entries, HistoryEntry{item.Timestamp, item.ProductID, changes})
+
+	if len(entries) > maxHistoryEntries {
+		entries = entries[len(entries)-maxHistoryEntries:]
+	}
+
+	store.history[ID] = entries
+}
+
+// deleteHistory removes the history of an item. The caller must hold the store lock.
+func (store *Store) deleteHistory(ID string) {
+	delete(store.history, ID)
+}
+
+// fieldChanges collects the changes between the old and new values of fields
+type fieldChanges []FieldChange
+
+func (changes *fieldChanges) compare(field, station, previous, current string) {
+	if previous != current {
+		*changes = append(*changes, FieldChange{field, station, previous, current})
+	}
+}
+
+// departureChanges returns the field changes between two versions of a departure
+func departureChanges(previous, current models.Departure) []FieldChange {
+	var changes fieldChanges
+
+	changes.compare(FieldPlatform, "", previous.PlatformActual, current.PlatformActual)
+	changes.compare(FieldDelay, "", delayValue(previous.Delay), delayValue(current.Delay))
+	changes.compare(FieldDestination, "", stationCodesValue(previous.DestinationActual), stationCodesValue(current.DestinationActual))
+	changes.compare(FieldCancelled, "", boolValue(previous.Cancelled), boolValue(current.Cancelled))
+	changes.compare(FieldMaterial, "", wingsMaterialValue(previous.TrainWings), wingsMaterialValue(current.TrainWings))
+
+	return changes
+}
+
+// arrivalChanges returns the field changes between two versions of an arrival
+func arrivalChanges(previous, current models.Arrival) []FieldChange {
+	var changes fieldChanges
+
+	changes.compare(FieldPlatform, "", previous.PlatformActual, current.PlatformActual)
+	changes.compare(FieldDelay, "", delayValue(previous.Delay), delayValue(current.Delay))
+	changes.compare(FieldOrigin, "", stationCodesValue(previous.OriginActual), stationCodesValue(current.OriginActual))
+	changes.compare(FieldCancelled, "", boolValue(previous.Cancelled), boolValue(current.Cancelled))
+
+	return changes
+}
+
+// serviceChanges returns the field changes of all stops between two versions of a service
+func serviceChanges(previous, current models.Service) []FieldChange {
+	var changes fieldChanges
+
+	// Parts of a combined service share stations, so stops are identified by part and station:
+	type stopKey struct {
+		part    int
+		station string
+	}
+
+	previousStops := make(map[stopKey]models.ServiceStop)
+
+	for partIndex, part := range previous.ServiceParts {
+		for _, stop := range part.Stops {
+			previousStops[stopKey{partIndex, stop.Station.Code}] = stop
+		}
+	}
+
+	for partIndex, part := range current.ServiceParts {
+		for _, currentStop := range part.Stops {
+			station := currentStop.Station.Code
+			previousStop := previousStops[stopKey{partIndex, station}]
+
+			changes.compare(FieldArrivalPlatform, station, previousStop.ArrivalPlatformActual, currentStop.ArrivalPlatformActual)
+			changes.compare(FieldArrivalDelay, station, delayValue(previousStop.ArrivalDelay), delayValue(currentStop.ArrivalDelay))
+			changes.compare(FieldArrivalCancelled, station, boolValue(previousStop.ArrivalCancelled), boolValue(currentStop.ArrivalCancelled))
+			changes.compare(FieldDeparturePlatform, station, previousStop.DeparturePlatformActual, currentStop.DeparturePlatformActual)
+			changes.compare(FieldDepartureDelay, station, delayValue(previousStop.DepartureDelay), delayValue(currentStop.DepartureDelay))
+			changes.compare(FieldDepartureCancelled, station, boolValue(previousStop.DepartureCancelled), boolValue(currentStop.DepartureCancelled))
+			changes.compare(FieldDestination, station, previousStop.DestinationActual, currentStop.DestinationActual)
+			changes.compare(FieldMaterial, station, materialValue(previousStop.Material), materialValue(currentStop.Material))
+		}
+	}
+
+	return changes
+}
+
+func delayValue(delay int) string {
+	return strconv.Itoa(delay)
+}
+
+func boolValue(value bool) string {
+	return strconv.FormatBool(value)
+}
+
+func stationCodesValue(stations []models.Station) string {
+	codes := make([]string, 0, len(stations))
+
+	for _, station := range stations {
+		codes = append(codes, station.Code)
+	}
+
+	return strings.Join(codes, ",")
+}
+
+// materialValue returns the composition as comma separated list of material numbers
+func materialValue(material []models.Material) string {
+	numbers := make([]string, 0, len(material))
+
+	for _, unit := range material {
+		if number := unit.NormalizedNumber(); number != nil && !unit.AlreadyRemoved {
+			numbers = append(numbers, *number)
+		}
+	}
+
+	return strings.Join(numbers, ",")
+}
+
+// wingsMaterialValue returns the composition of all wings, separated by slashes
+func wingsMaterialValue(wings []models.TrainWing) string {
+	compositions := make([]string, 0, len(wings))
+
+	for _, wing := range wings {
+		compositions = append(compositions, materialValue(wing.Material))
+	}
+
+	return strings.Trim(strings.Join(compositions, "/"), "/")
+}
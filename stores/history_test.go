@@ -0,0 +1,97 @@
+package stores
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func TestDepartureHistory(t *testing.T) {
+	var store DepartureStore
+	store.InitStore()
+
+	departure := generateDeparture()
+	departure.PlatformActual = "5"
+	store.ProcessDeparture(departure)
+
+	// Unchanged fields should not add an entry:
+	departure.ProductID = "12346"
+	departure.Timestamp = departure.Timestamp.Add(time.Minute)
+	store.ProcessDeparture(departure)
+
+	departure.ProductID = "12347"
+	departure.Timestamp = departure.Timestamp.Add(time.Minute)
+	departure.PlatformActual = "7"
+	departure.Delay = 180
+	departure.TrainWings = []models.TrainWing{{Material: []models.Material{{Number: "000000-09547-0"}}}}
+	store.ProcessDeparture(departure)
+
+	history := store.GetHistory(departure.ID)
+
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(history))
+	}
+
+	if len(history[0].Changes) != 1 || history[0].Changes[0] != (FieldChange{FieldPlatform, "", "", "5"}) {
+		t.Errorf("Wrong initial entry: %+v", history[0])
+	}
+
+	expected := []FieldChange{
+		{FieldPlatform, "", "5", "7"},
+		{FieldDelay, "", "0", "180"},
+		{FieldMaterial, "", "", "9547"},
+	}
+
+	if history[1].ProductID != "12347" || fmt.Sprint(history[1].Changes) != fmt.Sprint(expected) {
+		t.Errorf("Wrong changes: %+v", history[1])
+	}
+
+	store.deleteDeparture(departure)
+
+	if len(store.GetHistory(departure.ID)) != 0 {
+		t.Error("History should be removed with the departure")
+	}
+}
+
+func TestServiceHistoryLimit(t *testing.T) {
+	var store ServiceStore
+	store.InitStore()
+
+	var service models.Service
+
+	for delay := 0; delay < maxHistoryEntries+10; delay++ {
+		service = generateService()
+		service.Timestamp = service.Timestamp.Add(time.Duration(delay) * time.Minute)
+		service.ServiceParts[0].Stops[1].ArrivalDelay = delay * 60
+		store.ProcessService(service)
+	}
+
+	history := store.GetHistory(service.ID)
+
+	if len(history) != maxHistoryEntries {
+		t.Fatalf("Expected %d history entries, got %d", maxHistoryEntries, len(history))
+	}
+
+	last := history[len(history)-1]
+
+	if len(last.Changes) != 1 || last.Changes[0] != (FieldChange{FieldArrivalDelay, "GVC", "1980", "2040"}) {
+		t.Errorf("Wrong changes: %+v", last.Changes)
+	}
+}
+
+func TestServiceHistoryCombinedParts(t *testing.T) {
+	service := generateService()
+
+	// Second part with different platforms at the same stations:
+	part := models.ServicePart{ServiceNumber: "11234"}
+	part.Stops = append(part.Stops, service.ServiceParts[0].Stops...)
+	part.Stops[0].DeparturePlatformActual = "5a"
+	part.Stops[1].ArrivalPlatformActual = "12"
+	service.ServiceParts = append(service.ServiceParts, part)
+
+	if changes := serviceChanges(service, service); len(changes) != 0 {
+		t.Errorf("Unchanged combined service should have no changes: %+v", changes)
+	}
+}
@@ -0,0 +1,145 @@
+package stores
+
+import "sync"
+
+// Topic kinds
+const (
+	TopicDepartures = "departures"
+	TopicArrivals   = "arrivals"
+	TopicServices   = "services"
+)
+
+// Topic identifies a set of items clients can subscribe to: all departures or arrivals
+// for a station (Key is the station code), or a single service (Key is the service ID)
+type Topic struct {
+	Kind string
+	Key  string
+}
+
+// TopicChange is a change which is delivered to the subscribers of a topic
+type TopicChange struct {
+	Topic  Topic
+	Change Change
+}
+
+// Hub distributes the changes of the departure, arrival and service stores to
+// clients, based on the topics they are subscribed to
+type Hub struct {
+	mutex         sync.RWMutex
+	subscriptions map[Topic]map[*HubClient]struct{}
+
+	collection  *StoreCollection
+	listenerIDs [3]int
+}
+
+// HubClient receives the changes for all topics it is subscribed to. When the client does not
+// keep up with the changes, it is closed and Done is signalled.
+type HubClient struct {
+	hub     *Hub
+	changes chan TopicChange
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewHub creates a hub for the given store collection
+func NewHub(collection *StoreCollection) *Hub {
+	return &Hub{
+		subscriptions: make(map[Topic]map[*HubClient]struct{}),
+		collection:    collection,
+	}
+}
+
+// Start registers the hub as listener of the stores
+func (hub *Hub) Start() {
+	hub.listenerIDs[0] = hub.collection.DepartureStore.AddListener(func(change Change) {
+		hub.publish(Topic{TopicDepartures, change.Station}, change)
+	})
+	hub.listenerIDs[1] = hub.collection.ArrivalStore.AddListener(func(change Change) {
+		hub.publish(Topic{TopicArrivals, change.Station}, change)
+	})
+	hub.listenerIDs[2] = hub.collection.ServiceStore.AddListener(func(change Change) {
+		hub.publish(Topic{TopicServices, change.ID}, change)
+	})
+}
+
+// Stop removes the hub listeners from the stores
+func (hub *Hub) Stop() {
+	hub.collection.DepartureStore.RemoveListener(hub.listenerIDs[0])
+	hub.collection.ArrivalStore.RemoveListener(hub.listenerIDs[1])
+	hub.collection.ServiceStore.RemoveListener(hub.listenerIDs[2])
+}
+
+// NewClient creates a client which can queue up to bufferSize changes
+func (hub *Hub) NewClient(bufferSize int) *HubClient {
+	return &HubClient{
+		hub:     hub,
+		changes: make(chan TopicChange, bufferSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// publish delivers a change to all subscribers of a topic
+func (hub *Hub) publish(topic Topic, change Change) {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+
+	for client := range hub.subscriptions[topic] {
+		select {
+		case client.changes <- TopicChange{topic, change}:
+		default:
+			client.once.Do(func() { close(client.done) })
+		}
+	}
+}
+
+// Subscribe subscribes the client to a topic
+func (client *HubClient) Subscribe(topic Topic) {
+	client.hub.mutex.Lock()
+	defer client.hub.mutex.Unlock()
+
+	if client.hub.subscriptions[topic] == nil {
+		client.hub.subscriptions[topic] = make(map[*HubClient]struct{})
+	}
+
+	client.hub.subscriptions[topic][client] = struct{}{}
+}
+
+// Unsubscribe unsubscribes the client from a topic
+func (client *HubClient) Unsubscribe(topic Topic) {
+	client.hub.mutex.Lock()
+	defer client.hub.mutex.Unlock()
+
+	client.unsubscribe(topic)
+}
+
+func (client *HubClient) unsubscribe(topic Topic) {
+	delete(client.hub.subscriptions[topic], client)
+
+	if len(client.hub.subscriptions[topic]) == 0 {
+		delete(client.hub.subscriptions, topic)
+	}
+}
+
+// Changes returns the channel on which changes are delivered
+func (client *HubClient) Changes() <-chan TopicChange {
+	return client.changes
+}
+
+// Done is closed when the client has been closed, or could not keep up with the changes
+func (client *HubClient) Done() <-chan struct{} {
+	return client.done
+}
+
+// Close unsubscribes the client from all topics
+func (client *HubClient) Close() {
+	client.hub.mutex.Lock()
+	defer client.hub.mutex.Unlock()
+
+	for topic, clients := range client.hub.subscriptions {
+		if _, exists := clients[client]; exists {
+			client.unsubscribe(topic)
+		}
+	}
+
+	client.once.Do(func() { close(client.done) })
+}
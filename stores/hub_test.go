@@ -0,0 +1,73 @@
+package stores
+
+import (
+	"testing"
+)
+
+func TestHubSubscriptions(t *testing.T) {
+	collection := &StoreCollection{}
+	collection.DepartureStore.InitStore()
+	collection.ArrivalStore.InitStore()
+	collection.ServiceStore.InitStore()
+
+	hub := NewHub(collection)
+	hub.Start()
+	defer hub.Stop()
+
+	client := hub.NewClient(10)
+	defer client.Close()
+
+	client.Subscribe(Topic{TopicDepartures, "UT"})
+
+	departure := generateDeparture()
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	other := generateDeparture()
+	other.Station.Code = "ASD"
+	other.GenerateID()
+	collection.DepartureStore.ProcessDeparture(other)
+
+	if len(client.Changes()) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(client.Changes()))
+	}
+
+	change := <-client.Changes()
+
+	if change.Topic.Key != "UT" || change.Change.ID != departure.ID {
+		t.Errorf("Wrong change delivered: %+v", change)
+	}
+
+	client.Unsubscribe(Topic{TopicDepartures, "UT"})
+	departure.Timestamp = departure.Timestamp.Add(1)
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	if len(client.Changes()) != 0 {
+		t.Error("Unsubscribed client should not receive changes")
+	}
+}
+
+func TestHubSlowClient(t *testing.T) {
+	collection := &StoreCollection{}
+	collection.DepartureStore.InitStore()
+
+	hub := NewHub(collection)
+	hub.Start()
+	defer hub.Stop()
+
+	client := hub.NewClient(1)
+	client.Subscribe(Topic{TopicDepartures, "UT"})
+
+	departure := generateDeparture()
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	departure.Timestamp = departure.Timestamp.Add(1)
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	select {
+	case <-client.Done():
+	default:
+		t.Error("Slow client should be marked as done")
+	}
+
+	client.Close()
+}
@@ -0,0 +1,362 @@
+package stores
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// materialHistoryDays is the number of days (before today) for which material usage is kept
+const materialHistoryDays = 2
+
+// materialCleanUpInterval is the interval at which outdated material usage is removed
+const materialCleanUpInterval = 10 * time.Minute
+
+// MaterialService is a (part of a) service which is run by a material unit
+type MaterialService struct {
+	ServiceID     string // Service ID (service date and service number)
+	ServiceDate   string
+	ServiceNumber string
+	ServiceType   string
+	Company       string
+	MaterialType  string
+
+	From          models.Station
+	To            models.Station
+	DepartureTime time.Time
+	ArrivalTime   time.Time // Zero when unknown
+}
+
+// MaterialRotation is the sequence of services run by a material unit on a single service date
+type MaterialRotation struct {
+	ServiceDate string
+	Services    []MaterialService
+}
+
+// Materials is the material index of the stores. It is nil when the index has not been started.
+var Materials *MaterialIndex
+
+// MaterialIndex keeps track of the services and departures on which material units are used.
+// Usage is kept after services and departures have been removed, so the rotation of a unit
+// remains available for a few days.
+type MaterialIndex struct {
+	mutex sync.RWMutex
+
+	// Material usage per unit number, by service ID (from services) or departure ID (from departures):
+	services   map[string]map[string]MaterialService
+	departures map[string]map[string]MaterialService
+
+	// Unit numbers per service ID or departure ID:
+	serviceUnits   map[string][]string
+	departureUnits map[string][]string
+
+	collection  *StoreCollection
+	listenerIDs [2]int
+	ticker      *time.Ticker
+	stop        chan struct{}
+}
+
+// NewMaterialIndex creates a material index for the given store collection
+func NewMaterialIndex(collection *StoreCollection) *MaterialIndex {
+	return &MaterialIndex{
+		services:       make(map[string]map[string]MaterialService),
+		departures:     make(map[string]map[string]MaterialService),
+		serviceUnits:   make(map[string][]string),
+		departureUnits: make(map[string][]string),
+		collection:     collection,
+	}
+}
+
+// Start registers the index as listener of the stores, indexes the current store contents
+// and periodically removes outdated material usage
+func (index *MaterialIndex) Start() {
+	index.listenerIDs[0] = index.collection.DepartureStore.AddListener(func(change Change) {
+		if change.Action == ChangeUpdated {
+			index.updateDeparture(change.Item.(models.Departure))
+		}
+	})
+	index.listenerIDs[1] = index.collection.ServiceStore.AddListener(func(change Change) {
+		if change.Action == ChangeUpdated {
+			index.updateService(change.Item.(models.Service))
+		}
+	})
+
+	for _, departure := range index.collection.DepartureStore.GetDepartures(true) {
+		index.updateDeparture(departure)
+	}
+
+	for _, service := range index.collection.ServiceStore.GetServices(true) {
+		index.updateService(service)
+	}
+
+	index.ticker = time.NewTicker(materialCleanUpInterval)
+	index.stop = make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case currentTime := <-index.ticker.C:
+				index.CleanUp(currentTime)
+			case <-index.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop removes the index listeners from the stores
+func (index *MaterialIndex) Stop() {
+	index.collection.DepartureStore.RemoveListener(index.listenerIDs[0])
+	index.collection.ServiceStore.RemoveListener(index.listenerIDs[1])
+
+	index.ticker.Stop()
+	close(index.stop)
+}
+
+// updateService replaces the material usage of a service
+func (index *MaterialIndex) updateService(service models.Service) {
+	usages := serviceMaterialUsage(service)
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	replaceMaterialUsage(index.services, index.serviceUnits, service.ID, usages)
+}
+
+// updateDeparture replaces the material usage of a departure
+func (index *MaterialIndex) updateDeparture(departure models.Departure) {
+	usages := departureMaterialUsage(departure)
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	replaceMaterialUsage(index.departures, index.departureUnits, departure.ID, usages)
+}
+
+func replaceMaterialUsage(usage map[string]map[string]MaterialService, units map[string][]string, ID string, usages map[string]MaterialService) {
+	for _, number := range units[ID] {
+		delete(usage[number], ID)
+
+		if len(usage[number]) == 0 {
+			delete(usage, number)
+		}
+	}
+
+	delete(units, ID)
+
+	for number, materialService := range usages {
+		if usage[number] == nil {
+			usage[number] = make(map[string]MaterialService)
+		}
+
+		usage[number][ID] = materialService
+		units[ID] = append(units[ID], number)
+	}
+}
+
+// serviceMaterialUsage determines the usage of all material units of a service. A unit runs from
+// the first stop where it is listed, to the next stop where the service calls after the last stop
+// where it is listed (unless it remains behind at that stop).
+func serviceMaterialUsage(service models.Service) map[string]MaterialService {
+	usages := make(map[string]MaterialService)
+
+	for _, part := range service.ServiceParts {
+		serviceNumber := part.ServiceNumber
+		if serviceNumber == "" {
+			serviceNumber = service.ServiceNumber
+		}
+
+		for stopIndex, stop := range part.Stops {
+			for _, material := range stop.Material {
+				number := material.NormalizedNumber()
+
+				if number == nil || material.AlreadyRemoved {
+					continue
+				}
+
+				usage, exists := usages[*number]
+
+				if !exists {
+					usage = MaterialService{
+						ServiceID:     service.ID,
+						ServiceDate:   service.ServiceDate,
+						ServiceNumber: serviceNumber,
+						ServiceType:   service.ServiceType,
+						Company:       service.Company,
+						MaterialType:  material.NaterialType,
+						From:          stop.Station,
+						DepartureTime: stop.DepartureTime,
+					}
+				}
+
+				usage.To = stop.Station
+				usage.ArrivalTime = stop.ArrivalTime
+
+				if !material.RemainsBehind {
+					if next := nextStoppingStop(part.Stops, stopIndex); next != nil {
+						usage.To = next.Station
+						usage.ArrivalTime = next.ArrivalTime
+					}
+				}
+
+				usages[*number] = usage
+			}
+		}
+	}
+
+	return usages
+}
+
+// nextStoppingStop returns the first stop after the given stop where the service calls,
+// skipping the stations it passes through
+func nextStoppingStop(stops []models.ServiceStop, stopIndex int) *models.ServiceStop {
+	for index := stopIndex + 1; index < len(stops); index++ {
+		if stops[index].IsStopping() {
+			return &stops[index]
+		}
+	}
+
+	return nil
+}
+
+// departureMaterialUsage determines the usage of all material units of a departure
+func departureMaterialUsage(departure models.Departure) map[string]MaterialService {
+	usages := make(map[string]MaterialService)
+
+	for _, wing := range departure.TrainWings {
+		for _, material := range wing.Material {
+			number := material.NormalizedNumber()
+
+			if number == nil || material.AlreadyRemoved {
+				continue
+			}
+
+			usage := MaterialService{
+				ServiceID:     departure.ServiceDate + "-" + departure.ServiceNumber,
+				ServiceDate:   departure.ServiceDate,
+				ServiceNumber: departure.ServiceNumber,
+				ServiceType:   departure.ServiceType,
+				Company:       departure.Company,
+				MaterialType:  material.NaterialType,
+				From:          departure.Station,
+				To:            material.DestinationActual,
+				DepartureTime: departure.DepartureTime,
+			}
+
+			if usage.To.Code == "" && len(wing.DestinationActual) > 0 {
+				usage.To = wing.DestinationActual[0]
+			}
+
+			usages[*number] = usage
+		}
+	}
+
+	return usages
+}
+
+// GetMaterialServices returns all known services of a material unit, ordered on departure time.
+// Usage from services takes precedence over usage from departures; when a service is only known
+// from departures, the first departure of the unit is used.
+func (index *MaterialIndex) GetMaterialServices(number string) []MaterialService {
+	materialServices := make(map[string]MaterialService)
+
+	index.mutex.RLock()
+	for _, usage := range index.departures[number] {
+		existing, exists := materialServices[usage.ServiceID]
+
+		if !exists || usage.DepartureTime.Before(existing.DepartureTime) {
+			materialServices[usage.ServiceID] = usage
+		}
+	}
+
+	for _, usage := range index.services[number] {
+		materialServices[usage.ServiceID] = usage
+	}
+	index.mutex.RUnlock()
+
+	var services []MaterialService
+
+	for _, usage := range materialServices {
+		services = append(services, usage)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].DepartureTime.Before(services[j].DepartureTime)
+	})
+
+	return services
+}
+
+// GetCurrentAndNext returns the service a material unit is currently running (the last service
+// which has departed and has not yet arrived) and the first service after currentTime.
+// Both may be nil.
+func (index *MaterialIndex) GetCurrentAndNext(number string, currentTime time.Time) (current *MaterialService, next *MaterialService) {
+	for _, service := range index.GetMaterialServices(number) {
+		service := service
+
+		if service.DepartureTime.After(currentTime) {
+			next = &service
+			break
+		}
+
+		if service.ArrivalTime.IsZero() || !service.ArrivalTime.Before(currentTime) {
+			current = &service
+		} else {
+			current = nil
+		}
+	}
+
+	return current, next
+}
+
+// GetRotation returns the services of a material unit, grouped per service date
+func (index *MaterialIndex) GetRotation(number string) []MaterialRotation {
+	var rotation []MaterialRotation
+	days := make(map[string]int)
+
+	for _, service := range index.GetMaterialServices(number) {
+		day, exists := days[service.ServiceDate]
+
+		if !exists {
+			day = len(rotation)
+			days[service.ServiceDate] = day
+			rotation = append(rotation, MaterialRotation{ServiceDate: service.ServiceDate})
+		}
+
+		rotation[day].Services = append(rotation[day].Services, service)
+	}
+
+	sort.Slice(rotation, func(i, j int) bool {
+		return rotation[i].ServiceDate < rotation[j].ServiceDate
+	})
+
+	return rotation
+}
+
+// CleanUp removes the usage of services with a service date before the history period
+func (index *MaterialIndex) CleanUp(currentTime time.Time) {
+	firstDate := currentTime.AddDate(0, 0, -materialHistoryDays).Format("2006-01-02")
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	cleanUpMaterialUsage(index.services, index.serviceUnits, firstDate)
+	cleanUpMaterialUsage(index.departures, index.departureUnits, firstDate)
+}
+
+func cleanUpMaterialUsage(usage map[string]map[string]MaterialService, units map[string][]string, firstDate string) {
+	for number, usages := range usage {
+		for ID, materialService := range usages {
+			if materialService.ServiceDate < firstDate {
+				delete(usages, ID)
+				delete(units, ID)
+			}
+		}
+
+		if len(usages) == 0 {
+			delete(usage, number)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package stores
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func TestMaterialIndex(t *testing.T) {
+	collection := &StoreCollection{}
+	collection.DepartureStore.InitStore()
+	collection.ServiceStore.InitStore()
+
+	// Service 1234 UT-GVC, passing through GD, known before the index starts:
+	service := generateService()
+	stops := service.ServiceParts[0].Stops
+	stops[0].StoppingActual = true
+	stops[1].StoppingActual = true
+	service.ServiceParts[0].Stops = []models.ServiceStop{stops[0], {Station: models.Station{Code: "GD"}}, stops[1]}
+	service.ServiceParts[0].Stops[0].Material = []models.Material{{Number: "000000-09547-0"}, {Number: "000000-02412-0"}}
+	collection.ServiceStore.ProcessService(service)
+
+	index := NewMaterialIndex(collection)
+	index.Start()
+	defer index.Stop()
+
+	// Departure of service 5678 from GVC, only known from the departure:
+	departure := generateDeparture()
+	departure.ServiceID = "5678"
+	departure.ServiceNumber = "5678"
+	departure.Station.Code = "GVC"
+	departure.GenerateID()
+	departure.DepartureTime = time.Date(2019, time.January, 27, 14, 4, 0, 0, time.UTC)
+	departure.TrainWings = []models.TrainWing{{
+		DestinationActual: []models.Station{{Code: "ASD"}},
+		Material:          []models.Material{{Number: "000000-09547-0"}},
+	}}
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	services := index.GetMaterialServices("9547")
+
+	if len(services) != 2 {
+		t.Fatalf("Expected 2 services, got %d", len(services))
+	}
+
+	if services[0].ServiceNumber != "1234" || services[0].From.Code != "UT" || services[0].To.Code != "GVC" || services[0].ArrivalTime.IsZero() {
+		t.Errorf("Wrong usage from service: %+v", services[0])
+	}
+
+	if services[1].ServiceNumber != "5678" || services[1].From.Code != "GVC" || services[1].To.Code != "ASD" {
+		t.Errorf("Wrong usage from departure: %+v", services[1])
+	}
+
+	current, next := index.GetCurrentAndNext("9547", time.Date(2019, time.January, 27, 13, 0, 0, 0, time.UTC))
+
+	if current == nil || current.ServiceNumber != "1234" {
+		t.Errorf("Expected current service 1234, got %+v", current)
+	}
+
+	if next == nil || next.ServiceNumber != "5678" {
+		t.Errorf("Expected next service 5678, got %+v", next)
+	}
+
+	current, next = index.GetCurrentAndNext("9547", time.Date(2019, time.January, 27, 13, 50, 0, 0, time.UTC))
+
+	if current != nil || next == nil {
+		t.Error("Unit should have no current service between services")
+	}
+
+	rotation := index.GetRotation("9547")
+
+	if len(rotation) != 1 || rotation[0].ServiceDate != "2019-01-27" || len(rotation[0].Services) != 2 {
+		t.Errorf("Wrong rotation: %+v", rotation)
+	}
+
+	// Unit is removed from the service:
+	service.Timestamp = service.Timestamp.Add(time.Minute)
+	service.ServiceParts[0].Stops[0].Material = []models.Material{{Number: "000000-02412-0"}}
+	collection.ServiceStore.ProcessService(service)
+
+	if len(index.GetMaterialServices("9547")) != 1 {
+		t.Error("Removed unit should no longer run service 1234")
+	}
+
+	// Removing a departure from the store keeps its usage:
+	collection.DepartureStore.deleteDeparture(departure)
+
+	if len(index.GetMaterialServices("9547")) != 1 {
+		t.Error("Usage should be kept when departure is removed")
+	}
+
+	index.CleanUp(time.Date(2019, time.January, 30, 12, 0, 0, 0, time.UTC))
+
+	if len(index.GetMaterialServices("9547")) != 0 || len(index.GetMaterialServices("2412")) != 0 {
+		t.Error("Outdated usage should be cleaned up")
+	}
+}
@@ -0,0 +1,306 @@
+package stores
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// RetentionPolicy contains the thresholds for hiding and removing items. The thresholds are relative
+// to the real departure or arrival time, or to the end of the validity of a service.
+type RetentionPolicy struct {
+	Hide            time.Duration // Hide items this long after departure or arrival
+	HideNonRealtime time.Duration // Hide non-realtime and cancelled departures this long after departure
+	Remove          time.Duration // Remove hidden items this long after departure or arrival
+}
+
+// RetentionPolicies contains the retention policy of a store, with overrides per station and per
+// service type. A station override takes precedence over a service type override.
+type RetentionPolicies struct {
+	Default      RetentionPolicy
+	Stations     map[string]RetentionPolicy
+	ServiceTypes map[string]RetentionPolicy
+}
+
+// DepartureRetention is the default retention policy of the departure store
+var DepartureRetention = RetentionPolicy{
+	Hide:            10 * time.Minute,
+	HideNonRealtime: 1 * time.Minute,
+	Remove:          4 * time.Hour,
+}
+
+// ArrivalRetention is the default retention policy of the arrival store
+var ArrivalRetention = RetentionPolicy{
+	Hide:   30 * time.Minute,
+	Remove: 4 * time.Hour,
+}
+
+// ServiceRetention is the default retention policy of the service store
+var ServiceRetention = RetentionPolicy{
+	Hide:   0,
+	Remove: 48 * time.Hour,
+}
+
+// DepartureDowntimeDetection is the default downtime detection configuration for the departure store
+var DepartureDowntimeDetection = DowntimeDetectionConfig{
+	MinAverage:      float64(1) / 60,  // One message per minute
+	MinAverageNight: float64(1) / 600, // One message per 10 minutes
+	NightStartHour:  2,                // Night starts at 02:00
+	NightEndHour:    5,                // Night ends at 05:00
+	RecoveryTime:    70,               // 70 mins recovery time
+}
+
+// ArrivalDowntimeDetection is the default downtime detection configuration for the arrival store
+var ArrivalDowntimeDetection = DowntimeDetectionConfig{
+	MinAverage:      float64(1) / 60,   // One message per minute
+	MinAverageNight: float64(1) / 1200, // One message per 20 minutes
+	NightStartHour:  2,                 // Night starts at 02:00
+	NightEndHour:    5,                 // Night ends at 05:00
+	RecoveryTime:    70,                // 70 mins recovery time
+}
+
+// ServiceDowntimeDetection is the downtime detection configuration for the service store.
+// Services are received in every mode, so it is also used to detect silent connections.
+var ServiceDowntimeDetection = DowntimeDetectionConfig{
+	MinAverage:      float64(1) / 60,   // One message per minute
+	MinAverageNight: float64(1) / 1800, // One message per 30 minutes
+	NightStartHour:  2,                 // Night starts at 02:00
+	NightEndHour:    5,                 // Night ends at 05:00
+	RecoveryTime:    1,                 // 1 minute recovery time
+}
+
+// Policy returns the retention policy for an item at the given station with the given service type
+func (policies RetentionPolicies) Policy(station, serviceType string) RetentionPolicy {
+	if policy, exists := policies.Stations[station]; exists {
+		return policy
+	}
+
+	if policy, exists := policies.ServiceTypes[serviceType]; exists {
+		return policy
+	}
+
+	return policies.Default
+}
+
+// SetPolicies replaces the retention policies and downtime detection configuration of a store
+func (store *Store) SetPolicies(retention RetentionPolicies, downtimeDetection DowntimeDetectionConfig) {
+	store.Lock()
+	store.Retention = retention
+	store.DowntimeDetection = downtimeDetection
+	store.Unlock()
+}
+
+// retentionPolicies returns the current retention policies of a store
+func (store *Store) retentionPolicies() RetentionPolicies {
+	store.RLock()
+	defer store.RUnlock()
+
+	return store.Retention
+}
+
+// GetDowntimeDetection returns the current downtime detection configuration of a store
+func (store *Store) GetDowntimeDetection() DowntimeDetectionConfig {
+	store.RLock()
+	defer store.RUnlock()
+
+	return store.DowntimeDetection
+}
+
+// Policies contains the retention policies and downtime detection configuration of a store
+type Policies struct {
+	Retention         RetentionPolicies
+	DowntimeDetection DowntimeDetectionConfig
+}
+
+// CollectionPolicies contains the policies of the departure, arrival and service stores
+type CollectionPolicies struct {
+	Departures Policies
+	Arrivals   Policies
+	Services   Policies
+}
+
+// ReadPolicies reads and validates the retention and downtime detection settings in the stores
+// section of a configuration. Settings which are not configured have their default value.
+func ReadPolicies(config *viper.Viper) (CollectionPolicies, error) {
+	var policies CollectionPolicies
+	var err error
+
+	if policies.Departures, err = readPolicies(config, "stores.departures", DepartureRetention, DepartureDowntimeDetection); err != nil {
+		return policies, err
+	}
+	if policies.Arrivals, err = readPolicies(config, "stores.arrivals", ArrivalRetention, ArrivalDowntimeDetection); err != nil {
+		return policies, err
+	}
+	if policies.Services, err = readPolicies(config, "stores.services", ServiceRetention, ServiceDowntimeDetection); err != nil {
+		return policies, err
+	}
+
+	return policies, nil
+}
+
+// ApplyPolicies sets the policies of the stores, logging the policies of every store which changed
+func ApplyPolicies(policies CollectionPolicies) {
+	Stores.DepartureStore.applyPolicies("departures", policies.Departures)
+	Stores.ArrivalStore.applyPolicies("arrivals", policies.Arrivals)
+	Stores.ServiceStore.applyPolicies("services", policies.Services)
+}
+
+func (store *Store) applyPolicies(name string, policies Policies) {
+	current := Policies{store.retentionPolicies(), store.GetDowntimeDetection()}
+
+	if reflect.DeepEqual(current, policies) {
+		return
+	}
+
+	store.SetPolicies(policies.Retention, policies.DowntimeDetection)
+
+	log.Info().Str("store", name).
+		Interface("retention", policies.Retention).
+		Interface("downtime", policies.DowntimeDetection).
+		Msg("Store policies changed")
+}
+
+// ConfigurePolicies applies the retention and downtime detection settings in the stores section of
+// the configuration. When a setting is invalid, an error is returned and no policies are changed.
+func ConfigurePolicies() error {
+	policies, err := ReadPolicies(viper.GetViper())
+
+	if err != nil {
+		return err
+	}
+
+	ApplyPolicies(policies)
+
+	return nil
+}
+
+// readPolicies reads the retention policies and downtime detection configuration of a store
+func readPolicies(config *viper.Viper, key string, retention RetentionPolicy, downtimeDetection DowntimeDetectionConfig) (Policies, error) {
+	var policies Policies
+	var err error
+
+	if policies.Retention, err = readRetentionPolicies(config, key, retention); err != nil {
+		return policies, err
+	}
+
+	policies.DowntimeDetection, err = readDowntimeDetection(config, key+".downtime", downtimeDetection)
+
+	return policies, err
+}
+
+// readRetentionPolicies reads the retention policy of a store with its station and service type overrides
+func readRetentionPolicies(config *viper.Viper, key string, defaults RetentionPolicy) (RetentionPolicies, error) {
+	var err error
+
+	var policies RetentionPolicies
+
+	if policies.Default, err = readRetentionPolicy(config, key, defaults); err != nil {
+		return policies, err
+	}
+
+	// Without overrides the maps remain nil, like the policies of a new store:
+	stations := config.GetStringMap(key + ".stations")
+	serviceTypes := config.GetStringMap(key + ".service_types")
+
+	if len(stations) > 0 {
+		policies.Stations = make(map[string]RetentionPolicy)
+	}
+	if len(serviceTypes) > 0 {
+		policies.ServiceTypes = make(map[string]RetentionPolicy)
+	}
+
+	// Configuration keys are case insensitive, station codes and service types are upper case:
+	for station := range stations {
+		if policies.Stations[strings.ToUpper(station)], err = readRetentionPolicy(config, key+".stations."+station, policies.Default); err != nil {
+			return policies, err
+		}
+	}
+
+	for serviceType := range serviceTypes {
+		if policies.ServiceTypes[strings.ToUpper(serviceType)], err = readRetentionPolicy(config, key+".service_types."+serviceType, policies.Default); err != nil {
+			return policies, err
+		}
+	}
+
+	return policies, nil
+}
+
+// readRetentionPolicy reads a retention policy, using base for the thresholds which are not configured
+func readRetentionPolicy(config *viper.Viper, key string, base RetentionPolicy) (RetentionPolicy, error) {
+	for setting, value := range map[string]*time.Duration{
+		".hide":              &base.Hide,
+		".hide_non_realtime": &base.HideNonRealtime,
+		".remove":            &base.Remove,
+	} {
+		if err := readSetting(config, key+setting, value, cast.ToDurationE); err != nil {
+			return base, err
+		}
+
+		if *value < 0 {
+			return base, fmt.Errorf("%s%s: must not be negative", key, setting)
+		}
+	}
+
+	return base, nil
+}
+
+// readDowntimeDetection reads a downtime detection configuration, using base for the settings which are not configured
+func readDowntimeDetection(config *viper.Viper, key string, base DowntimeDetectionConfig) (DowntimeDetectionConfig, error) {
+	floats := map[string]*float64{
+		".min_average":       &base.MinAverage,
+		".min_average_night": &base.MinAverageNight,
+	}
+	ints := map[string]*int{
+		".night_start_hour": &base.NightStartHour,
+		".night_end_hour":   &base.NightEndHour,
+		".recovery_time":    &base.RecoveryTime,
+	}
+
+	for setting, value := range floats {
+		if err := readSetting(config, key+setting, value, cast.ToFloat64E); err != nil {
+			return base, err
+		}
+
+		if *value < 0 {
+			return base, fmt.Errorf("%s%s: must not be negative", key, setting)
+		}
+	}
+
+	for setting, value := range ints {
+		if err := readSetting(config, key+setting, value, cast.ToIntE); err != nil {
+			return base, err
+		}
+
+		if *value < 0 {
+			return base, fmt.Errorf("%s%s: must not be negative", key, setting)
+		}
+	}
+
+	if base.NightStartHour > 23 || base.NightEndHour > 23 {
+		return base, fmt.Errorf("%s: night hours must be between 0 and 23", key)
+	}
+
+	return base, nil
+}
+
+// readSetting converts a setting when it is configured, and leaves value unchanged otherwise
+func readSetting[T any](config *viper.Viper, key string, value *T, convert func(interface{}) (T, error)) error {
+	if !config.IsSet(key) {
+		return nil
+	}
+
+	converted, err := convert(config.Get(key))
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+
+	*value = converted
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package stores
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func readTestConfig(t *testing.T, config string) {
+	viper.SetConfigType("yaml")
+
+	if err := viper.ReadConfig(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(viper.Reset)
+}
+
+func TestConfigurePolicies(t *testing.T) {
+	InitializeStores()
+
+	readTestConfig(t, `
+stores:
+  departures:
+    hide: 15m
+    stations:
+      shl:
+        hide: 30m
+    service_types:
+      ic:
+        remove: 2h
+    downtime:
+      recovery_time: 30
+  services:
+    remove: 24h
+`)
+
+	if err := ConfigurePolicies(); err != nil {
+		t.Fatal(err)
+	}
+
+	retention := Stores.DepartureStore.Retention
+
+	tables := []struct {
+		station     string
+		serviceType string
+		expected    RetentionPolicy
+	}{
+		{"UT", "SPR", RetentionPolicy{15 * time.Minute, time.Minute, 4 * time.Hour}},
+		{"SHL", "SPR", RetentionPolicy{30 * time.Minute, time.Minute, 4 * time.Hour}},
+		{"UT", "IC", RetentionPolicy{15 * time.Minute, time.Minute, 2 * time.Hour}},
+		{"SHL", "IC", RetentionPolicy{30 * time.Minute, time.Minute, 4 * time.Hour}},
+	}
+
+	for _, table := range tables {
+		if policy := retention.Policy(table.station, table.serviceType); policy != table.expected {
+			t.Errorf("Wrong policy for %s/%s: expected %+v, got %+v", table.station, table.serviceType, table.expected, policy)
+		}
+	}
+
+	if Stores.DepartureStore.DowntimeDetection.RecoveryTime != 30 || Stores.DepartureStore.DowntimeDetection.NightStartHour != 2 {
+		t.Errorf("Wrong downtime detection: %+v", Stores.DepartureStore.DowntimeDetection)
+	}
+
+	if Stores.ArrivalStore.Retention.Default != ArrivalRetention {
+		t.Error("Arrivals should keep the default policy")
+	}
+
+	if Stores.ServiceStore.Retention.Default.Remove != 24*time.Hour {
+		t.Error("Wrong service policy")
+	}
+
+	// Reload without overrides:
+	readTestConfig(t, `
+stores:
+  departures:
+    hide: 5m
+`)
+
+	if err := ConfigurePolicies(); err != nil {
+		t.Fatal(err)
+	}
+
+	if policy := Stores.DepartureStore.Retention.Policy("SHL", "IC"); policy.Hide != 5*time.Minute || policy.Remove != 4*time.Hour {
+		t.Errorf("Overrides should be removed after reload: %+v", policy)
+	}
+}
+
+func TestInvalidPolicies(t *testing.T) {
+	InitializeStores()
+
+	tables := []string{
+		"stores:\n  departures:\n    hide: 5m\n  arrivals:\n    remove: often\n",
+		"stores:\n  departures:\n    hide: 5m\n    stations:\n      ut:\n        hide: -1m\n",
+		"stores:\n  departures:\n    hide: 5m\n  services:\n    downtime:\n      night_start_hour: 25\n",
+	}
+
+	for _, table := range tables {
+		config := viper.New()
+		config.SetConfigType("yaml")
+
+		if err := config.ReadConfig(strings.NewReader(table)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ReadPolicies(config); err == nil {
+			t.Errorf("Expected an error for %q", table)
+		}
+	}
+
+	// The valid departures setting is not applied either:
+	readTestConfig(t, tables[0])
+
+	if err := ConfigurePolicies(); err == nil {
+		t.Error("Expected an error")
+	}
+
+	if Stores.DepartureStore.Retention.Default != DepartureRetention {
+		t.Errorf("Invalid configuration should not change the policies: %+v", Stores.DepartureStore.Retention)
+	}
+}
+
+func TestCleanupStationPolicy(t *testing.T) {
+	var store DepartureStore
+	store.InitStore()
+
+	store.Retention.Stations = map[string]RetentionPolicy{
+		"SHL": {Hide: time.Hour, HideNonRealtime: time.Hour, Remove: 4 * time.Hour},
+	}
+
+	departure := generateDeparture()
+	store.ProcessDeparture(departure)
+
+	schiphol := generateDeparture()
+	schiphol.Station.Code = "SHL"
+	schiphol.GenerateID()
+	store.ProcessDeparture(schiphol)
+
+	store.CleanUp(departure.DepartureTime.Add(30 * time.Minute))
+
+	if !store.GetDeparture("1234", "2019-01-27", "UT").Hidden {
+		t.Error("Departure should be hidden after 10 minutes")
+	}
+
+	if store.GetDeparture("1234", "2019-01-27", "SHL").Hidden {
+		t.Error("Departure at SHL should be visible for an hour")
+	}
+}
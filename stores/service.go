@@ -1,6 +1,7 @@
 package stores
 
 import (
+	"sort"
 	"time"
 
 	"github.com/rijdendetreinen/gotrain/models"
@@ -11,6 +12,7 @@ import (
 type ServiceStore struct {
 	Store
 	services map[string]models.Service
+	numbers  map[string]map[string]struct{}
 }
 
 // ProcessService adds or updates a service in a service store
@@ -25,7 +27,7 @@ func (store *ServiceStore) ProcessService(newService models.Service) {
 	if serviceExists {
 		// Check for duplicate:
 		if existingService.ProductID == newService.ProductID {
-			log.Info().Str("ProductID", newService.ProductID).Msg("Service is duplicate")
+			store.messageLog().Info().Str("ProductID", newService.ProductID).Msg("Service is duplicate")
 
 			store.Counters.Duplicates++
 			// We process duplicates anyway, just in case there was a mess-up somewhere.
@@ -33,7 +35,7 @@ func (store *ServiceStore) ProcessService(newService models.Service) {
 
 		// Check whether newService is actually newer:
 		if existingService.Timestamp.After(newService.Timestamp) {
-			log.Info().
+			store.messageLog().Info().
 				Str("ProductID", newService.ProductID).
 				Time("ExistingTimestamp", existingService.Timestamp).
 				Time("NewTimestamp", newService.Timestamp).
@@ -52,26 +54,75 @@ func (store *ServiceStore) ProcessService(newService models.Service) {
 	threshold = threshold.Add(-10 * time.Second)
 
 	if newService.Timestamp.Before(threshold) {
-		log.Debug().Str("ProductID", newService.ProductID).Msg("Service is outdated")
+		store.messageLog().Debug().Str("ProductID", newService.ProductID).Msg("Service is outdated")
 		store.Counters.TooLate++
 	}
 
 	store.Lock()
+	previous, previousExists := store.services[newService.ID]
+	if previousExists {
+		store.removeNumberReferences(previous)
+	}
+	store.recordHistory(newService.ID, newService.StoreItem, serviceChanges(previous, newService))
 	store.services[newService.ID] = newService
+	store.updateNumberReferences(newService)
+	store.persist(bucketServices, newService.ID, newService)
+	store.wal.Append(walRecord{Service: &newService})
 	store.Unlock()
 
 	store.Counters.Processed++
+
+	change := Change{ChangeUpdated, newService.ID, "", newService, nil}
+
+	if previousExists {
+		change.Previous = previous
+	}
+
+	store.notifyChange(change)
+}
+
+// serviceNumbers returns the service number and the service numbers of all service parts
+func serviceNumbers(service models.Service) []string {
+	numbers := []string{service.ServiceNumber}
+
+	for _, part := range service.ServiceParts {
+		if part.ServiceNumber != "" && part.ServiceNumber != service.ServiceNumber {
+			numbers = append(numbers, part.ServiceNumber)
+		}
+	}
+
+	return numbers
+}
+
+func (store *ServiceStore) updateNumberReferences(service models.Service) {
+	for _, number := range serviceNumbers(service) {
+		_, numberExists := store.numbers[number]
+		if !numberExists {
+			store.numbers[number] = make(map[string]struct{})
+		}
+
+		store.numbers[number][service.ID] = struct{}{}
+	}
+}
+
+func (store *ServiceStore) removeNumberReferences(service models.Service) {
+	for _, number := range serviceNumbers(service) {
+		delete(store.numbers[number], service.ID)
+
+		if len(store.numbers[number]) == 0 {
+			delete(store.numbers, number)
+		}
+	}
 }
 
 // InitStore initializes the service store by creating the services map
 func (store *ServiceStore) InitStore() {
 	store.services = make(map[string]models.Service)
+	store.numbers = make(map[string]map[string]struct{})
+	store.history = make(map[string][]HistoryEntry)
 
-	store.DowntimeDetection.MinAverage = float64(1) / 60        // One message per minute
-	store.DowntimeDetection.MinAverageNight = float64(1) / 1800 // One message per 30 minutes
-	store.DowntimeDetection.NightStartHour = 2                  // Night starts at 02:00
-	store.DowntimeDetection.NightEndHour = 5                    // Night ends at 05:00
-	store.DowntimeDetection.RecoveryTime = 1                    // 1 minute recovery time
+	store.Retention = RetentionPolicies{Default: ServiceRetention}
+	store.DowntimeDetection = ServiceDowntimeDetection
 }
 
 // GetNumberOfServices returns the number of services in the store (unfiltered)
@@ -92,6 +143,21 @@ func (store *ServiceStore) GetAllServices() map[string]models.Service {
 	return services
 }
 
+// GetServices returns all services in the store
+func (store *ServiceStore) GetServices(includeHidden bool) []models.Service {
+	var services []models.Service
+
+	store.RLock()
+	for _, service := range store.services {
+		if includeHidden || !service.Hidden {
+			services = append(services, service)
+		}
+	}
+	store.RUnlock()
+
+	return services
+}
+
 // GetService retrieves a single service
 func (store *ServiceStore) GetService(serviceID, serviceDate string) *models.Service {
 	id := serviceDate + "-" + serviceID
@@ -107,32 +173,123 @@ func (store *ServiceStore) GetService(serviceID, serviceDate string) *models.Ser
 	return nil
 }
 
+// GetServicesByNumber returns the services for all known dates with the given service number
+// (either the number of the service or of one of its parts), ordered on service date
+func (store *ServiceStore) GetServicesByNumber(serviceNumber string) []models.Service {
+	var services []models.Service
+
+	store.RLock()
+	for ID := range store.numbers[serviceNumber] {
+		service, found := store.services[ID]
+
+		if found {
+			services = append(services, service)
+		}
+	}
+	store.RUnlock()
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ServiceDate < services[j].ServiceDate
+	})
+
+	return services
+}
+
+// GetCurrentService returns the current service for a service number: the service which is valid
+// for the shortest time after currentTime, or the service which was valid most recently.
+func (store *ServiceStore) GetCurrentService(serviceNumber string, currentTime time.Time) *models.Service {
+	var current *models.Service
+
+	for _, service := range store.GetServicesByNumber(serviceNumber) {
+		service := service
+
+		switch {
+		case current == nil:
+			current = &service
+		case !service.ValidUntil.Before(currentTime):
+			// Valid service, prefer the first one to expire:
+			if current.ValidUntil.Before(currentTime) || service.ValidUntil.Before(current.ValidUntil) {
+				current = &service
+			}
+		case current.ValidUntil.Before(currentTime) && service.ValidUntil.After(current.ValidUntil):
+			// Both expired, prefer the most recent one:
+			current = &service
+		}
+	}
+
+	return current
+}
+
 // hideService hides a service
 func (store *ServiceStore) hideService(serviceID string) {
 	store.Lock()
 	service := store.services[serviceID]
 	service.Hidden = true
 	store.services[serviceID] = service
+	store.persist(bucketServices, serviceID, service)
 	store.Unlock()
+
+	store.notifyChange(Change{ChangeHidden, serviceID, "", service, nil})
 }
 
 // deleteService deletes a service
 func (store *ServiceStore) deleteService(serviceID string) {
 	store.Lock()
+	service := store.services[serviceID]
 	delete(store.services, serviceID)
+	store.removeNumberReferences(service)
+	store.deleteHistory(serviceID)
+	store.unpersist(bucketServices, serviceID)
 	store.Unlock()
+
+	store.notifyChange(Change{ChangeRemoved, serviceID, "", service, nil})
 }
 
 // ReadStore reads the save store contents
 func (store *ServiceStore) ReadStore() error {
-	return readGob("services.gob", &store.services)
+	err := readGob(servicesFile, &store.services)
+
+	if err != nil {
+		return err
+	}
+
+	for _, service := range store.services {
+		store.updateNumberReferences(service)
+	}
+
+	return nil
+}
+
+// ReadBackend reads the services from the backend
+func (store *ServiceStore) ReadBackend() error {
+	return store.backend.Load(bucketServices, func(decode func(item interface{}) error) error {
+		var service models.Service
+
+		if err := decode(&service); err != nil {
+			return err
+		}
+
+		store.services[service.ID] = service
+		store.updateNumberReferences(service)
+
+		return nil
+	})
+}
+
+// persistAll writes all services to the backend
+func (store *ServiceStore) persistAll() {
+	store.Lock()
+	for ID, service := range store.services {
+		store.persist(bucketServices, ID, service)
+	}
+	store.Unlock()
 }
 
 // SaveStore saves the service store contents
 func (store *ServiceStore) SaveStore() error {
 	store.RLock()
 
-	err := writeGob("services.gob", store.services)
+	err := writeGob(servicesFile, store.services)
 
 	store.RUnlock()
 	return err
@@ -140,9 +297,7 @@ func (store *ServiceStore) SaveStore() error {
 
 // CleanUp removes outdated items
 func (store *ServiceStore) CleanUp(currentTime time.Time) {
-	// Remove all services before date X:
-	thresholdRemove := currentTime.AddDate(0, 0, -2)
-	thresholdHide := currentTime
+	retention := store.retentionPolicies()
 
 	log.Debug().Msg("Cleaning up service store")
 
@@ -152,6 +307,12 @@ func (store *ServiceStore) CleanUp(currentTime time.Time) {
 	for serviceID, service := range store.services {
 		store.RUnlock()
 
+		policy := retention.Policy("", service.ServiceTypeCode)
+
+		// Hide services which are no longer valid, and remove them some time later (default 2 days):
+		thresholdRemove := currentTime.Add(-policy.Remove)
+		thresholdHide := currentTime.Add(-policy.Hide)
+
 		if !service.Hidden && service.ValidUntil.Before(thresholdHide) {
 			log.Debug().Str("ServiceID", serviceID).Msg("Hiding service")
 
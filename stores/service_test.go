@@ -269,3 +269,95 @@ func TestSaveServiceStore2(t *testing.T) {
 		t.Errorf("Wrong number of services: expected %d, got %d", 20000, store2.GetNumberOfServices())
 	}
 }
+
+func TestServicesByNumber(t *testing.T) {
+	var store ServiceStore
+	store.InitStore()
+
+	service1 := generateService()
+	service1.ServiceParts[0].ServiceNumber = "1234"
+
+	service2 := generateService()
+	service2.ServiceDate = "2019-01-28"
+	service2.GenerateID()
+	service2.ValidUntil = service2.ValidUntil.AddDate(0, 0, 1)
+
+	var wingPart models.ServicePart
+	wingPart.ServiceNumber = "11234"
+	service2.ServiceParts = append(service2.ServiceParts, wingPart)
+
+	store.ProcessService(service2)
+	store.ProcessService(service1)
+
+	services := store.GetServicesByNumber("1234")
+
+	if len(services) != 2 {
+		t.Fatalf("Expected 2 services, got %d", len(services))
+	}
+
+	if services[0].ServiceDate != "2019-01-27" || services[1].ServiceDate != "2019-01-28" {
+		t.Error("Services should be ordered on service date")
+	}
+
+	services = store.GetServicesByNumber("11234")
+
+	if len(services) != 1 || services[0].ServiceDate != "2019-01-28" {
+		t.Error("Service should be found by service part number")
+	}
+
+	if len(store.GetServicesByNumber("4321")) != 0 {
+		t.Error("Unknown service number should not return services")
+	}
+
+	// Update service without the wing part:
+	service2.ServiceParts = service2.ServiceParts[:1]
+	service2.Timestamp = service2.Timestamp.Add(time.Minute)
+	store.ProcessService(service2)
+
+	if len(store.GetServicesByNumber("11234")) != 0 {
+		t.Error("Number of removed service part should be removed from index")
+	}
+
+	store.deleteService(service1.ID)
+
+	if len(store.GetServicesByNumber("1234")) != 1 {
+		t.Error("Deleted service should be removed from index")
+	}
+}
+
+func TestCurrentService(t *testing.T) {
+	var store ServiceStore
+	store.InitStore()
+
+	service1 := generateService()
+
+	service2 := generateService()
+	service2.ServiceDate = "2019-01-28"
+	service2.GenerateID()
+	service2.ValidUntil = service2.ValidUntil.AddDate(0, 0, 1)
+
+	store.ProcessService(service1)
+	store.ProcessService(service2)
+
+	current := store.GetCurrentService("1234", time.Date(2019, time.January, 27, 12, 0, 0, 0, time.UTC))
+
+	if current == nil || current.ServiceDate != "2019-01-27" {
+		t.Error("Expected service of 2019-01-27 to be current")
+	}
+
+	current = store.GetCurrentService("1234", time.Date(2019, time.January, 27, 18, 0, 0, 0, time.UTC))
+
+	if current == nil || current.ServiceDate != "2019-01-28" {
+		t.Error("Expected service of 2019-01-28 to be current")
+	}
+
+	current = store.GetCurrentService("1234", time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC))
+
+	if current == nil || current.ServiceDate != "2019-01-28" {
+		t.Error("Expected most recent service to be current when all services are expired")
+	}
+
+	if store.GetCurrentService("4321", time.Now()) != nil {
+		t.Error("Unknown service number should not return a service")
+	}
+}
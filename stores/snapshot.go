@@ -0,0 +1,214 @@
+package stores
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SnapshotFormatVersion is the current version of the snapshot format. Increase it when a change of
+// the models breaks decoding of older snapshots, and add a migration from the previous version.
+const SnapshotFormatVersion = 1
+
+// GoTrainVersion is the version of GoTrain which is written to the snapshot header
+var GoTrainVersion = "dev"
+
+// snapshotMagic identifies snapshot files. Files without it are legacy snapshots (format version 0),
+// which only contain the gob encoded store contents.
+var snapshotMagic = []byte("GOTRAIN-SNAPSHOT\n")
+
+// ErrSnapshotCorrupt is returned when a snapshot is truncated or the checksum does not match
+var ErrSnapshotCorrupt = errors.New("snapshot is corrupt")
+
+// SnapshotHeader describes the contents of a snapshot file
+type SnapshotHeader struct {
+	FormatVersion  int
+	GoTrainVersion string
+	Created        time.Time
+	Items          int
+	Size           int    // Size of the payload in bytes
+	Checksum       uint32 // CRC-32 (IEEE) of the payload
+}
+
+// snapshotMigration converts the payload of a snapshot to the next format version
+type snapshotMigration func(payload []byte) ([]byte, error)
+
+// snapshotMigrations contains the migrations by the format version they migrate from
+var snapshotMigrations = map[int]snapshotMigration{
+	// Legacy snapshots only lack the header:
+	0: func(payload []byte) ([]byte, error) { return payload, nil },
+}
+
+// Encode a GOB file. The snapshot is written to a temporary file first, which replaces the
+// existing snapshot only when it has been written completely.
+func writeGob(filePath string, object interface{}) error {
+	var payload, header bytes.Buffer
+
+	if err := gob.NewEncoder(&payload).Encode(object); err != nil {
+		return err
+	}
+
+	err := gob.NewEncoder(&header).Encode(SnapshotHeader{
+		FormatVersion:  SnapshotFormatVersion,
+		GoTrainVersion: GoTrainVersion,
+		Created:        time.Now(),
+		Items:          countItems(object),
+		Size:           payload.Len(),
+		Checksum:       crc32.ChecksumIEEE(payload.Bytes()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	path := getDataDirectory() + filePath
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	w.Write(snapshotMagic)
+	binary.Write(w, binary.BigEndian, uint32(header.Len()))
+	w.Write(header.Bytes())
+	w.Write(payload.Bytes())
+
+	err = w.Flush()
+
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	syncDirectory(filepath.Dir(path))
+
+	return nil
+}
+
+// Read a GOB file
+func readGob(filePath string, object interface{}) error {
+	header, payload, err := readSnapshot(getDataDirectory() + filePath)
+
+	if err != nil {
+		return err
+	}
+
+	log.Debug().Str("file", filePath).Int("format", header.FormatVersion).Str("version", header.GoTrainVersion).
+		Time("created", header.Created).Int("items", header.Items).Msg("Reading snapshot")
+
+	return gob.NewDecoder(bytes.NewReader(payload)).Decode(object)
+}
+
+// readSnapshot reads a snapshot file, verifies its checksum and migrates the payload to the
+// current format version
+func readSnapshot(path string) (SnapshotHeader, []byte, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return SnapshotHeader{}, nil, err
+	}
+
+	header, payload, err := parseSnapshot(data)
+
+	if err != nil {
+		return header, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if header.FormatVersion > SnapshotFormatVersion {
+		return header, nil, fmt.Errorf("%s: snapshot format version %d is not supported (GoTrain %s)", path, header.FormatVersion, header.GoTrainVersion)
+	}
+
+	for version := header.FormatVersion; version < SnapshotFormatVersion; version++ {
+		migration, exists := snapshotMigrations[version]
+
+		if !exists {
+			return header, nil, fmt.Errorf("%s: no migration from snapshot format version %d", path, version)
+		}
+
+		if payload, err = migration(payload); err != nil {
+			return header, nil, fmt.Errorf("%s: migration from snapshot format version %d failed: %w", path, version, err)
+		}
+	}
+
+	return header, payload, nil
+}
+
+// parseSnapshot splits a snapshot into its header and payload
+func parseSnapshot(data []byte) (SnapshotHeader, []byte, error) {
+	var header SnapshotHeader
+
+	if !bytes.HasPrefix(data, snapshotMagic) {
+		header.Size = len(data)
+		return header, data, nil
+	}
+
+	data = data[len(snapshotMagic):]
+
+	if len(data) < 4 {
+		return header, nil, ErrSnapshotCorrupt
+	}
+
+	headerSize := int(binary.BigEndian.Uint32(data))
+	data = data[4:]
+
+	if len(data) < headerSize {
+		return header, nil, ErrSnapshotCorrupt
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(data[:headerSize])).Decode(&header); err != nil {
+		return header, nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+
+	payload := data[headerSize:]
+
+	if len(payload) != header.Size || crc32.ChecksumIEEE(payload) != header.Checksum {
+		return header, nil, ErrSnapshotCorrupt
+	}
+
+	return header, payload, nil
+}
+
+// countItems returns the number of items of a map or slice
+func countItems(object interface{}) int {
+	value := reflect.ValueOf(object)
+
+	switch value.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return value.Len()
+	}
+
+	return 1
+}
+
+// syncDirectory makes sure a rename is persisted. Not supported on all platforms, so errors are ignored.
+func syncDirectory(directory string) {
+	dir, err := os.Open(directory)
+
+	if err != nil {
+		return
+	}
+
+	dir.Sync()
+	dir.Close()
+}
@@ -0,0 +1,104 @@
+package stores
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"testing"
+)
+
+func useTemporaryDataDirectory(t *testing.T) {
+	directory := StoresDataDirectory
+	StoresDataDirectory = t.TempDir() + "/"
+
+	t.Cleanup(func() {
+		StoresDataDirectory = directory
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	useTemporaryDataDirectory(t)
+
+	items := map[string]string{"a": "1", "b": "2"}
+
+	if err := writeGob("test.gob", items); err != nil {
+		t.Fatal(err)
+	}
+
+	header, _, err := readSnapshot(StoresDataDirectory + "test.gob")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.FormatVersion != SnapshotFormatVersion || header.Items != 2 || header.GoTrainVersion != GoTrainVersion || header.Created.IsZero() {
+		t.Errorf("Wrong header: %+v", header)
+	}
+
+	var read map[string]string
+
+	if err := readGob("test.gob", &read); err != nil || read["b"] != "2" {
+		t.Errorf("Wrong contents: %v (%v)", read, err)
+	}
+
+	// No temporary files should remain:
+	entries, _ := os.ReadDir(StoresDataDirectory)
+
+	if len(entries) != 1 {
+		t.Errorf("Expected only the snapshot, found %d files", len(entries))
+	}
+}
+
+func TestCorruptSnapshot(t *testing.T) {
+	useTemporaryDataDirectory(t)
+
+	if err := writeGob("test.gob", []string{"a", "b", "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(StoresDataDirectory + "test.gob")
+
+	var read []string
+
+	// Flipped byte in the payload:
+	corrupt := bytes.Clone(data)
+	corrupt[len(corrupt)-2] ^= 0xff
+	os.WriteFile(StoresDataDirectory+"test.gob", corrupt, 0644)
+
+	if err := readGob("test.gob", &read); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("Expected corrupt snapshot error, got %v", err)
+	}
+
+	// Garbage in the header:
+	corrupt = bytes.Clone(data)
+	for i := len(snapshotMagic) + 4; i < len(snapshotMagic)+12; i++ {
+		corrupt[i] = 0xff
+	}
+	os.WriteFile(StoresDataDirectory+"test.gob", corrupt, 0644)
+
+	if err := readGob("test.gob", &read); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("Expected corrupt snapshot error for a corrupt header, got %v", err)
+	}
+
+	// Truncated file:
+	os.WriteFile(StoresDataDirectory+"test.gob", data[:len(data)-5], 0644)
+
+	if err := readGob("test.gob", &read); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("Expected corrupt snapshot error, got %v", err)
+	}
+}
+
+func TestLegacySnapshot(t *testing.T) {
+	useTemporaryDataDirectory(t)
+
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(map[string]int{"a": 1})
+	os.WriteFile(StoresDataDirectory+"legacy.gob", buffer.Bytes(), 0644)
+
+	var read map[string]int
+
+	if err := readGob("legacy.gob", &read); err != nil || read["a"] != 1 {
+		t.Errorf("Legacy snapshot should be readable: %v (%v)", read, err)
+	}
+}
@@ -0,0 +1,208 @@
+package stores
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+// StationDetails are the details of a station, as learned from the received messages
+type StationDetails struct {
+	models.Station
+
+	// Accessibility flags, only known for stations called by a service:
+	AccessibilityKnown  bool
+	Accessible          bool
+	AssistanceAvailable bool
+}
+
+// The StationStore contains all stations which occur in departures, arrivals and services
+type StationStore struct {
+	sync.RWMutex
+	stations map[string]StationDetails
+}
+
+// InitStore initializes the station store by creating the stations map
+func (store *StationStore) InitStore() {
+	store.stations = make(map[string]StationDetails)
+}
+
+// ProcessDeparture learns all stations of a departure
+func (store *StationStore) ProcessDeparture(departure models.Departure) {
+	stations := []models.Station{departure.Station}
+	stations = append(stations, departure.DestinationActual...)
+	stations = append(stations, departure.DestinationPlanned...)
+	stations = append(stations, departure.ViaActual...)
+	stations = append(stations, departure.ViaPlanned...)
+
+	for _, wing := range departure.TrainWings {
+		stations = append(stations, wing.DestinationActual...)
+		stations = append(stations, wing.DestinationPlanned...)
+		stations = append(stations, wing.Stations...)
+		stations = append(stations, wing.StationsPlanned...)
+	}
+
+	for _, tip := range departure.BoardingTips {
+		stations = append(stations, tip.ExitStation, tip.Destination)
+	}
+
+	for _, tip := range departure.TravelTips {
+		stations = append(stations, tip.Stations...)
+	}
+
+	for _, tip := range departure.ChangeTips {
+		stations = append(stations, tip.Destination, tip.ChangeStation)
+	}
+
+	store.learnStations(stations)
+}
+
+// ProcessArrival learns all stations of an arrival
+func (store *StationStore) ProcessArrival(arrival models.Arrival) {
+	stations := []models.Station{arrival.Station}
+	stations = append(stations, arrival.OriginActual...)
+	stations = append(stations, arrival.OriginPlanned...)
+	stations = append(stations, arrival.ViaActual...)
+	stations = append(stations, arrival.ViaPlanned...)
+
+	store.learnStations(stations)
+}
+
+// ProcessService learns all stations called by a service, including their accessibility
+func (store *StationStore) ProcessService(service models.Service) {
+	for _, part := range service.ServiceParts {
+		for _, stop := range part.Stops {
+			store.learnStation(StationDetails{
+				Station:             stop.Station,
+				AccessibilityKnown:  true,
+				Accessible:          stop.StationAccessible,
+				AssistanceAvailable: stop.AssistanceAvailable,
+			})
+		}
+	}
+}
+
+func (store *StationStore) learnStations(stations []models.Station) {
+	for _, station := range stations {
+		store.learnStation(StationDetails{Station: station})
+	}
+}
+
+// learnStation adds a station to the store, or updates the names and flags which are known
+func (store *StationStore) learnStation(station StationDetails) {
+	if station.Code == "" {
+		return
+	}
+
+	store.RLock()
+	existing, exists := store.stations[station.Code]
+	store.RUnlock()
+
+	updated := mergeStationDetails(existing, station)
+
+	if exists && updated == existing {
+		return
+	}
+
+	store.Lock()
+	store.stations[station.Code] = mergeStationDetails(store.stations[station.Code], station)
+	store.Unlock()
+}
+
+// mergeStationDetails updates existing details with the names and flags known in station
+func mergeStationDetails(existing, station StationDetails) StationDetails {
+	existing.Code = station.Code
+
+	if station.NameShort != "" {
+		existing.NameShort = station.NameShort
+	}
+	if station.NameMedium != "" {
+		existing.NameMedium = station.NameMedium
+	}
+	if station.NameLong != "" {
+		existing.NameLong = station.NameLong
+	}
+
+	if station.AccessibilityKnown {
+		existing.AccessibilityKnown = true
+		existing.Accessible = station.Accessible
+		existing.AssistanceAvailable = station.AssistanceAvailable
+	}
+
+	return existing
+}
+
+// GetNumberOfStations returns the number of stations in the store
+func (store *StationStore) GetNumberOfStations() int {
+	store.RLock()
+	count := len(store.stations)
+	store.RUnlock()
+
+	return count
+}
+
+// GetStation retrieves a single station by its code
+func (store *StationStore) GetStation(code string) *StationDetails {
+	store.RLock()
+	station, found := store.stations[strings.ToUpper(code)]
+	store.RUnlock()
+
+	if found {
+		return &station
+	}
+
+	return nil
+}
+
+// GetStations returns all stations, ordered by code
+func (store *StationStore) GetStations() []StationDetails {
+	return store.SearchStations("")
+}
+
+// SearchStations returns all stations of which the code or one of the names starts with
+// the given prefix (case insensitive), ordered by code
+func (store *StationStore) SearchStations(prefix string) []StationDetails {
+	prefix = strings.ToLower(prefix)
+	stations := []StationDetails{}
+
+	store.RLock()
+	for _, station := range store.stations {
+		if station.matchesPrefix(prefix) {
+			stations = append(stations, station)
+		}
+	}
+	store.RUnlock()
+
+	sort.Slice(stations, func(i, j int) bool {
+		return stations[i].Code < stations[j].Code
+	})
+
+	return stations
+}
+
+func (station StationDetails) matchesPrefix(prefix string) bool {
+	for _, name := range []string{station.Code, station.NameShort, station.NameMedium, station.NameLong} {
+		if strings.HasPrefix(strings.ToLower(name), prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadStore reads the save store contents
+func (store *StationStore) ReadStore() error {
+	return readGob(stationsFile, &store.stations)
+}
+
+// SaveStore saves the station store contents
+func (store *StationStore) SaveStore() error {
+	store.RLock()
+
+	err := writeGob(stationsFile, store.stations)
+
+	store.RUnlock()
+	return err
+}
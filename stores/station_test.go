@@ -0,0 +1,74 @@
+package stores
+
+import (
+	"testing"
+
+	"github.com/rijdendetreinen/gotrain/models"
+)
+
+func TestStationStore(t *testing.T) {
+	var store StationStore
+	store.InitStore()
+
+	departure := generateDeparture()
+	departure.Station = models.Station{Code: "UT", NameShort: "Utrecht", NameMedium: "Utrecht C.", NameLong: "Utrecht Centraal"}
+	departure.DestinationActual = []models.Station{{Code: "GVC", NameLong: "Den Haag Centraal"}}
+	store.ProcessDeparture(departure)
+
+	arrival := generateArrival()
+	arrival.Station = models.Station{Code: "GVC", NameShort: "Den Haag C"}
+	arrival.OriginActual = []models.Station{{Code: "ASD", NameLong: "Amsterdam Centraal"}}
+	store.ProcessArrival(arrival)
+
+	service := generateService()
+	service.ServiceParts[0].Stops[0].StationAccessible = true
+	service.ServiceParts[0].Stops[0].AssistanceAvailable = true
+	store.ProcessService(service)
+
+	if store.GetNumberOfStations() != 3 {
+		t.Fatalf("Expected 3 stations, got %d", store.GetNumberOfStations())
+	}
+
+	station := store.GetStation("gvc")
+
+	if station == nil || station.NameShort != "Den Haag C" || station.NameLong != "Den Haag Centraal" {
+		t.Errorf("Station names should be merged: %+v", station)
+	}
+
+	station = store.GetStation("UT")
+
+	if station == nil || !station.AccessibilityKnown || !station.Accessible || !station.AssistanceAvailable {
+		t.Errorf("Accessibility should be learned from service stops: %+v", station)
+	}
+
+	if store.GetStation("ASD").AccessibilityKnown {
+		t.Error("Accessibility should not be known for stations without service stops")
+	}
+
+	if store.GetStation("XYZ") != nil {
+		t.Error("Unknown station should not be found")
+	}
+
+	stations := store.GetStations()
+
+	if len(stations) != 3 || stations[0].Code != "ASD" || stations[2].Code != "UT" {
+		t.Errorf("Stations should be ordered by code: %+v", stations)
+	}
+
+	tables := []struct {
+		prefix   string
+		expected int
+	}{
+		{"den haag", 1},
+		{"ut", 1},
+		{"A", 1},
+		{"c", 0},
+		{"", 3},
+	}
+
+	for _, table := range tables {
+		if found := store.SearchStations(table.prefix); len(found) != table.expected {
+			t.Errorf("Search for %q: expected %d stations, got %d", table.prefix, table.expected, len(found))
+		}
+	}
+}
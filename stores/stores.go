@@ -1,9 +1,6 @@
 package stores
 
 import (
-	"bufio"
-	"encoding/gob"
-	"os"
 	"sync"
 	"time"
 
@@ -33,6 +30,10 @@ type StoreCollection struct {
 	ArrivalStore   ArrivalStore
 	DepartureStore DepartureStore
 	ServiceStore   ServiceStore
+	StationStore   StationStore
+
+	backend Backend
+	wal     *WAL
 }
 
 // Store is the generic store struct
@@ -44,6 +45,13 @@ type Store struct {
 	MessagesAverage   float64
 	LastStatusChange  time.Time
 	DowntimeDetection DowntimeDetectionConfig
+	Retention         RetentionPolicies
+
+	changeListeners changeListeners
+	history         map[string][]HistoryEntry
+	backend         Backend
+	wal             *WAL
+	replaying       bool
 }
 
 // Counters stores some interesting counters for a store
@@ -143,8 +151,10 @@ func (store *Store) newMeasurement(time time.Time) {
 
 // Update the store status based on the current messagesAverage
 func (store *Store) updateStatus(currentTime time.Time) {
+	downtimeDetection := store.GetDowntimeDetection()
+
 	// Determine whether we are currently receiving messages:
-	isReceiving := store.MessagesAverage >= store.DowntimeDetection.CurrentMinimumAverage(currentTime)
+	isReceiving := store.MessagesAverage >= downtimeDetection.CurrentMinimumAverage(currentTime)
 
 	// Determine possible status changes:
 	if isReceiving && (store.Status == StatusUnknown || store.Status == StatusDown) {
@@ -155,7 +165,7 @@ func (store *Store) updateStatus(currentTime time.Time) {
 	} else if isReceiving && store.Status == StatusRecovering {
 		// We are currently receiving and our status is RECOVERING
 		// Check last update time to see if we can change to UP:
-		if currentTime.Sub(store.LastStatusChange).Seconds() >= float64(store.DowntimeDetection.RecoveryTime*60) {
+		if currentTime.Sub(store.LastStatusChange).Seconds() >= float64(downtimeDetection.RecoveryTime*60) {
 			store.Status = StatusUp
 			store.LastStatusChange = currentTime
 		}
@@ -200,9 +210,37 @@ func InitializeStores() *StoreCollection {
 	Stores.ServiceStore.ResetStatus()
 	Stores.ServiceStore.InitStore()
 
+	Stores.StationStore.InitStore()
+
+	Stores.SetBackend(MemoryBackend{})
+
 	return &Stores
 }
 
+// SetBackend sets the backend of the departure, arrival and service stores
+func (collection *StoreCollection) SetBackend(backend Backend) {
+	collection.backend = backend
+	collection.ArrivalStore.backend = backend
+	collection.DepartureStore.backend = backend
+	collection.ServiceStore.backend = backend
+}
+
+// hasPersistentBackend returns true when the stores are persisted on every change
+func (collection *StoreCollection) hasPersistentBackend() bool {
+	return collection.backend != nil && collection.backend.Persistent()
+}
+
+// CloseBackend closes the backend of the stores
+func CloseBackend() {
+	if Stores.backend == nil {
+		return
+	}
+
+	if err := Stores.backend.Close(); err != nil {
+		log.Error().Err(err).Msg("Can't close store backend")
+	}
+}
+
 // CleanUp cleans all stores and removes outdated items
 func CleanUp() {
 	currentTime := time.Now()
@@ -219,11 +257,20 @@ func TakeMeasurements() {
 	Stores.ServiceStore.TakeMeasurement()
 }
 
-// LoadStores reads all store content files
+// LoadStores reads all store content files, or the contents of the backend when it is persistent
 func LoadStores() {
-	servicesError := Stores.ServiceStore.ReadStore()
-	departuresError := Stores.DepartureStore.ReadStore()
-	arrivalsError := Stores.ArrivalStore.ReadStore()
+	var servicesError, departuresError, arrivalsError error
+
+	if Stores.hasPersistentBackend() {
+		servicesError, departuresError, arrivalsError = readBackends()
+	} else {
+		servicesError = Stores.ServiceStore.ReadStore()
+		departuresError = Stores.DepartureStore.ReadStore()
+		arrivalsError = Stores.ArrivalStore.ReadStore()
+	}
+
+	stationsError := Stores.StationStore.ReadStore()
+	walError := Stores.replayWAL()
 
 	if servicesError != nil {
 		log.Error().Err(servicesError).Msg("Can't load services store")
@@ -234,13 +281,60 @@ func LoadStores() {
 	if arrivalsError != nil {
 		log.Error().Err(arrivalsError).Msg("Can't load arrivals store")
 	}
+	if stationsError != nil {
+		log.Error().Err(stationsError).Msg("Can't load stations store")
+	}
+	if walError != nil {
+		log.Error().Err(walError).Msg("Can't replay write-ahead log")
+	}
+}
+
+// readBackends reads the stores from the backend. When the backend is empty (e.g. after switching
+// from the memory backend), the saved store files are read and written to the backend instead.
+func readBackends() (servicesError, departuresError, arrivalsError error) {
+	servicesError = Stores.ServiceStore.ReadBackend()
+	departuresError = Stores.DepartureStore.ReadBackend()
+	arrivalsError = Stores.ArrivalStore.ReadBackend()
+
+	if servicesError != nil || departuresError != nil || arrivalsError != nil {
+		return
+	}
+
+	if Stores.ServiceStore.GetNumberOfServices() > 0 || Stores.DepartureStore.GetNumberOfDepartures() > 0 || Stores.ArrivalStore.GetNumberOfArrivals() > 0 {
+		return
+	}
+
+	log.Info().Msg("Store backend is empty, importing saved store contents")
+
+	servicesError = Stores.ServiceStore.ReadStore()
+	departuresError = Stores.DepartureStore.ReadStore()
+	arrivalsError = Stores.ArrivalStore.ReadStore()
+
+	Stores.ServiceStore.persistAll()
+	Stores.DepartureStore.persistAll()
+	Stores.ArrivalStore.persistAll()
+
+	return
 }
 
-// SaveStores saves all stores
+// SaveStores saves all stores. Stores with a persistent backend are already saved on every change.
+// The write-ahead log is truncated when the stores have been saved successfully.
 func SaveStores() {
-	servicesError := Stores.ServiceStore.SaveStore()
-	departuresError := Stores.DepartureStore.SaveStore()
-	arrivalsError := Stores.ArrivalStore.SaveStore()
+	var servicesError, departuresError, arrivalsError error
+
+	segment, walError := Stores.wal.Rotate()
+
+	if walError != nil {
+		log.Error().Err(walError).Msg("Can't start new write-ahead log segment")
+	}
+
+	if !Stores.hasPersistentBackend() {
+		servicesError = Stores.ServiceStore.SaveStore()
+		departuresError = Stores.DepartureStore.SaveStore()
+		arrivalsError = Stores.ArrivalStore.SaveStore()
+	}
+
+	stationsError := Stores.StationStore.SaveStore()
 
 	if servicesError != nil {
 		log.Error().Err(servicesError).Msg("Can't save services store")
@@ -251,35 +345,15 @@ func SaveStores() {
 	if arrivalsError != nil {
 		log.Error().Err(arrivalsError).Msg("Can't save arrivals store")
 	}
-}
-
-// Encode a GOB file
-func writeGob(filePath string, object interface{}) error {
-	file, err := os.Create(getDataDirectory() + filePath)
-
-	if err != nil {
-		return err
+	if stationsError != nil {
+		log.Error().Err(stationsError).Msg("Can't save stations store")
 	}
 
-	w := bufio.NewWriter(file)
-	enc := gob.NewEncoder(w)
-	err = enc.Encode(object)
-
-	w.Flush()
-	file.Close()
-
-	return err
-}
-
-// Read a GOB file
-func readGob(filePath string, object interface{}) error {
-	file, err := os.Open(getDataDirectory() + filePath)
-	if err == nil {
-		decoder := gob.NewDecoder(file)
-		err = decoder.Decode(object)
+	if walError == nil && servicesError == nil && departuresError == nil && arrivalsError == nil {
+		if err := Stores.wal.Truncate(segment); err != nil {
+			log.Error().Err(err).Msg("Can't truncate write-ahead log")
+		}
 	}
-	file.Close()
-	return err
 }
 
 func getDataDirectory() string {
@@ -0,0 +1,350 @@
+package stores
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rijdendetreinen/gotrain/models"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// walRecord is a single accepted departure, arrival or service in the write-ahead log
+type walRecord struct {
+	Departure *models.Departure
+	Arrival   *models.Arrival
+	Service   *models.Service
+}
+
+// WALReplay contains the results of the last replay of the write-ahead log
+type WALReplay struct {
+	Segments int
+	Records  int
+	Duration time.Duration
+}
+
+var lastWALReplay WALReplay
+
+// GetWALReplay returns the results of the last replay of the write-ahead log
+func GetWALReplay() WALReplay {
+	return lastWALReplay
+}
+
+// WAL is an append-only write-ahead log of all accepted departures, arrivals and services since
+// the last snapshot. The log consists of numbered segments; a new segment is started for every
+// snapshot, so the older segments can be removed once the snapshot has been saved.
+// All methods can be called on a nil WAL, in which case nothing is logged.
+type WAL struct {
+	Directory string
+
+	mutex   sync.Mutex
+	segment int
+	file    *os.File
+	encoder *gob.Encoder
+}
+
+// OpenWAL opens a write-ahead log, creating the directory if necessary. Existing segments are kept
+// for replay; new records are written to a new segment.
+func OpenWAL(directory string) (*WAL, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	wal := &WAL{Directory: directory}
+	segments, err := wal.segments()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segments) > 0 {
+		wal.segment = segments[len(segments)-1]
+	}
+
+	return wal, nil
+}
+
+func (wal *WAL) segmentFile(segment int) string {
+	return filepath.Join(wal.Directory, fmt.Sprintf("wal-%08d.log", segment))
+}
+
+// segments returns the numbers of all segments in the directory, in order
+func (wal *WAL) segments() ([]int, error) {
+	entries, err := os.ReadDir(wal.Directory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []int
+
+	for _, entry := range entries {
+		var segment int
+
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "wal-") {
+			if _, err := fmt.Sscanf(entry.Name(), "wal-%08d.log", &segment); err == nil {
+				segments = append(segments, segment)
+			}
+		}
+	}
+
+	sort.Ints(segments)
+
+	return segments, nil
+}
+
+// Append writes a record to the current segment, starting a new segment when there is none
+func (wal *WAL) Append(record walRecord) {
+	if wal == nil {
+		return
+	}
+
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	if wal.file == nil {
+		if err := wal.startSegment(); err != nil {
+			log.Error().Err(err).Msg("Could not start write-ahead log segment")
+			return
+		}
+	}
+
+	if err := wal.encoder.Encode(record); err != nil {
+		log.Error().Err(err).Int("segment", wal.segment).Msg("Could not append to write-ahead log")
+	}
+}
+
+// startSegment starts a new segment. Must be called while holding the mutex.
+func (wal *WAL) startSegment() error {
+	file, err := os.OpenFile(wal.segmentFile(wal.segment+1), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	wal.segment++
+	wal.file = file
+	wal.encoder = gob.NewEncoder(file)
+
+	return nil
+}
+
+// Rotate closes the current segment before a snapshot is taken, so new records are written to a
+// new segment. It returns the last segment which is included in the snapshot, to be passed to
+// Truncate when the snapshot has been saved.
+func (wal *WAL) Rotate() (int, error) {
+	if wal == nil {
+		return 0, nil
+	}
+
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	if wal.file == nil {
+		return wal.segment, nil
+	}
+
+	err := wal.file.Close()
+	wal.file = nil
+
+	return wal.segment, err
+}
+
+// Truncate removes all segments up to and including the given segment
+func (wal *WAL) Truncate(segment int) error {
+	if wal == nil {
+		return nil
+	}
+
+	segments, err := wal.segments()
+
+	if err != nil {
+		return err
+	}
+
+	for _, existing := range segments {
+		if existing <= segment {
+			if err := os.Remove(wal.segmentFile(existing)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close closes the current segment
+func (wal *WAL) Close() error {
+	if wal == nil {
+		return nil
+	}
+
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	if wal.file == nil {
+		return nil
+	}
+
+	err := wal.file.Close()
+	wal.file = nil
+
+	return err
+}
+
+// Replay calls fn for every record in the existing segments, in order. A segment which ends with
+// an incomplete record (e.g. after a crash) is replayed up to that record.
+func (wal *WAL) Replay(fn func(record walRecord)) (WALReplay, error) {
+	var replay WALReplay
+
+	if wal == nil {
+		return replay, nil
+	}
+
+	start := time.Now()
+
+	wal.mutex.Lock()
+	current := wal.segment
+	if wal.file == nil {
+		// The last segment is not in use yet, so it was written before opening the log:
+		current++
+	}
+	wal.mutex.Unlock()
+
+	segments, err := wal.segments()
+
+	if err != nil {
+		return replay, err
+	}
+
+	for _, segment := range segments {
+		if segment >= current {
+			break
+		}
+
+		records, err := replaySegment(wal.segmentFile(segment), fn)
+		replay.Segments++
+		replay.Records += records
+
+		if err != nil {
+			log.Warn().Err(err).Int("segment", segment).Int("records", records).Msg("Write-ahead log segment is incomplete")
+		}
+	}
+
+	replay.Duration = time.Since(start)
+
+	return replay, nil
+}
+
+func replaySegment(path string, fn func(record walRecord)) (int, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(file)
+	records := 0
+
+	for {
+		var record walRecord
+
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+
+			return records, err
+		}
+
+		fn(record)
+		records++
+	}
+}
+
+// SetWAL sets the write-ahead log of the departure, arrival and service stores
+func (collection *StoreCollection) SetWAL(wal *WAL) {
+	collection.wal = wal
+	collection.ArrivalStore.wal = wal
+	collection.DepartureStore.wal = wal
+	collection.ServiceStore.wal = wal
+}
+
+// setReplaying marks the departure, arrival and service stores as replaying the write-ahead log
+func (collection *StoreCollection) setReplaying(replaying bool) {
+	collection.ArrivalStore.replaying = replaying
+	collection.DepartureStore.replaying = replaying
+	collection.ServiceStore.replaying = replaying
+}
+
+// nopLogger discards all log messages
+var nopLogger = zerolog.Nop()
+
+// messageLog returns the logger for messages about individual items. Replayed records are mostly
+// duplicates of the snapshot, so these messages are discarded while replaying the write-ahead log.
+func (store *Store) messageLog() *zerolog.Logger {
+	if store.replaying {
+		return &nopLogger
+	}
+
+	return &log.Logger
+}
+
+// replayWAL processes all records in the write-ahead log, without logging them again
+func (collection *StoreCollection) replayWAL() error {
+	wal := collection.wal
+
+	if wal == nil {
+		return nil
+	}
+
+	collection.SetWAL(nil)
+	collection.setReplaying(true)
+	defer func() {
+		collection.setReplaying(false)
+		collection.SetWAL(wal)
+	}()
+
+	replay, err := wal.Replay(func(record walRecord) {
+		switch {
+		case record.Departure != nil:
+			collection.DepartureStore.ProcessDeparture(*record.Departure)
+		case record.Arrival != nil:
+			collection.ArrivalStore.ProcessArrival(*record.Arrival)
+		case record.Service != nil:
+			collection.ServiceStore.ProcessService(*record.Service)
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// Replayed records are not received messages:
+	collection.ArrivalStore.ResetCounters()
+	collection.DepartureStore.ResetCounters()
+	collection.ServiceStore.ResetCounters()
+
+	lastWALReplay = replay
+
+	log.Info().Int("segments", replay.Segments).Int("records", replay.Records).Dur("duration", replay.Duration).
+		Msg("Write-ahead log replayed")
+
+	return nil
+}
+
+// CloseWAL closes the write-ahead log of the stores
+func CloseWAL() {
+	if err := Stores.wal.Close(); err != nil {
+		log.Error().Err(err).Msg("Can't close write-ahead log")
+	}
+}
@@ -0,0 +1,134 @@
+package stores
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func newWALTestCollection(t *testing.T, directory string) *StoreCollection {
+	wal, err := OpenWAL(directory)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collection := &StoreCollection{}
+	collection.DepartureStore.InitStore()
+	collection.ArrivalStore.InitStore()
+	collection.ServiceStore.InitStore()
+	collection.SetWAL(wal)
+
+	return collection
+}
+
+func TestWAL(t *testing.T) {
+	directory := t.TempDir()
+	collection := newWALTestCollection(t, directory)
+
+	departure := generateDeparture()
+	collection.DepartureStore.ProcessDeparture(departure)
+	collection.ArrivalStore.ProcessArrival(generateArrival())
+	collection.ServiceStore.ProcessService(generateService())
+
+	departure.ProductID = "12346"
+	departure.PlatformActual = "5b"
+	departure.Timestamp = departure.Timestamp.Add(1)
+	collection.DepartureStore.ProcessDeparture(departure)
+
+	collection.wal.Close()
+
+	// Simulate a crash while writing a record:
+	file, _ := os.OpenFile(collection.wal.segmentFile(1), os.O_WRONLY|os.O_APPEND, 0644)
+	file.Write([]byte{0x20, 0xff})
+	file.Close()
+
+	restored := newWALTestCollection(t, directory)
+
+	if err := restored.replayWAL(); err != nil {
+		t.Fatal(err)
+	}
+
+	if GetWALReplay().Records != 4 || GetWALReplay().Segments != 1 {
+		t.Errorf("Wrong replay: %+v", GetWALReplay())
+	}
+
+	restoredDeparture := restored.DepartureStore.GetDeparture("1234", "2019-01-27", "UT")
+
+	if restoredDeparture == nil || restoredDeparture.PlatformActual != "5b" {
+		t.Errorf("Wrong restored departure: %+v", restoredDeparture)
+	}
+
+	if restored.ArrivalStore.GetNumberOfArrivals() != 1 || restored.ServiceStore.GetNumberOfServices() != 1 {
+		t.Error("Arrival and service should be restored")
+	}
+
+	if restored.DepartureStore.Counters.Processed != 0 {
+		t.Error("Replayed records should not be counted")
+	}
+
+	// New records go to a new segment, which is not truncated:
+	departure.ServiceID = "5678"
+	departure.GenerateID()
+	restored.DepartureStore.ProcessDeparture(departure)
+
+	segment, err := restored.wal.Rotate()
+
+	if err != nil || segment != 2 {
+		t.Fatalf("Wrong rotated segment %d (%v)", segment, err)
+	}
+
+	departure.ServiceID = "9012"
+	departure.GenerateID()
+	restored.DepartureStore.ProcessDeparture(departure)
+
+	if err := restored.wal.Truncate(segment); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, _ := restored.wal.segments()
+
+	if len(segments) != 1 || segments[0] != 3 {
+		t.Errorf("Only the segment after the snapshot should remain: %v", segments)
+	}
+
+	restored.wal.Close()
+}
+
+func TestWALReplayLogging(t *testing.T) {
+	directory := t.TempDir()
+	collection := newWALTestCollection(t, directory)
+	collection.DepartureStore.ProcessDeparture(generateDeparture())
+	collection.wal.Close()
+
+	// The departure is also in the snapshot:
+	restored := newWALTestCollection(t, directory)
+	wal := restored.wal
+	restored.SetWAL(nil)
+	restored.DepartureStore.ProcessDeparture(generateDeparture())
+	restored.SetWAL(wal)
+	defer wal.Close()
+
+	var output bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&output)
+	defer func() { log.Logger = logger }()
+
+	if err := restored.replayWAL(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(output.String(), "duplicate") {
+		t.Errorf("Replayed duplicates should not be logged: %s", output.String())
+	}
+
+	restored.DepartureStore.ProcessDeparture(generateDeparture())
+
+	if !strings.Contains(output.String(), "duplicate") {
+		t.Error("Duplicates should be logged again after the replay")
+	}
+}